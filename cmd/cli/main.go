@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/dimitrovvlado/redis-server/internal/protocol"
@@ -34,8 +35,13 @@ func main() {
 	scanner := bufio.NewScanner(os.Stdin)
 	buf := make([]byte, 0, 4096)
 	rbuf := make([]byte, 1024)
+	var sess session
 	for {
-		fmt.Printf("%s:%d> ", *host, *port)
+		if sess.db != 0 {
+			fmt.Printf("%s:%d[%d]> ", *host, *port, sess.db)
+		} else {
+			fmt.Printf("%s:%d> ", *host, *port)
+		}
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -65,6 +71,7 @@ func main() {
 				frame, size := protocol.ExtractFrameFromBuffer(buf)
 				if frame != nil {
 					fmt.Printf("%s\n", frame.String())
+					sess.update(line, frame)
 					buf = buf[size:] //trim the extracted frame
 					break
 				}
@@ -81,3 +88,71 @@ func encodeCmd(cmd string) protocol.Resp {
 	}
 	return resp
 }
+
+// session tracks the database selected by the commands sent, for the prompt.
+type session struct {
+	db    int
+	multi bool
+	// queued holds, for each command queued by the transaction, the database it selects or -1
+	// if it is not a SELECT.
+	queued []int
+}
+
+// update tracks the command line once it got the reply. The database only changes when a SELECT
+// succeeds, which within a transaction is once EXEC executed it.
+func (s *session) update(line string, reply protocol.Resp) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	name := strings.ToLower(fields[0])
+	if !s.multi {
+		switch {
+		case name == "multi" && isOK(reply):
+			s.multi = true
+		case name == "select" && isOK(reply):
+			if db := selectArg(fields); db >= 0 {
+				s.db = db
+			}
+		}
+		return
+	}
+	switch name {
+	case "exec":
+		if a, ok := reply.(protocol.Array); ok && len(a.Items) == len(s.queued) {
+			for i, db := range s.queued {
+				if db >= 0 && isOK(a.Items[i]) {
+					s.db = db
+				}
+			}
+		}
+		s.multi, s.queued = false, nil
+	case "discard":
+		s.multi, s.queued = false, nil
+	default:
+		if r, ok := reply.(protocol.SimpleString); ok && r.Data == "QUEUED" {
+			db := -1
+			if name == "select" {
+				db = selectArg(fields)
+			}
+			s.queued = append(s.queued, db)
+		}
+	}
+}
+
+// selectArg returns the database selected by the SELECT command line, or -1 if it is invalid.
+func selectArg(fields []string) int {
+	if len(fields) != 2 {
+		return -1
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+func isOK(reply protocol.Resp) bool {
+	r, ok := reply.(protocol.SimpleString)
+	return ok && r.Data == "OK"
+}
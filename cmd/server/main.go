@@ -13,12 +13,26 @@ func main() {
 
 	host := flag.String("host", "localhost", "Server hostname")
 	port := flag.Int("port", 6379, "Server port")
+	hz := flag.Int("hz", 10, "Number of active expire cycles per second")
+	databases := flag.Int("databases", 16, "Number of databases")
+	notify := flag.String("notify-keyspace-events", "", "Classes of keyspace events published, like KEA")
 	flag.Parse()
+	if *databases < 1 {
+		log.Fatalf("Invalid number of databases: %d", *databases)
+	}
+	events, err := server.ParseKeyspaceEvents(*notify)
+	if err != nil {
+		log.Fatalf("Invalid notify-keyspace-events: %v", err)
+	}
 
-	ds := datastore.NewDatastore()
-	go ds.StartExpiryCheck()
+	broker := server.NewBroker()
+	dbs := make([]*datastore.Datastore, *databases)
+	for i := range dbs {
+		dbs[i] = datastore.NewDatastore(datastore.WithHz(*hz), datastore.WithNotifier(events.Notifier(broker, i)))
+		go dbs[i].StartExpiryCheck()
+	}
 
-	err := server.Serve(*host, *port, ds)
+	err = server.Serve(*host, *port, dbs, broker)
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err.Error())
 	}
@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var errBitFieldType = errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+
+func handleSetBitCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("setbit")
+	}
+	offset, err := parseBitOffset(args[1], false, 1)
+	if err != nil {
+		return errorReply(err)
+	}
+	value := args[2].String()
+	if value != "0" && value != "1" {
+		return protocol.Error{Data: "ERR bit is not an integer or out of range"}
+	}
+	old, err := ds.SetBit(args[0].String(), offset, value[0]-'0')
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: int64(old)}
+}
+
+func handleGetBitCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("getbit")
+	}
+	offset, err := parseBitOffset(args[1], false, 1)
+	if err != nil {
+		return errorReply(err)
+	}
+	bit, err := ds.GetBit(args[0].String(), offset)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: int64(bit)}
+}
+
+func handleBitCountCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("bitcount")
+	}
+	var r *datastore.BitRange
+	switch len(args) {
+	case 1:
+	case 3, 4:
+		var err error
+		if r, err = parseBitRange(args[1], args[2], args[3:]); err != nil {
+			return errorReply(err)
+		}
+	default:
+		return errorReply(errSyntax)
+	}
+	n, err := ds.BitCount(args[0].String(), r)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleBitPosCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("bitpos")
+	}
+	bit := args[1].String()
+	if bit != "0" && bit != "1" {
+		if _, err := parseInt(args[1]); err != nil {
+			return errorReply(err)
+		}
+		return protocol.Error{Data: "ERR The bit argument must be 1 or 0."}
+	}
+	var r *datastore.BitRange
+	endGiven := len(args) > 3
+	switch len(args) {
+	case 2:
+	case 3:
+		start, err := parseInt(args[2])
+		if err != nil {
+			return errorReply(err)
+		}
+		r = &datastore.BitRange{Start: start, End: -1}
+	case 4, 5:
+		var err error
+		if r, err = parseBitRange(args[2], args[3], args[4:]); err != nil {
+			return errorReply(err)
+		}
+	default:
+		return errorReply(errSyntax)
+	}
+	pos, err := ds.BitPos(args[0].String(), bit[0]-'0', r, endGiven)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: pos}
+}
+
+// parseBitRange parses the start and end of BITCOUNT and BITPOS, followed by an optional unit.
+func parseBitRange(startArg, endArg protocol.Resp, unit []protocol.Resp) (*datastore.BitRange, error) {
+	start, err := parseInt(startArg)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseInt(endArg)
+	if err != nil {
+		return nil, err
+	}
+	r := &datastore.BitRange{Start: start, End: end}
+	if len(unit) > 0 {
+		switch strings.ToUpper(unit[0].String()) {
+		case "BIT":
+			r.Bit = true
+		case "BYTE":
+		default:
+			return nil, errSyntax
+		}
+	}
+	return r, nil
+}
+
+func handleBitOpCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs("bitop")
+	}
+	var op datastore.BitOperation
+	switch strings.ToUpper(args[0].String()) {
+	case "AND":
+		op = datastore.BitAnd
+	case "OR":
+		op = datastore.BitOr
+	case "XOR":
+		op = datastore.BitXor
+	case "NOT":
+		op = datastore.BitNot
+		if len(args) != 3 {
+			return protocol.Error{Data: "ERR BITOP NOT must be called with a single source key."}
+		}
+	default:
+		return errorReply(errSyntax)
+	}
+	n, err := ds.BitOp(op, args[1].String(), stringArgs(args[2:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleBitFieldCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleBitField("bitfield", args, false, ds)
+}
+
+func handleBitFieldROCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleBitField("bitfield_ro", args, true, ds)
+}
+
+func handleBitField(cmd string, args []protocol.Resp, readOnly bool, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs(cmd)
+	}
+	ops := make([]datastore.BitFieldOp, 0)
+	overflow := datastore.BitFieldWrap
+	for i := 1; i < len(args); i++ {
+		sub := strings.ToUpper(args[i].String())
+		if readOnly && sub != "GET" {
+			return protocol.Error{Data: "ERR BITFIELD_RO only supports the GET subcommand"}
+		}
+		var op datastore.BitFieldOp
+		switch {
+		case sub == "GET" && i+2 < len(args):
+			op.Opcode = datastore.BitFieldGet
+		case sub == "SET" && i+3 < len(args):
+			op.Opcode = datastore.BitFieldSet
+		case sub == "INCRBY" && i+3 < len(args):
+			op.Opcode = datastore.BitFieldIncrBy
+		case sub == "OVERFLOW" && i+1 < len(args):
+			switch strings.ToUpper(args[i+1].String()) {
+			case "WRAP":
+				overflow = datastore.BitFieldWrap
+			case "SAT":
+				overflow = datastore.BitFieldSat
+			case "FAIL":
+				overflow = datastore.BitFieldFail
+			default:
+				return protocol.Error{Data: "ERR Invalid OVERFLOW type specified"}
+			}
+			i++
+			continue
+		default:
+			return errorReply(errSyntax)
+		}
+		var err error
+		if op.Signed, op.Bits, err = parseBitFieldType(args[i+1].String()); err != nil {
+			return errorReply(err)
+		}
+		if op.Offset, err = parseBitOffset(args[i+2], true, op.Bits); err != nil {
+			return errorReply(err)
+		}
+		if op.Opcode != datastore.BitFieldGet {
+			if op.Value, err = parseInt(args[i+3]); err != nil {
+				return errorReply(err)
+			}
+			i++
+		}
+		op.Overflow = overflow
+		ops = append(ops, op)
+		i += 2
+	}
+	results, err := ds.BitField(args[0].String(), ops)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(results))
+	for i, r := range results {
+		if r == nil {
+			items[i] = protocol.BulkString{Data: nil}
+		} else {
+			items[i] = protocol.Integer{Value: *r}
+		}
+	}
+	return protocol.Array{Items: items}
+}
+
+// parseBitFieldType parses a BITFIELD type such as i8 or u16.
+func parseBitFieldType(s string) (bool, uint, error) {
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'I' && s[0] != 'u' && s[0] != 'U') {
+		return false, 0, errBitFieldType
+	}
+	signed := s[0] == 'i' || s[0] == 'I'
+	n, err := strconv.ParseUint(s[1:], 10, 8)
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, errBitFieldType
+	}
+	return signed, uint(n), nil
+}
+
+// parseBitOffset parses a bit offset. When hashed is set, offsets prefixed with "#" are
+// multiplied by the width of the field, as BITFIELD does.
+func parseBitOffset(arg protocol.Resp, hashed bool, width uint) (int64, error) {
+	s := arg.String()
+	multiplier := int64(1)
+	if hashed && strings.HasPrefix(s, "#") {
+		s = s[1:]
+		multiplier = int64(width)
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 || offset > math.MaxInt64/multiplier {
+		return 0, datastore.ErrBitOffset
+	}
+	return offset * multiplier, nil
+}
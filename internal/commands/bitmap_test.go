@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func integerArray(values ...int64) protocol.Array {
+	items := make([]protocol.Resp, len(values))
+	for i, v := range values {
+		items[i] = protocol.Integer{Value: v}
+	}
+	return protocol.Array{Items: items}
+}
+
+func TestBitCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SETBIT", "k", "7", "1"), protocol.Integer{Value: 0}},
+		{command("SETBIT", "k", "7", "0"), protocol.Integer{Value: 1}},
+		{command("SETBIT", "k", "7", "2"), protocol.Error{Data: "ERR bit is not an integer or out of range"}},
+		{command("SETBIT", "k", "-1", "1"), protocol.Error{Data: "ERR bit offset is not an integer or out of range"}},
+		{command("SETBIT", "k", "7", "1"), protocol.Integer{Value: 0}},
+		{command("GETBIT", "k", "7"), protocol.Integer{Value: 1}},
+		{command("GETBIT", "k", "100"), protocol.Integer{Value: 0}},
+		{command("GET", "k"), bulkString("\x01")},
+		{command("SET", "k", "foobar"), protocol.SimpleString{Data: "OK"}},
+		{command("BITCOUNT", "k"), protocol.Integer{Value: 26}},
+		{command("BITCOUNT", "k", "0", "0"), protocol.Integer{Value: 4}},
+		{command("BITCOUNT", "k", "1", "1", "BYTE"), protocol.Integer{Value: 6}},
+		{command("BITCOUNT", "k", "5", "30", "BIT"), protocol.Integer{Value: 17}},
+		{command("BITCOUNT", "k", "1"), protocol.Error{Data: "ERR syntax error"}},
+		{command("BITCOUNT", "missing"), protocol.Integer{Value: 0}},
+		{command("SET", "k", "\xff\xf0\x00"), protocol.SimpleString{Data: "OK"}},
+		{command("BITPOS", "k", "0"), protocol.Integer{Value: 12}},
+		{command("SET", "k", "\x00\xff\xf0"), protocol.SimpleString{Data: "OK"}},
+		{command("BITPOS", "k", "1", "0"), protocol.Integer{Value: 8}},
+		{command("BITPOS", "k", "1", "2"), protocol.Integer{Value: 16}},
+		{command("BITPOS", "k", "1", "2", "-1", "BYTE"), protocol.Integer{Value: 16}},
+		{command("BITPOS", "k", "1", "7", "15", "BIT"), protocol.Integer{Value: 8}},
+		{command("BITPOS", "k", "2"), protocol.Error{Data: "ERR The bit argument must be 1 or 0."}},
+		{command("SET", "k", "\xff\xff\xff"), protocol.SimpleString{Data: "OK"}},
+		{command("BITPOS", "k", "0"), protocol.Integer{Value: 24}},
+		{command("BITPOS", "k", "0", "0", "-1"), protocol.Integer{Value: -1}},
+		{command("BITPOS", "missing", "0"), protocol.Integer{Value: 0}},
+		{command("BITPOS", "missing", "1"), protocol.Integer{Value: -1}},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("GETBIT", "list", "0"), wrongType},
+	})
+}
+
+func TestBitOpCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SET", "k1", "foobar"), protocol.SimpleString{Data: "OK"}},
+		{command("SET", "k2", "abcdef"), protocol.SimpleString{Data: "OK"}},
+		{command("BITOP", "AND", "dest", "k1", "k2"), protocol.Integer{Value: 6}},
+		{command("GET", "dest"), bulkString("`bc`ab")},
+		{command("BITOP", "OR", "dest", "k1", "missing"), protocol.Integer{Value: 6}},
+		{command("GET", "dest"), bulkString("foobar")},
+		{command("BITOP", "AND", "dest", "k1", "missing"), protocol.Integer{Value: 6}},
+		{command("GET", "dest"), bulkString("\x00\x00\x00\x00\x00\x00")},
+		{command("BITOP", "NOT", "dest", "k1", "k2"), protocol.Error{Data: "ERR BITOP NOT must be called with a single source key."}},
+		{command("SET", "k3", "\x0f"), protocol.SimpleString{Data: "OK"}},
+		{command("BITOP", "NOT", "dest", "k3"), protocol.Integer{Value: 1}},
+		{command("GET", "dest"), bulkString("\xf0")},
+		{command("BITOP", "XOR", "dest", "missing"), protocol.Integer{Value: 0}},
+		{command("EXISTS", "dest"), protocol.Integer{Value: 0}},
+		{command("BITOP", "NAND", "dest", "k1"), protocol.Error{Data: "ERR syntax error"}},
+	})
+}
+
+func TestBitFieldCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("BITFIELD", "k", "INCRBY", "i5", "100", "1", "GET", "u4", "0"), integerArray(1, 0)},
+		{command("BITFIELD", "k2", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"), integerArray(1, 1)},
+		{command("BITFIELD", "k2", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"), integerArray(2, 2)},
+		{command("BITFIELD", "k2", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"), integerArray(3, 3)},
+		{command("BITFIELD", "k2", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"), integerArray(0, 3)},
+		{command("BITFIELD", "k2", "OVERFLOW", "FAIL", "INCRBY", "u2", "102", "1"), protocol.Array{Items: []protocol.Resp{protocol.BulkString{Data: nil}}}},
+		{command("BITFIELD", "k3", "SET", "i8", "#1", "-100", "GET", "i8", "8", "GET", "u8", "#1"), integerArray(0, -100, 156)},
+		{command("BITFIELD", "k3", "SET", "i8", "0", "200"), integerArray(0)},
+		{command("BITFIELD_RO", "k3", "GET", "i8", "0"), integerArray(-56)},
+		{command("BITFIELD", "k3", "OVERFLOW", "SAT", "INCRBY", "i8", "0", "-100"), integerArray(-128)},
+		{command("BITFIELD_RO", "k3", "SET", "i8", "0", "1"), protocol.Error{Data: "ERR BITFIELD_RO only supports the GET subcommand"}},
+		{command("BITFIELD", "k3", "GET", "u64", "0"), protocol.Error{Data: "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."}},
+		{command("BITFIELD", "k3", "OVERFLOW", "BOGUS"), protocol.Error{Data: "ERR Invalid OVERFLOW type specified"}},
+		{command("BITFIELD", "k3", "GET", "i8"), protocol.Error{Data: "ERR syntax error"}},
+		{command("BITFIELD_RO", "missing", "GET", "i64", "0"), integerArray(0)},
+		{command("EXISTS", "missing"), protocol.Integer{Value: 0}},
+	})
+}
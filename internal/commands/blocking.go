@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var (
+	errTimeoutNotFloat = errors.New("ERR timeout is not a float or out of range")
+	errTimeoutNegative = errors.New("ERR timeout is negative")
+)
+
+// blockedCommand is a blocking command waiting for one of its lists to get elements.
+type blockedCommand struct {
+	db      *datastore.Datastore
+	blocked *datastore.Blocked
+	// timeout is how long the command waits, forever if 0.
+	timeout time.Duration
+	reply   func(datastore.Popped) protocol.Resp
+}
+
+// blockingPop executes the pop if one of its lists has elements, or else blocks the client until
+// a write gives them some or the timeout elapses. Commands executed by EXEC do not block and
+// reply like a timeout instead.
+func (c *Client) blockingPop(pop datastore.ListPop, timeout time.Duration, reply func(datastore.Popped) protocol.Resp) protocol.Resp {
+	ds := c.selected()
+	if c.exec {
+		popped, err := ds.PopFirst(pop)
+		if err != nil {
+			return errorReply(err)
+		}
+		if popped.Key == "" {
+			return protocol.Array{}
+		}
+		return reply(popped)
+	}
+	b := datastore.NewBlocked(pop)
+	popped, served, err := ds.PopOrBlock(b)
+	if err != nil {
+		return errorReply(err)
+	}
+	if !served {
+		c.blocked = &blockedCommand{db: ds, blocked: b, timeout: timeout, reply: reply}
+		return nil
+	}
+	return reply(popped)
+}
+
+// waitUnblocked waits until the blocked command of the client is served, or replies a null
+// array once its timeout elapses or the connection of the client is closed. The caller must not hold the lock of the databases, which the
+// writes serving the command need.
+func (c *Client) waitUnblocked() protocol.Resp {
+	cmd := c.blocked
+	c.blocked = nil
+	var timeout <-chan time.Time
+	if cmd.timeout > 0 {
+		timer := time.NewTimer(cmd.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-cmd.blocked.Served():
+	case <-timeout:
+		if cmd.db.Unblock(cmd.blocked) {
+			return protocol.Array{}
+		}
+		// The command has been served while timing out.
+	case <-c.closed:
+		if cmd.db.Unblock(cmd.blocked) {
+			return protocol.Array{}
+		}
+	}
+	popped, err := cmd.blocked.Result()
+	if err != nil {
+		return errorReply(err)
+	}
+	return cmd.reply(popped)
+}
+
+// parseTimeout parses the timeout of a blocking command, in seconds that may be fractional.
+func parseTimeout(arg protocol.Resp) (time.Duration, error) {
+	v, err := strconv.ParseFloat(arg.String(), 64)
+	if err != nil || math.IsNaN(v) || v*float64(time.Second) > math.MaxInt64 {
+		return 0, errTimeoutNotFloat
+	}
+	if v < 0 {
+		return 0, errTimeoutNegative
+	}
+	return time.Duration(v * float64(time.Second)), nil
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+// block executes the blocking command on behalf of the client in the background, once the
+// clients blocked before it are. Returns the channel receiving its reply.
+func block(t *testing.T, c *Client, in protocol.Resp) <-chan protocol.Resp {
+	t.Helper()
+	ds := c.selected()
+	before := ds.NumBlocked()
+	reply := make(chan protocol.Resp, 1)
+	go func() {
+		got, _ := HandleCommand(in, c)
+		reply <- got
+	}()
+	for ds.NumBlocked() == before {
+		select {
+		case got := <-reply:
+			t.Fatalf("%s: expected to block, got %v", in, got)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return reply
+}
+
+func expectReply(t *testing.T, reply <-chan protocol.Resp, expected protocol.Resp) {
+	t.Helper()
+	select {
+	case got := <-reply:
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected %v, got no reply", expected)
+	}
+}
+
+func TestBlockingPop(t *testing.T) {
+	dbs := newDatabases(1)
+	first, second, third := block(t, NewClient(dbs), command("BLPOP", "a", "b", "0")),
+		block(t, NewClient(dbs), command("BRPOP", "b", "0")),
+		block(t, NewClient(dbs), command("BLMPOP", "0", "1", "b", "LEFT", "COUNT", "5"))
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("RPUSH", "b", "1", "2", "3", "4"), protocol.Integer{Value: 4}},
+		{command("EXISTS", "b"), protocol.Integer{Value: 0}},
+	})
+	// The clients are served in the order they blocked.
+	expectReply(t, first, bulkStringArray([]string{"b", "1"}))
+	expectReply(t, second, bulkStringArray([]string{"b", "4"}))
+	expectReply(t, third, protocol.Array{Items: []protocol.Resp{bulkString("b"), bulkStringArray([]string{"2", "3"})}})
+}
+
+func TestBlockingMove(t *testing.T) {
+	dbs := newDatabases(1)
+	move := block(t, NewClient(dbs), command("BLMOVE", "src", "dst", "RIGHT", "LEFT", "0"))
+	pop := block(t, NewClient(dbs), command("BRPOPLPUSH", "dst", "other", "0"))
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("LPUSH", "src", "x"), protocol.Integer{Value: 1}},
+	})
+	expectReply(t, move, bulkString("x"))
+	expectReply(t, pop, bulkString("x"))
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("LRANGE", "other", "0", "-1"), bulkStringArray([]string{"x"})},
+		{command("BLMOVE", "other", "dst", "LEFT", "LEFT", "0"), bulkString("x")},
+	})
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	dbs := newDatabases(1)
+	c := NewClient(dbs)
+	start := time.Now()
+	runClientSequence(t, c, []step{
+		{command("BLPOP", "a", "0.05"), protocol.Array{}},
+		{command("BLMOVE", "a", "b", "LEFT", "LEFT", "0.01"), protocol.Array{}},
+	})
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected the commands to block until their timeout, returned after %v", elapsed)
+	}
+	if n := c.selected().NumBlocked(); n != 0 {
+		t.Errorf("Expected the timed out clients to be unblocked, got %d", n)
+	}
+	runClientSequence(t, c, []step{
+		{command("BLPOP", "a", "x"), protocol.Error{Data: "ERR timeout is not a float or out of range"}},
+		{command("BLPOP", "a", "-1"), protocol.Error{Data: "ERR timeout is negative"}},
+		{command("BLMPOP", "0", "0", "a", "LEFT"), protocol.Error{Data: "ERR numkeys should be greater than 0"}},
+		{command("BLPOP", "a"), wrongNumberOfArgs("blpop")},
+		{command("SET", "s", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("BRPOP", "s", "0"), wrongType},
+	})
+}
+
+func TestBlockedClientClosed(t *testing.T) {
+	dbs := newDatabases(1)
+	closed := make(chan struct{})
+	reply := block(t, NewClient(dbs, WithClosed(closed)), command("BLPOP", "list", "0"))
+	close(closed)
+	expectReply(t, reply, protocol.Array{})
+	if n := dbs.all[0].NumBlocked(); n != 0 {
+		t.Errorf("Expected the closed client to be unblocked, got %d", n)
+	}
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("RPUSH", "list", "x"), protocol.Integer{Value: 1}},
+		{command("LLEN", "list"), protocol.Integer{Value: 1}},
+	})
+}
+
+func TestBlockingInTransaction(t *testing.T) {
+	dbs := newDatabases(1)
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("RPUSH", "b", "x"), protocol.Integer{Value: 1}},
+		{command("MULTI"), protocol.SimpleString{Data: "OK"}},
+		{command("BLPOP", "a", "0"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("BLPOP", "a", "b", "0"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{protocol.Array{}, bulkStringArray([]string{"b", "x"})}}},
+	})
+}
+
+func TestBlockedServedAfterTransaction(t *testing.T) {
+	dbs := newDatabases(1)
+	reply := block(t, NewClient(dbs), command("BLPOP", "list", "0"))
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("MULTI"), protocol.SimpleString{Data: "OK"}},
+		{command("RPUSH", "list", "x"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("LPOP", "list"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: 1}, bulkString("x")}}},
+		{command("MULTI"), protocol.SimpleString{Data: "OK"}},
+		{command("RPUSH", "list", "y", "z"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("LPOP", "list"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: 2}, bulkString("y")}}},
+	})
+	// The element pushed and popped by the first transaction is never served.
+	expectReply(t, reply, bulkStringArray([]string{"list", "z"}))
+}
+
+func TestBlockedClientWatch(t *testing.T) {
+	dbs := newDatabases(1)
+	c := NewClient(dbs)
+	reply := block(t, NewClient(dbs), command("BLPOP", "list", "0"))
+	runClientSequence(t, c, []step{
+		{command("WATCH", "list"), protocol.SimpleString{Data: "OK"}},
+	})
+	runClientSequence(t, NewClient(dbs), []step{
+		{command("RPUSH", "list", "x"), protocol.Integer{Value: 1}},
+	})
+	expectReply(t, reply, bulkStringArray([]string{"list", "x"}))
+	runClientSequence(t, c, []step{
+		{command("MULTI"), protocol.SimpleString{Data: "OK"}},
+		{command("LLEN", "list"), protocol.SimpleString{Data: "QUEUED"}},
+		{command("EXEC"), protocol.Array{}},
+	})
+	if n := dbs.all[0].NumBlocked(); n != 0 {
+		t.Errorf("Expected no blocked client, got %d", n)
+	}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"sync"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+// Databases are the numbered databases shared by the clients.
+type Databases struct {
+	all []*datastore.Datastore
+	// mu is held for reading while a command executes, and for writing while a transaction
+	// executes so that no other command interleaves with it.
+	mu sync.RWMutex
+}
+
+// NewDatabases returns the numbered databases.
+func NewDatabases(dbs ...*datastore.Datastore) *Databases {
+	return &Databases{all: dbs}
+}
+
+// serveBlocked serves the clients blocked on the lists written by the command or the
+// transaction that just executed. The caller must hold mu.
+func (dbs *Databases) serveBlocked() {
+	for _, ds := range dbs.all {
+		ds.ServeBlocked()
+	}
+}
+
+// Client is the state of a connection: the databases it can select from, the selected one, its
+// transaction and the command it is blocked by.
+type Client struct {
+	dbs *Databases
+	db  int
+
+	// multi is set between MULTI and EXEC or DISCARD, while the commands are queued.
+	multi  bool
+	queued []queuedCommand
+	// execAbort is set when a command could not be queued, which makes EXEC fail.
+	execAbort bool
+	// watch is flagged when one of the watched keys is modified.
+	watch   *datastore.Watch
+	watched []watchedKey
+	// exec is set while EXEC executes the queued commands, which must not block.
+	exec bool
+
+	// blocked is set by a blocking command that found no element, for the client to wait
+	// until it is served once the lock of the databases is released.
+	blocked *blockedCommand
+	// closed is closed once the connection of the client is, which unblocks its command.
+	closed <-chan struct{}
+
+	// commands are the commands of the client implemented outside of this package.
+	commands map[string]*redisCommand
+	// subscriptions returns the number of pub/sub subscriptions of the connection.
+	subscriptions func() int
+}
+
+// Command is a command implemented outside of this package, like the pub/sub commands of the
+// server.
+type Command struct {
+	Handler func(args []protocol.Resp) protocol.Resp
+	// Arity is the number of arguments including the command name, or its opposite when it is
+	// the minimum number of arguments.
+	Arity int
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithCommands adds the commands to the client, taking precedence over the commands of the same
+// name of this package.
+func WithCommands(cmds map[string]Command) ClientOption {
+	return func(c *Client) {
+		if c.commands == nil {
+			c.commands = make(map[string]*redisCommand)
+		}
+		for name, cmd := range cmds {
+			c.commands[name] = &redisCommand{handler: stateless(cmd.Handler), arity: cmd.Arity}
+		}
+	}
+}
+
+// WithSubscriptions makes the client count the pub/sub subscriptions of its connection with
+// subscriptions. The client is in subscriber mode while it has some.
+func WithSubscriptions(subscriptions func() int) ClientOption {
+	return func(c *Client) {
+		c.subscriptions = subscriptions
+	}
+}
+
+// WithClosed makes the client give up its blocked command once closed is closed, like when
+// its connection has been closed.
+func WithClosed(closed <-chan struct{}) ClientOption {
+	return func(c *Client) {
+		c.closed = closed
+	}
+}
+
+type queuedCommand struct {
+	cmd  *redisCommand
+	args []protocol.Resp
+}
+
+type watchedKey struct {
+	db  *datastore.Datastore
+	key string
+}
+
+// NewClient returns a client of the databases, with the database 0 selected.
+func NewClient(dbs *Databases, opts ...ClientOption) *Client {
+	c := &Client{dbs: dbs}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// DB returns the index of the selected database.
+func (c *Client) DB() int {
+	return c.db
+}
+
+// Close releases the keys watched by the client once its connection is closed.
+func (c *Client) Close() {
+	c.unwatch()
+}
+
+// lookupCommand returns the command of the lowercase name, or nil if it is unknown.
+func (c *Client) lookupCommand(name string) *redisCommand {
+	if cmd, ok := c.commands[name]; ok {
+		return cmd
+	}
+	return commandTable[name]
+}
+
+// subscribed reports whether the client is in subscriber mode.
+func (c *Client) subscribed() bool {
+	return c.subscriptions != nil && c.subscriptions() > 0
+}
+
+// selected returns the selected database.
+func (c *Client) selected() *datastore.Datastore {
+	return c.dbs.all[c.db]
+}
+
+// database returns the database at index, or nil if it is out of range.
+func (c *Client) database(index int64) *datastore.Datastore {
+	if index < 0 || index >= int64(len(c.dbs.all)) {
+		return nil
+	}
+	return c.dbs.all[index]
+}
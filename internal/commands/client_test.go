@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func newDatabases(n int) *Databases {
+	dbs := make([]*datastore.Datastore, n)
+	for i := range dbs {
+		dbs[i] = datastore.NewDatastore()
+	}
+	return NewDatabases(dbs...)
+}
+
+func TestSelect(t *testing.T) {
+	dbs := newDatabases(16)
+	c, other := NewClient(dbs), NewClient(dbs)
+	outOfRange := protocol.Error{Data: "ERR DB index is out of range"}
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "db0"), protocol.SimpleString{Data: "OK"}},
+		{command("SELECT", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("GET", "k"), protocol.BulkString{Data: nil}},
+		{command("SET", "k", "db1"), protocol.SimpleString{Data: "OK"}},
+		{command("SELECT", "16"), outOfRange},
+		{command("SELECT", "-1"), outOfRange},
+		{command("SELECT", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("SELECT"), wrongNumberOfArgs("select")},
+		{command("GET", "k"), bulkString("db1")},
+	})
+	if c.DB() != 1 {
+		t.Errorf("Expected the database 1 to be selected, got %d", c.DB())
+	}
+	runClientSequence(t, other, []step{
+		{command("GET", "k"), bulkString("db0")},
+	})
+}
+
+func TestCopyAndMoveAcrossDatabases(t *testing.T) {
+	c := NewClient(newDatabases(16))
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "v", "EX", "100"), protocol.SimpleString{Data: "OK"}},
+		{command("COPY", "k", "k", "DB", "1"), protocol.Integer{Value: 1}},
+		{command("COPY", "k", "k", "DB", "1"), protocol.Integer{Value: 0}},
+		{command("COPY", "k", "k", "DB", "16"), protocol.Error{Data: "ERR DB index is out of range"}},
+		{command("MOVE", "k", "1"), protocol.Integer{Value: 0}},
+		{command("MOVE", "k", "2"), protocol.Integer{Value: 1}},
+		{command("MOVE", "k", "0"), errorReply(datastore.ErrSameObject)},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+		{command("SELECT", "2"), protocol.SimpleString{Data: "OK"}},
+		{command("GET", "k"), bulkString("v")},
+		{command("TTL", "k"), protocol.Integer{Value: 100}},
+		{command("SELECT", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("TTL", "k"), protocol.Integer{Value: 100}},
+	})
+}
+
+func TestSwapDB(t *testing.T) {
+	dbs := newDatabases(16)
+	c, other := NewClient(dbs), NewClient(dbs)
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "db0"), protocol.SimpleString{Data: "OK"}},
+		{command("SWAPDB", "0", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("GET", "k"), protocol.BulkString{Data: nil}},
+		{command("SWAPDB", "0", "0"), protocol.SimpleString{Data: "OK"}},
+		{command("SWAPDB", "x", "0"), protocol.Error{Data: "ERR invalid first DB index"}},
+		{command("SWAPDB", "0", "x"), protocol.Error{Data: "ERR invalid second DB index"}},
+		{command("SWAPDB", "0", "16"), protocol.Error{Data: "ERR DB index is out of range"}},
+		{command("SWAPDB", "0"), wrongNumberOfArgs("swapdb")},
+	})
+	// Clients that selected the database 1 see the keys of the database 0.
+	runClientSequence(t, other, []step{
+		{command("SELECT", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("GET", "k"), bulkString("db0")},
+	})
+}
+
+func TestFlushDBAndFlushAll(t *testing.T) {
+	c := NewClient(newDatabases(16))
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("SELECT", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("SET", "k", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("FLUSHDB", "ASYNC"), protocol.SimpleString{Data: "OK"}},
+		{command("DBSIZE"), protocol.Integer{Value: 0}},
+		{command("SELECT", "0"), protocol.SimpleString{Data: "OK"}},
+		{command("DBSIZE"), protocol.Integer{Value: 1}},
+		{command("FLUSHDB", "FOO"), errorReply(errSyntax)},
+		{command("FLUSHALL", "SYNC", "ASYNC"), errorReply(errSyntax)},
+		{command("SELECT", "2"), protocol.SimpleString{Data: "OK"}},
+		{command("SET", "k", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("FLUSHALL", "sync"), protocol.SimpleString{Data: "OK"}},
+		{command("DBSIZE"), protocol.Integer{Value: 0}},
+		{command("SELECT", "0"), protocol.SimpleString{Data: "OK"}},
+		{command("DBSIZE"), protocol.Integer{Value: 0}},
+		{command("FLUSHDB"), protocol.SimpleString{Data: "OK"}},
+	})
+}
+
+func TestClientCommands(t *testing.T) {
+	subscriptions := 0
+	c := NewClient(newDatabases(1),
+		WithCommands(map[string]Command{
+			"subscribe": {Handler: func(args []protocol.Resp) protocol.Resp {
+				subscriptions++
+				return protocol.Integer{Value: int64(subscriptions)}
+			}, Arity: -2},
+			"get": {Handler: func(args []protocol.Resp) protocol.Resp {
+				return bulkString("overridden")
+			}, Arity: 2},
+		}),
+		WithSubscriptions(func() int { return subscriptions }))
+	notAllowed := func(name string) protocol.Error {
+		return protocol.Error{Data: "ERR Can't execute '" + name + "': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"}
+	}
+	runClientSequence(t, c, []step{
+		{command("GET", "k"), bulkString("overridden")},
+		{command("PING"), protocol.SimpleString{Data: "PONG"}},
+		{command("SUBSCRIBE"), wrongNumberOfArgs("subscribe")},
+		{command("MULTI"), protocol.SimpleString{Data: "OK"}},
+		{command("SUBSCRIBE", "a"), protocol.Error{Data: "ERR Command not allowed inside a transaction"}},
+		{command("EXEC"), errExecAbort},
+		{command("SUBSCRIBE", "a"), protocol.Integer{Value: 1}},
+		{command("GET", "k"), notAllowed("get")},
+		{command("SET", "k", "v"), notAllowed("set")},
+		{command("PING"), bulkStringArray([]string{"pong", ""})},
+		{command("PING", "hello"), bulkStringArray([]string{"pong", "hello"})},
+		{command("SUBSCRIBE", "b"), protocol.Integer{Value: 2}},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"strconv"
 	"strings"
 
@@ -11,41 +12,60 @@ import (
 	"github.com/dimitrovvlado/redis-server/internal/protocol"
 )
 
-func HandleCommand(resp protocol.Resp, ds *datastore.Datastore) (protocol.Resp, error) {
-	switch resp.(type) {
-	case protocol.Array:
-		a := resp.(protocol.Array)
-		cmd := (a.Items[0]).(protocol.BulkString)
-		cmdS := strings.ToLower(protocol.Val(cmd.Data))
-		args := (a.Items)[1:]
-		switch cmdS {
-		case "ping":
-			return handlePingCommand(args), nil
-		case "echo":
-			return handleEchoCommand(args), nil
-		case "set":
-			return handleSetCommand(args, ds), nil
-		case "get":
-			return handleGetCommand(args, ds), nil
-		case "del":
-			return handleDelCommand(args, ds), nil
-		case "exists":
-			return handleExistsCommand(args, ds), nil
-		case "incr":
-			return handleIncrCommand(args, ds), nil
-		case "decr":
-			return handleDecrCommand(args, ds), nil
-		default:
-			return handleUnknownCommand(cmdS, args), nil
-		}
-	default:
+// HandleCommand executes the command on behalf of the client, against its selected database.
+// Within a transaction, the command is queued until EXEC.
+func HandleCommand(resp protocol.Resp, c *Client) (protocol.Resp, error) {
+	a, ok := resp.(protocol.Array)
+	if !ok || len(a.Items) == 0 {
 		slog.Warn("Unexpected RESP type")
+		return nil, errors.New("unexpected RESP type")
+	}
+	name := strings.ToLower(a.Items[0].String())
+	args := a.Items[1:]
+	cmd := c.lookupCommand(name)
+	if cmd == nil {
+		c.abortMulti()
+		return handleUnknownCommand(name, args), nil
+	}
+	if !cmd.validArity(len(a.Items)) {
+		c.abortMulti()
+		return wrongNumberOfArgs(name), nil
+	}
+	if c.subscribed() && !subscriberCommands[name] {
+		c.abortMulti()
+		return protocol.Error{Data: fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name)}, nil
+	}
+	if cmd.flags&flagTransaction != 0 {
+		return cmd.handler(args, c), nil
 	}
-	return nil, errors.New("unexpected RESP type")
+	if c.multi && subscriptionCommands[name] {
+		c.abortMulti()
+		return protocol.Error{Data: "ERR Command not allowed inside a transaction"}, nil
+	}
+	if c.multi {
+		c.queued = append(c.queued, queuedCommand{cmd: cmd, args: args})
+		return protocol.SimpleString{Data: "QUEUED"}, nil
+	}
+	c.dbs.mu.RLock()
+	reply := cmd.handler(args, c)
+	c.dbs.serveBlocked()
+	c.dbs.mu.RUnlock()
+	if c.blocked != nil {
+		return c.waitUnblocked(), nil
+	}
+	return reply, nil
 }
 
-func handlePingCommand(args []protocol.Resp) protocol.Resp {
+func handlePingCommand(args []protocol.Resp, c *Client) protocol.Resp {
 	len := len(args)
+	if len <= 1 && c.subscribed() {
+		// In subscriber mode, the reply is formatted like the pushed messages.
+		message := ""
+		if len == 1 {
+			message = args[0].String()
+		}
+		return bulkStringArray([]string{"pong", message})
+	}
 	if len == 0 {
 		return protocol.SimpleString{Data: "PONG"}
 	} else if len == 1 {
@@ -71,44 +91,26 @@ func handleUnknownCommand(c string, args []protocol.Resp) protocol.Resp {
 }
 
 func handleSetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
-	len := len(args)
-
-	if len >= 2 {
-		key := args[0].String()
-		val := args[1].String()
-		if len == 2 {
-			ds.Set(key, val)
-			return protocol.SimpleString{Data: "OK"}
-		} else if len == 4 {
-			expMode := strings.ToUpper(args[2].String())
-			exp, err := strconv.ParseInt(args[3].String(), 10, 64)
-			if err != nil || exp <= 0 {
-				return protocol.Error{Data: "ERR value is not an integer or out of range"}
-			}
-			switch expMode {
-			case "EX":
-				//Set the specified expire time, in seconds
-				ds.SetWithExpiry(key, val, exp*1000)
-				return protocol.SimpleString{Data: "OK"}
-			case "PX":
-				//Set the specified expire time, in milliseconds
-				ds.SetWithExpiry(key, val, exp)
-				return protocol.SimpleString{Data: "OK"}
-			case "EXAT":
-				//Set the specified Unix time at which the key will expire, in seconds
-				ds.SetWithExactExpiry(key, val, exp*1000)
-				return protocol.SimpleString{Data: "OK"}
-			case "PXAT":
-				//Set the specified Unix time at which the key will expire, in milliseconds
-				ds.SetWithExactExpiry(key, val, exp)
-				return protocol.SimpleString{Data: "OK"}
-			default:
-				return protocol.Error{Data: "ERR syntax error"}
-			}
-		}
+	if len(args) < 2 {
+		return wrongNumberOfArgs("set")
 	}
-
-	return protocol.Error{Data: "ERR wrong number of arguments for 'set' command"}
+	opts, err := parseStringOptions("set", args[2:], false)
+	if err != nil {
+		return errorReply(err)
+	}
+	old, ok, err := ds.SetWithOptions(args[0].String(), args[1].String(), opts)
+	if errors.Is(err, datastore.ErrInvalidExpireTime) {
+		return errorReply(errInvalidExpireTime("set"))
+	} else if err != nil {
+		return errorReply(err)
+	}
+	switch {
+	case opts.Get:
+		return protocol.BulkString{Data: old}
+	case !ok:
+		return protocol.BulkString{Data: nil}
+	}
+	return protocol.SimpleString{Data: "OK"}
 }
 
 func handleGetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
@@ -117,7 +119,9 @@ func handleGetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Re
 	}
 	key := args[0].String()
 	val, err := ds.Get(key)
-	if err != nil {
+	if errors.Is(err, datastore.ErrWrongType) {
+		return errorReply(err)
+	} else if err != nil {
 		return protocol.BulkString{Data: nil}
 	}
 	return protocol.BulkString{Data: protocol.Ptr(fmt.Sprintf("%s", val))}
@@ -142,7 +146,7 @@ func handleExistsCommand(args []protocol.Resp, ds *datastore.Datastore) protocol
 	}
 	var cnt int64
 	for _, k := range args {
-		if _, err := ds.Get(k.String()); err == nil {
+		if ds.Exists(k.String()) {
 			cnt += 1
 		}
 	}
@@ -156,7 +160,7 @@ func handleIncrCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.R
 	key := args[0].String()
 	v, err := ds.Increment(key)
 	if err != nil {
-		return protocol.Error{Data: "ERR value is not an integer or out of range"}
+		return errorReply(err)
 	}
 	return protocol.Integer{Value: v}
 }
@@ -168,7 +172,107 @@ func handleDecrCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.R
 	key := args[0].String()
 	v, err := ds.Decrement(key)
 	if err != nil {
-		return protocol.Error{Data: "ERR value is not an integer or out of range"}
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: v}
+}
+
+func handleIncrByCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("incrby")
+	}
+	delta, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	v, err := ds.IncrementBy(args[0].String(), delta)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: v}
+}
+
+func handleDecrByCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("decrby")
+	}
+	delta, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	if delta == math.MinInt64 {
+		return protocol.Error{Data: "ERR decrement would overflow"}
+	}
+	v, err := ds.IncrementBy(args[0].String(), -delta)
+	if err != nil {
+		return errorReply(err)
 	}
 	return protocol.Integer{Value: v}
 }
+
+func handleIncrByFloatCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("incrbyfloat")
+	}
+	v, err := ds.IncrementByFloat(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(v)
+}
+
+func wrongNumberOfArgs(cmd string) protocol.Resp {
+	return protocol.Error{Data: fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd)}
+}
+
+func errorReply(err error) protocol.Resp {
+	return protocol.Error{Data: err.Error()}
+}
+
+func bulkString(s string) protocol.Resp {
+	return protocol.BulkString{Data: protocol.Ptr(s)}
+}
+
+func bulkStringArray(values []string) protocol.Array {
+	items := make([]protocol.Resp, len(values))
+	for i, v := range values {
+		items[i] = bulkString(v)
+	}
+	return protocol.Array{Items: items}
+}
+
+func parseInt(arg protocol.Resp) (int64, error) {
+	v, err := strconv.ParseInt(arg.String(), 10, 64)
+	if err != nil {
+		return 0, datastore.ErrNotInteger
+	}
+	return v, nil
+}
+
+var (
+	errNotFloat = datastore.ErrNotFloat
+	errSyntax   = errors.New("ERR syntax error")
+)
+
+func parseFloat(arg protocol.Resp) (float64, error) {
+	v, err := strconv.ParseFloat(arg.String(), 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errNotFloat
+	}
+	return v, nil
+}
+
+func stringArgs(args []protocol.Resp) []string {
+	ret := make([]string, len(args))
+	for i, a := range args {
+		ret[i] = a.String()
+	}
+	return ret
+}
+
+func boolInteger(b bool) protocol.Resp {
+	if b {
+		return protocol.Integer{Value: 1}
+	}
+	return protocol.Integer{Value: 0}
+}
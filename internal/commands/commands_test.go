@@ -57,7 +57,7 @@ func TestHandleCommand(t *testing.T) {
 				protocol.BulkString{Data: protocol.Ptr("v")},
 				protocol.BulkString{Data: protocol.Ptr("EX")},
 			}},
-			expected: protocol.Error{Data: "ERR wrong number of arguments for 'set' command"},
+			expected: protocol.Error{Data: "ERR syntax error"},
 		},
 		"Set PX Error": {
 			in: protocol.Array{Items: []protocol.Resp{
@@ -66,7 +66,7 @@ func TestHandleCommand(t *testing.T) {
 				protocol.BulkString{Data: protocol.Ptr("v")},
 				protocol.BulkString{Data: protocol.Ptr("PX")},
 			}},
-			expected: protocol.Error{Data: "ERR wrong number of arguments for 'set' command"},
+			expected: protocol.Error{Data: "ERR syntax error"},
 		},
 		"Set Invalid Expiry Type": {
 			in: protocol.Array{Items: []protocol.Resp{
@@ -95,7 +95,7 @@ func TestHandleCommand(t *testing.T) {
 				protocol.BulkString{Data: protocol.Ptr("v")},
 				protocol.BulkString{Data: protocol.Ptr("EXAT")},
 			}},
-			expected: protocol.Error{Data: "ERR wrong number of arguments for 'set' command"},
+			expected: protocol.Error{Data: "ERR syntax error"},
 		},
 		"Set PXAT Error": {
 			in: protocol.Array{Items: []protocol.Resp{
@@ -104,7 +104,7 @@ func TestHandleCommand(t *testing.T) {
 				protocol.BulkString{Data: protocol.Ptr("v")},
 				protocol.BulkString{Data: protocol.Ptr("PXAT")},
 			}},
-			expected: protocol.Error{Data: "ERR wrong number of arguments for 'set' command"},
+			expected: protocol.Error{Data: "ERR syntax error"},
 		},
 		"Set Invalid EXAT Expiry Value": {
 			in: protocol.Array{Items: []protocol.Resp{
@@ -114,13 +114,13 @@ func TestHandleCommand(t *testing.T) {
 				protocol.BulkString{Data: protocol.Ptr("EXAT")},
 				protocol.BulkString{Data: protocol.Ptr("-1")},
 			}},
-			expected: protocol.Error{Data: "ERR value is not an integer or out of range"},
+			expected: protocol.Error{Data: "ERR invalid expire time in 'set' command"},
 		},
 	}
 	ds := datastore.NewDatastore()
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := HandleCommand(test.in, ds)
+			got, err := HandleCommand(test.in, NewClient(NewDatabases(ds)))
 			if err != nil {
 				t.Errorf("HandleCommand() error = %v", err)
 			}
@@ -167,7 +167,7 @@ func TestExistsCommand(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := HandleCommand(test.in, ds)
+			got, err := HandleCommand(test.in, NewClient(NewDatabases(ds)))
 			if err != nil {
 				t.Errorf("HandleCommand() error = %v", err)
 			}
@@ -223,7 +223,7 @@ func TestDeleteCommand(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := HandleCommand(test.in, ds)
+			got, err := HandleCommand(test.in, NewClient(NewDatabases(ds)))
 			if err != nil {
 				t.Errorf("HandleCommand() error = %v", err)
 			}
@@ -283,7 +283,7 @@ func TestIncrCommand(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := HandleCommand(test.in, ds)
+			got, err := HandleCommand(test.in, NewClient(NewDatabases(ds)))
 			if err != nil {
 				t.Errorf("handleIncrCommand() error = %v", err)
 			}
@@ -343,7 +343,7 @@ func TestDecrCommand(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			got, err := HandleCommand(test.in, ds)
+			got, err := HandleCommand(test.in, NewClient(NewDatabases(ds)))
 			if err != nil {
 				t.Errorf("handleIncrCommand() error = %v", err)
 			}
@@ -353,3 +353,114 @@ func TestDecrCommand(t *testing.T) {
 		})
 	}
 }
+
+// command builds a RESP array of bulk strings, as sent by a client.
+func command(args ...string) protocol.Array {
+	items := make([]protocol.Resp, len(args))
+	for i, a := range args {
+		items[i] = protocol.BulkString{Data: protocol.Ptr(a)}
+	}
+	return protocol.Array{Items: items}
+}
+
+// runSequence executes the steps in order against the same datastore.
+func runSequence(t *testing.T, ds *datastore.Datastore, steps []step) {
+	t.Helper()
+	runClientSequence(t, NewClient(NewDatabases(ds)), steps)
+}
+
+// runClientSequence executes the steps in order on behalf of the same client.
+func runClientSequence(t *testing.T, c *Client, steps []step) {
+	t.Helper()
+	for _, s := range steps {
+		got, err := HandleCommand(s.in, c)
+		if err != nil {
+			t.Errorf("%s: HandleCommand() error = %v", s.in, err)
+		}
+		if !reflect.DeepEqual(got, s.expected) {
+			t.Errorf("%s: expected: %v, got: %v", s.in, s.expected, got)
+		}
+	}
+}
+
+type step struct {
+	in       protocol.Resp
+	expected protocol.Resp
+}
+
+func TestIncrByCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	overflow := protocol.Error{Data: "ERR increment or decrement would overflow"}
+	runSequence(t, ds, []step{
+		{command("INCRBY", "k"), wrongNumberOfArgs("incrby")},
+		{command("INCRBY", "k", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("INCRBY", "k", "10"), protocol.Integer{Value: 10}},
+		{command("DECRBY", "k", "15"), protocol.Integer{Value: -5}},
+		{command("INCRBY", "k", "9223372036854775807"), protocol.Integer{Value: 9223372036854775802}},
+		{command("INCRBY", "k", "6"), overflow},
+		{command("DECRBY", "k", "-6"), overflow},
+		{command("SET", "min", "-9223372036854775808"), protocol.SimpleString{Data: "OK"}},
+		{command("DECR", "min"), overflow},
+		{command("DECRBY", "k", "-9223372036854775808"), protocol.Error{Data: "ERR decrement would overflow"}},
+		{command("SET", "s", "one"), protocol.SimpleString{Data: "OK"}},
+		{command("INCRBY", "s", "1"), errorReply(datastore.ErrNotInteger)},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("DECRBY", "list", "1"), wrongType},
+	})
+}
+
+func TestIncrByFloatCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("INCRBYFLOAT", "k"), wrongNumberOfArgs("incrbyfloat")},
+		{command("SET", "k", "10.50"), protocol.SimpleString{Data: "OK"}},
+		{command("INCRBYFLOAT", "k", "0.1"), bulkString("10.6")},
+		{command("INCRBYFLOAT", "k", "-5"), bulkString("5.6")},
+		{command("SET", "k", "5.0e3"), protocol.SimpleString{Data: "OK"}},
+		{command("INCRBYFLOAT", "k", "2.0e2"), bulkString("5200")},
+		{command("INCR", "k"), protocol.Integer{Value: 5201}},
+		{command("INCRBYFLOAT", "k", "abc"), protocol.Error{Data: "ERR value is not a valid float"}},
+		{command("INCRBYFLOAT", "k", "+inf"), protocol.Error{Data: "ERR increment would produce NaN or Infinity"}},
+		{command("INCRBYFLOAT", "new", "1e-3"), bulkString("0.001")},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("INCRBYFLOAT", "list", "1"), wrongType},
+	})
+}
+
+func TestSetOptions(t *testing.T) {
+	ds := datastore.NewDatastore()
+	ok := protocol.SimpleString{Data: "OK"}
+	nilBulk := protocol.BulkString{Data: nil}
+	runSequence(t, ds, []step{
+		{command("SET", "lock", "token", "NX", "PX", "10000"), ok},
+		{command("SET", "lock", "other", "NX", "PX", "10000"), nilBulk},
+		{command("GET", "lock"), bulkString("token")},
+		{command("SET", "lock", "other", "XX", "GET", "KEEPTTL"), bulkString("token")},
+		{command("GET", "lock"), bulkString("other")},
+		{command("SET", "missing", "v", "XX"), nilBulk},
+		{command("SET", "missing", "v", "XX", "GET"), nilBulk},
+		{command("EXISTS", "missing"), protocol.Integer{Value: 0}},
+		{command("SET", "new", "v", "NX", "GET"), nilBulk},
+		{command("SET", "new", "w", "NX", "GET"), bulkString("v")},
+		{command("SET", "new", "w", "GET"), bulkString("v")},
+		{command("SET", "new", "v", "nx", "nx", "ex", "10", "ex", "20"), nilBulk},
+		{command("SET", "k", "v", "NX", "XX"), errorReply(errSyntax)},
+		{command("SET", "k", "v", "XX", "NX"), errorReply(errSyntax)},
+		{command("SET", "k", "v", "EX", "10", "PX", "100"), errorReply(errSyntax)},
+		{command("SET", "k", "v", "KEEPTTL", "EX", "10"), errorReply(errSyntax)},
+		{command("SET", "k", "v", "PXAT", "10", "KEEPTTL"), errorReply(errSyntax)},
+		{command("SET", "k", "v", "PERSIST"), errorReply(errSyntax)},
+		{command("SET", "k", "v", "EX", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("SET", "k", "v", "EX", "0"), protocol.Error{Data: "ERR invalid expire time in 'set' command"}},
+		{command("SET", "k", "v", "EX", "9223372036854775807"), protocol.Error{Data: "ERR invalid expire time in 'set' command"}},
+		{command("SET", "k", "v", "PX", "9223372036854775807"), protocol.Error{Data: "ERR invalid expire time in 'set' command"}},
+		{command("SET", "k", "v", "EXAT", "1"), ok},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("SET", "list", "v", "GET"), wrongType},
+		{command("SET", "list", "v"), ok},
+		{command("GET", "list"), bulkString("v")},
+		{command("GETEX", "list", "PERSIST", "KEEPTTL"), errorReply(errSyntax)},
+		{command("GETEX", "list", "EX", "10", "EX", "20"), bulkString("v")},
+	})
+}
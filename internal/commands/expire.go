@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleExpireCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleExpire("expire", args, datastore.ExpiryAfter, 1000, ds)
+}
+
+func handlePExpireCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleExpire("pexpire", args, datastore.ExpiryAfter, 1, ds)
+}
+
+func handleExpireAtCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleExpire("expireat", args, datastore.ExpiryAt, 1000, ds)
+}
+
+func handlePExpireAtCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleExpire("pexpireat", args, datastore.ExpiryAt, 1, ds)
+}
+
+// handleExpire sets the expiration of a key to a time in units of multiplier milliseconds,
+// relative to now or absolute depending on the mode.
+func handleExpire(cmd string, args []protocol.Resp, mode datastore.ExpiryMode, multiplier int64, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	var opts datastore.ExpireOptions
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(arg.String()) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		default:
+			return protocol.Error{Data: fmt.Sprintf("ERR Unsupported option %s", arg.String())}
+		}
+	}
+	if opts.NX && (opts.XX || opts.GT || opts.LT) {
+		return protocol.Error{Data: "ERR NX and XX, GT or LT options at the same time are not compatible"}
+	}
+	if opts.GT && opts.LT {
+		return protocol.Error{Data: "ERR GT and LT options at the same time are not compatible"}
+	}
+	v, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	if v > math.MaxInt64/multiplier || v < math.MinInt64/multiplier {
+		return errorReply(errInvalidExpireTime(cmd))
+	}
+	ok, err := ds.Expire(args[0].String(), datastore.Expiry{Mode: mode, Millis: v * multiplier}, opts)
+	if errors.Is(err, datastore.ErrInvalidExpireTime) {
+		return errorReply(errInvalidExpireTime(cmd))
+	} else if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(ok)
+}
+
+func handleTTLCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("ttl")
+	}
+	return protocol.Integer{Value: toSeconds(ds.TTL(args[0].String()))}
+}
+
+func handlePTTLCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("pttl")
+	}
+	return protocol.Integer{Value: ds.TTL(args[0].String())}
+}
+
+func handleExpireTimeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("expiretime")
+	}
+	return protocol.Integer{Value: toSeconds(ds.ExpireTime(args[0].String()))}
+}
+
+func handlePExpireTimeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("pexpiretime")
+	}
+	return protocol.Integer{Value: ds.ExpireTime(args[0].String())}
+}
+
+func handlePersistCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("persist")
+	}
+	return boolInteger(ds.Persist(args[0].String()))
+}
+
+// toSeconds rounds milliseconds to the closest second, keeping the negative special values.
+func toSeconds(ms int64) int64 {
+	if ms < 0 {
+		return ms
+	}
+	return (ms + 500) / 1000
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestExpireCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	at := strconv.FormatInt(time.Now().Unix()+1000, 10)
+	runSequence(t, ds, []step{
+		{command("EXPIRE", "k"), wrongNumberOfArgs("expire")},
+		{command("EXPIRE", "missing", "100"), protocol.Integer{Value: 0}},
+		{command("TTL", "missing"), protocol.Integer{Value: -2}},
+		{command("PTTL", "missing"), protocol.Integer{Value: -2}},
+		{command("EXPIRETIME", "missing"), protocol.Integer{Value: -2}},
+		{command("SET", "k", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("TTL", "k"), protocol.Integer{Value: -1}},
+		{command("PEXPIRETIME", "k"), protocol.Integer{Value: -1}},
+		{command("EXPIRE", "k", "100", "XX"), protocol.Integer{Value: 0}},
+		{command("EXPIRE", "k", "100", "GT"), protocol.Integer{Value: 0}},
+		{command("EXPIRE", "k", "100", "LT"), protocol.Integer{Value: 1}},
+		{command("TTL", "k"), protocol.Integer{Value: 100}},
+		{command("EXPIRE", "k", "200", "NX"), protocol.Integer{Value: 0}},
+		{command("EXPIRE", "k", "50", "GT"), protocol.Integer{Value: 0}},
+		{command("EXPIRE", "k", "200", "XX", "GT"), protocol.Integer{Value: 1}},
+		{command("TTL", "k"), protocol.Integer{Value: 200}},
+		{command("EXPIREAT", "k", at), protocol.Integer{Value: 1}},
+		{command("EXPIRETIME", "k"), integerOf(at)},
+		{command("PEXPIREAT", "k", at+"000"), protocol.Integer{Value: 1}},
+		{command("PEXPIRETIME", "k"), integerOf(at + "000")},
+		{command("PERSIST", "k"), protocol.Integer{Value: 1}},
+		{command("PERSIST", "k"), protocol.Integer{Value: 0}},
+		{command("PERSIST", "missing"), protocol.Integer{Value: 0}},
+		{command("TTL", "k"), protocol.Integer{Value: -1}},
+		{command("PEXPIRE", "k", "-1"), protocol.Integer{Value: 1}},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+	})
+}
+
+func TestExpireErrors(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SET", "k", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("EXPIRE", "k", "100", "FOO"), protocol.Error{Data: "ERR Unsupported option FOO"}},
+		{command("EXPIRE", "k", "100", "NX", "XX"), protocol.Error{Data: "ERR NX and XX, GT or LT options at the same time are not compatible"}},
+		{command("EXPIRE", "k", "100", "GT", "LT"), protocol.Error{Data: "ERR GT and LT options at the same time are not compatible"}},
+		{command("EXPIRE", "k", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("EXPIRE", "k", "9223372036854775807"), protocol.Error{Data: "ERR invalid expire time in 'expire' command"}},
+		{command("PEXPIRE", "k", "9223372036854775807"), protocol.Error{Data: "ERR invalid expire time in 'pexpire' command"}},
+		{command("TTL", "k"), protocol.Integer{Value: -1}},
+	})
+}
+
+// TestTTLIsKept checks which writes keep the expiration of a key, like Redis does.
+func TestTTLIsKept(t *testing.T) {
+	ds := datastore.NewDatastore()
+	ttl := protocol.Integer{Value: 100}
+	noTTL := protocol.Integer{Value: -1}
+	runSequence(t, ds, []step{
+		{command("SET", "n", "1", "EX", "100"), protocol.SimpleString{Data: "OK"}},
+		{command("INCR", "n"), protocol.Integer{Value: 2}},
+		{command("INCRBY", "n", "2"), protocol.Integer{Value: 4}},
+		{command("DECR", "n"), protocol.Integer{Value: 3}},
+		{command("INCRBYFLOAT", "n", "0.5"), bulkString("3.5")},
+		{command("APPEND", "n", "1"), protocol.Integer{Value: 4}},
+		{command("SETRANGE", "n", "0", "4"), protocol.Integer{Value: 4}},
+		{command("SETBIT", "n", "0", "1"), protocol.Integer{Value: 0}},
+		{command("TTL", "n"), ttl},
+		{command("SET", "n", "1", "KEEPTTL"), protocol.SimpleString{Data: "OK"}},
+		{command("TTL", "n"), ttl},
+		{command("SET", "n", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("TTL", "n"), noTTL},
+		{command("RPUSH", "l", "a"), protocol.Integer{Value: 1}},
+		{command("EXPIRE", "l", "100"), protocol.Integer{Value: 1}},
+		{command("RPUSH", "l", "b"), protocol.Integer{Value: 2}},
+		{command("TTL", "l"), ttl},
+		{command("GETSET", "n", "2"), bulkString("1")},
+		{command("EXPIRE", "n", "100"), protocol.Integer{Value: 1}},
+		{command("GETSET", "n", "3"), bulkString("2")},
+		{command("TTL", "n"), noTTL},
+		{command("EXPIRE", "n", "100"), protocol.Integer{Value: 1}},
+		{command("MSET", "n", "4"), protocol.SimpleString{Data: "OK"}},
+		{command("TTL", "n"), noTTL},
+	})
+}
+
+// integerOf is the integer reply of a decimal string.
+func integerOf(s string) protocol.Resp {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return protocol.Integer{Value: v}
+}
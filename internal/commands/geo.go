@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var errGeoUnit = errors.New("ERR unsupported unit provided. please use M, KM, FT, MI")
+
+func handleGeoAddCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 4 {
+		return wrongNumberOfArgs("geoadd")
+	}
+	var opts datastore.ZAddOptions
+	var ch bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].String()) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "CH":
+			ch = true
+		default:
+			break options
+		}
+	}
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 || (opts.NX && opts.XX) {
+		return errorReply(errSyntax)
+	}
+	members := make([]datastore.GeoMember, 0, len(triples)/3)
+	for j := 0; j < len(triples); j += 3 {
+		p, err := parseGeoPoint(triples[j], triples[j+1])
+		if err != nil {
+			return errorReply(err)
+		}
+		members = append(members, datastore.GeoMember{Member: triples[j+2].String(), GeoPoint: p})
+	}
+	added, updated, err := ds.GeoAdd(args[0].String(), opts, members)
+	if err != nil {
+		return errorReply(err)
+	}
+	if ch {
+		return protocol.Integer{Value: added + updated}
+	}
+	return protocol.Integer{Value: added}
+}
+
+func handleGeoDistCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs("geodist")
+	}
+	conversion := 1.0
+	switch len(args) {
+	case 3:
+	case 4:
+		var err error
+		if conversion, err = parseGeoUnit(args[3]); err != nil {
+			return errorReply(err)
+		}
+	default:
+		return errorReply(errSyntax)
+	}
+	distance, ok, err := ds.GeoDist(args[0].String(), args[1].String(), args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	if !ok {
+		return protocol.BulkString{Data: nil}
+	}
+	return geoDistanceReply(distance / conversion)
+}
+
+func handleGeoPosCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("geopos")
+	}
+	pos, err := ds.GeoPos(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(pos))
+	for i, p := range pos {
+		if p == nil {
+			items[i] = protocol.Array{Items: nil}
+		} else {
+			items[i] = geoPointReply(*p)
+		}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleGeoHashCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("geohash")
+	}
+	hashes, err := ds.GeoHash(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(hashes))
+	for i, h := range hashes {
+		items[i] = protocol.BulkString{Data: h}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleGeoSearchCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 6 {
+		return wrongNumberOfArgs("geosearch")
+	}
+	opts, err := parseGeoSearch("GEOSEARCH", args[1:], false)
+	if err != nil {
+		return errorReply(err)
+	}
+	results, err := ds.GeoSearch(args[0].String(), opts.query)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(results))
+	for i, r := range results {
+		if !opts.withDist && !opts.withHash && !opts.withCoord {
+			items[i] = bulkString(r.Member)
+			continue
+		}
+		item := []protocol.Resp{bulkString(r.Member)}
+		if opts.withDist {
+			item = append(item, geoDistanceReply(r.Distance))
+		}
+		if opts.withHash {
+			item = append(item, protocol.Integer{Value: int64(r.Hash)})
+		}
+		if opts.withCoord {
+			item = append(item, geoPointReply(r.GeoPoint))
+		}
+		items[i] = protocol.Array{Items: item}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleGeoSearchStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 7 {
+		return wrongNumberOfArgs("geosearchstore")
+	}
+	opts, err := parseGeoSearch("GEOSEARCHSTORE", args[2:], true)
+	if err != nil {
+		return errorReply(err)
+	}
+	n, err := ds.GeoSearchStore(args[0].String(), args[1].String(), opts.query, opts.storeDist)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+type geoSearchOptions struct {
+	query                         datastore.GeoQuery
+	withDist, withHash, withCoord bool
+	storeDist                     bool
+}
+
+// parseGeoSearch parses the options of GEOSEARCH and GEOSEARCHSTORE following the source key.
+func parseGeoSearch(cmd string, args []protocol.Resp, store bool) (geoSearchOptions, error) {
+	var opts geoSearchOptions
+	q := &opts.query
+	var fromMember, fromLonLat, byRadius, byBox bool
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		var err error
+		switch arg := strings.ToUpper(args[i].String()); {
+		case arg == "WITHDIST":
+			opts.withDist = true
+		case arg == "WITHHASH":
+			opts.withHash = true
+		case arg == "WITHCOORD":
+			opts.withCoord = true
+		case arg == "ANY":
+			q.Any = true
+		case arg == "ASC":
+			q.Sort = datastore.GeoSortAsc
+		case arg == "DESC":
+			q.Sort = datastore.GeoSortDesc
+		case arg == "STOREDIST" && store:
+			opts.storeDist = true
+		case arg == "COUNT" && remaining > 0:
+			if q.Count, err = parseInt(args[i+1]); err != nil {
+				return opts, err
+			}
+			if q.Count <= 0 {
+				return opts, errors.New("ERR COUNT must be > 0")
+			}
+			i++
+		case arg == "FROMMEMBER" && remaining > 0:
+			if fromMember || fromLonLat {
+				return opts, errSyntax
+			}
+			fromMember = true
+			q.FromMember, q.Member = true, args[i+1].String()
+			i++
+		case arg == "FROMLONLAT" && remaining > 1:
+			if fromMember || fromLonLat {
+				return opts, errSyntax
+			}
+			fromLonLat = true
+			if q.Center, err = parseGeoPoint(args[i+1], args[i+2]); err != nil {
+				return opts, err
+			}
+			i += 2
+		case arg == "BYRADIUS" && remaining > 1:
+			if byRadius || byBox {
+				return opts, errSyntax
+			}
+			byRadius = true
+			if q.Radius, err = parseGeoLength(args[i+1], "radius"); err != nil {
+				return opts, err
+			}
+			if q.Radius < 0 {
+				return opts, errors.New("ERR radius cannot be negative")
+			}
+			if q.Conversion, err = parseGeoUnit(args[i+2]); err != nil {
+				return opts, err
+			}
+			i += 2
+		case arg == "BYBOX" && remaining > 2:
+			if byRadius || byBox {
+				return opts, errSyntax
+			}
+			byBox = true
+			q.Box = true
+			if q.Width, err = parseGeoLength(args[i+1], "width"); err != nil {
+				return opts, err
+			}
+			if q.Height, err = parseGeoLength(args[i+2], "height"); err != nil {
+				return opts, err
+			}
+			if q.Width < 0 || q.Height < 0 {
+				return opts, errors.New("ERR height or width cannot be negative")
+			}
+			if q.Conversion, err = parseGeoUnit(args[i+3]); err != nil {
+				return opts, err
+			}
+			i += 3
+		default:
+			return opts, errSyntax
+		}
+	}
+	if store && (opts.withDist || opts.withHash || opts.withCoord) {
+		return opts, fmt.Errorf("ERR %s is not compatible with WITHDIST, WITHHASH and WITHCOORD options", cmd)
+	}
+	if fromMember == fromLonLat {
+		return opts, fmt.Errorf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+	}
+	if byRadius == byBox {
+		return opts, fmt.Errorf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+	}
+	if q.Any && q.Count == 0 {
+		return opts, errors.New("ERR the ANY argument requires COUNT argument")
+	}
+	return opts, nil
+}
+
+func parseGeoPoint(longitude, latitude protocol.Resp) (datastore.GeoPoint, error) {
+	lon, err := parseFloat(longitude)
+	if err != nil {
+		return datastore.GeoPoint{}, err
+	}
+	lat, err := parseFloat(latitude)
+	if err != nil {
+		return datastore.GeoPoint{}, err
+	}
+	p := datastore.GeoPoint{Longitude: lon, Latitude: lat}
+	if !datastore.GeoValid(p) {
+		return p, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return p, nil
+}
+
+func parseGeoLength(arg protocol.Resp, name string) (float64, error) {
+	v, err := parseFloat(arg)
+	if err != nil {
+		return 0, fmt.Errorf("ERR need numeric %s", name)
+	}
+	return v, nil
+}
+
+// parseGeoUnit returns the number of meters in the unit.
+func parseGeoUnit(arg protocol.Resp) (float64, error) {
+	switch strings.ToLower(arg.String()) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, errGeoUnit
+}
+
+func geoDistanceReply(distance float64) protocol.Resp {
+	return bulkString(strconv.FormatFloat(distance, 'f', 4, 64))
+}
+
+func geoPointReply(p datastore.GeoPoint) protocol.Resp {
+	return bulkStringArray([]string{formatGeoCoordinate(p.Longitude), formatGeoCoordinate(p.Latitude)})
+}
+
+// formatGeoCoordinate formats a coordinate with 17 decimals, trimming trailing zeroes like Redis.
+func formatGeoCoordinate(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 17, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestGeoCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	nilArray := protocol.Array{Items: nil}
+	runSequence(t, ds, []step{
+		{command("GEOADD", "Sicily", "13.361389", "38.115556"), wrongNumberOfArgs("geoadd")},
+		{command("GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269"), errorReply(errSyntax)},
+		{command("GEOADD", "Sicily", "NX", "XX", "13.361389", "38.115556", "Palermo"), errorReply(errSyntax)},
+		{command("GEOADD", "Sicily", "200", "38.115556", "Palermo"), protocol.Error{Data: "ERR invalid longitude,latitude pair 200.000000,38.115556"}},
+		{command("GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"), protocol.Integer{Value: 2}},
+		{command("GEOADD", "Sicily", "NX", "CH", "13", "38", "Palermo"), protocol.Integer{Value: 0}},
+		{command("ZSCORE", "Sicily", "Palermo"), bulkString("3479099956230698")},
+		{command("GEODIST", "Sicily", "Palermo", "Catania"), bulkString("166274.1516")},
+		{command("GEODIST", "Sicily", "Palermo", "Catania", "km"), bulkString("166.2742")},
+		{command("GEODIST", "Sicily", "Palermo", "Catania", "yd"), protocol.Error{Data: "ERR unsupported unit provided. please use M, KM, FT, MI"}},
+		{command("GEODIST", "Sicily", "Palermo", "missing"), protocol.BulkString{Data: nil}},
+		{command("GEOPOS", "Sicily", "Palermo", "missing"), protocol.Array{Items: []protocol.Resp{
+			bulkStringArray([]string{"13.36138933897018433", "38.11555639549629859"}),
+			nilArray,
+		}}},
+		{command("GEOHASH", "Sicily", "Palermo", "Catania", "missing"), protocol.Array{Items: []protocol.Resp{
+			bulkString("sqc8b49rny0"), bulkString("sqdtr74hyu0"), protocol.BulkString{Data: nil},
+		}}},
+		{command("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"), bulkStringArray([]string{"Catania", "Palermo"})},
+		{command("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "DESC", "WITHDIST", "WITHHASH"), protocol.Array{Items: []protocol.Resp{
+			protocol.Array{Items: []protocol.Resp{bulkString("Palermo"), bulkString("190.4424"), protocol.Integer{Value: 3479099956230698}}},
+			protocol.Array{Items: []protocol.Resp{bulkString("Catania"), bulkString("56.4413"), protocol.Integer{Value: 3479447370796909}}},
+		}}},
+		{command("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "COUNT", "1"), bulkStringArray([]string{"Catania"})},
+		{command("GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "100", "km"), bulkStringArray([]string{"Palermo"})},
+		{command("GEOADD", "Sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"), protocol.Integer{Value: 2}},
+		{command("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "WITHCOORD", "WITHDIST"), protocol.Array{Items: []protocol.Resp{
+			protocol.Array{Items: []protocol.Resp{bulkString("Catania"), bulkString("56.4413"), bulkStringArray([]string{"15.08726745843887329", "37.50266842333162032"})}},
+			protocol.Array{Items: []protocol.Resp{bulkString("Palermo"), bulkString("190.4424"), bulkStringArray([]string{"13.36138933897018433", "38.11555639549629859"})}},
+			protocol.Array{Items: []protocol.Resp{bulkString("edge2"), bulkString("279.7403"), bulkStringArray([]string{"17.24151045083999634", "38.78813451624225195"})}},
+			protocol.Array{Items: []protocol.Resp{bulkString("edge1"), bulkString("279.7405"), bulkStringArray([]string{"12.7584877610206604", "38.78813451624225195"})}},
+		}}},
+		{command("GEOSEARCH", "missing", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km"), protocol.Array{Items: []protocol.Resp{}}},
+		{command("GEOSEARCH", "Sicily", "FROMMEMBER", "missing", "BYRADIUS", "200", "km"), protocol.Error{Data: "ERR could not decode requested zset member"}},
+		{command("GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "STOREDIST"), protocol.Integer{Value: 2}},
+		{command("ZRANGE", "dst", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"Catania", "56.4412578701582", "Palermo", "190.4424298477578"})},
+		{command("GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "0", "0", "BYRADIUS", "1", "m"), protocol.Integer{Value: 0}},
+		{command("EXISTS", "dst"), protocol.Integer{Value: 0}},
+	})
+}
+
+func TestGeoSearchErrors(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS"), wrongNumberOfArgs("geosearch")},
+		{command("GEOSEARCH", "k", "FROMMEMBER", "a", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km"), errorReply(errSyntax)},
+		{command("GEOSEARCH", "k", "BYRADIUS", "1", "km", "BYBOX", "1", "1", "km"), errorReply(errSyntax)},
+		{command("GEOSEARCH", "k", "BYRADIUS", "1", "km", "ASC", "WITHDIST"), protocol.Error{Data: "ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "ASC", "WITHDIST"), protocol.Error{Data: "ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "ANY"), protocol.Error{Data: "ERR the ANY argument requires COUNT argument"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "COUNT", "0"), protocol.Error{Data: "ERR COUNT must be > 0"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "-1", "km"), protocol.Error{Data: "ERR radius cannot be negative"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYBOX", "1", "-1", "km"), protocol.Error{Data: "ERR height or width cannot be negative"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "x", "km"), protocol.Error{Data: "ERR need numeric radius"}},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "yd"), errorReply(errGeoUnit)},
+		{command("GEOSEARCH", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "STOREDIST"), errorReply(errSyntax)},
+		{command("GEOSEARCHSTORE", "d", "k", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "WITHDIST"), protocol.Error{Data: "ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options"}},
+		{command("SET", "str", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("GEOPOS", "str", "a"), wrongType},
+	})
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleHSetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return wrongNumberOfArgs("hset")
+	}
+	n, err := ds.HSet(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleHSetNXCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("hsetnx")
+	}
+	ok, err := ds.HSetNX(args[0].String(), args[1].String(), args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(ok)
+}
+
+func handleHGetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("hget")
+	}
+	v, err := ds.HGet(args[0].String(), args[1].String())
+	if errors.Is(err, datastore.ErrNotFound) {
+		return protocol.BulkString{Data: nil}
+	} else if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(v)
+}
+
+func handleHMGetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("hmget")
+	}
+	values, err := ds.HMGet(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(values))
+	for i, v := range values {
+		items[i] = protocol.BulkString{Data: v}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleHGetAllCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleHashRead("hgetall", args, ds.HGetAll)
+}
+
+func handleHKeysCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleHashRead("hkeys", args, ds.HKeys)
+}
+
+func handleHValsCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleHashRead("hvals", args, ds.HVals)
+}
+
+func handleHashRead(cmd string, args []protocol.Resp, read func(string) ([]string, error)) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs(cmd)
+	}
+	values, err := read(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkStringArray(values)
+}
+
+func handleHDelCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("hdel")
+	}
+	n, err := ds.HDel(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleHExistsCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("hexists")
+	}
+	ok, err := ds.HExists(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(ok)
+}
+
+func handleHLenCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("hlen")
+	}
+	n, err := ds.HLen(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleHStrLenCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("hstrlen")
+	}
+	n, err := ds.HStrLen(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleHIncrByCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("hincrby")
+	}
+	delta, err := parseInt(args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	n, err := ds.HIncrBy(args[0].String(), args[1].String(), delta)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleHIncrByFloatCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("hincrbyfloat")
+	}
+	v, err := ds.HIncrByFloat(args[0].String(), args[1].String(), args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(v)
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestHashCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("HSET", "h", "f1"), wrongNumberOfArgs("hset")},
+		{command("HSET", "h", "f1", "v1", "f2", "v2"), protocol.Integer{Value: 2}},
+		{command("HSET", "h", "f1", "v1.1", "f3", "v3"), protocol.Integer{Value: 1}},
+		{command("HGET", "h", "f1"), bulkString("v1.1")},
+		{command("HGET", "h", "nope"), protocol.BulkString{Data: nil}},
+		{command("HMGET", "h", "f2", "nope"), protocol.Array{Items: []protocol.Resp{bulkString("v2"), protocol.BulkString{Data: nil}}}},
+		{command("HLEN", "h"), protocol.Integer{Value: 3}},
+		{command("HEXISTS", "h", "f3"), protocol.Integer{Value: 1}},
+		{command("HEXISTS", "h", "f4"), protocol.Integer{Value: 0}},
+		{command("HSTRLEN", "h", "f1"), protocol.Integer{Value: 4}},
+		{command("HSETNX", "h", "f1", "x"), protocol.Integer{Value: 0}},
+		{command("HSETNX", "h", "f4", "x"), protocol.Integer{Value: 1}},
+		{command("HDEL", "h", "f1", "f2", "f3", "nope"), protocol.Integer{Value: 3}},
+		{command("HGETALL", "h"), bulkStringArray([]string{"f4", "x"})},
+		{command("HKEYS", "h"), bulkStringArray([]string{"f4"})},
+		{command("HVALS", "h"), bulkStringArray([]string{"x"})},
+		{command("HGETALL", "missing"), bulkStringArray([]string{})},
+		{command("HDEL", "h", "f4"), protocol.Integer{Value: 1}},
+		{command("EXISTS", "h"), protocol.Integer{Value: 0}},
+	})
+}
+
+func TestHashIncrCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("HINCRBY", "h", "n", "5"), protocol.Integer{Value: 5}},
+		{command("HINCRBY", "h", "n", "-7"), protocol.Integer{Value: -2}},
+		{command("HINCRBY", "h", "n", "x"), protocol.Error{Data: "ERR value is not an integer or out of range"}},
+		{command("HINCRBY", "h", "n", "-9223372036854775807"), protocol.Error{Data: "ERR increment or decrement would overflow"}},
+		{command("HSET", "h", "s", "abc", "f", "10.50"), protocol.Integer{Value: 2}},
+		{command("HINCRBY", "h", "s", "1"), protocol.Error{Data: "ERR hash value is not an integer"}},
+		{command("HINCRBYFLOAT", "h", "f", "0.1"), bulkString("10.6")},
+		{command("HINCRBYFLOAT", "h", "f", "-5"), bulkString("5.6")},
+		{command("HINCRBYFLOAT", "h", "s", "1"), protocol.Error{Data: "ERR hash value is not a float"}},
+		{command("HINCRBYFLOAT", "h", "f", "abc"), protocol.Error{Data: "ERR value is not a valid float"}},
+		{command("HINCRBYFLOAT", "h", "new", "2.5e3"), bulkString("2500")},
+		{command("HINCRBYFLOAT", "h", "tenth", "0.1"), bulkString("0.1")},
+		{command("HINCRBYFLOAT", "h", "tenth", "0.1"), bulkString("0.2")},
+		{command("HINCRBYFLOAT", "h", "tenth", "0.1"), bulkString("0.3")},
+		{command("SET", "str", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("HSET", "str", "f", "v"), wrongType},
+		{command("HGET", "str", "f"), wrongType},
+		{command("GET", "h"), wrongType},
+	})
+}
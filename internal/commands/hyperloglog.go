@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handlePFAddCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("pfadd")
+	}
+	updated, err := ds.PFAdd(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(updated)
+}
+
+func handlePFCountCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("pfcount")
+	}
+	n, err := ds.PFCount(stringArgs(args)...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handlePFMergeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("pfmerge")
+	}
+	if err := ds.PFMerge(args[0].String(), stringArgs(args[1:])...); err != nil {
+		return errorReply(err)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
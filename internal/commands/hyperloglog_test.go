@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestHyperLogLogCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("PFADD"), wrongNumberOfArgs("pfadd")},
+		{command("PFADD", "h1", "a", "b", "c"), protocol.Integer{Value: 1}},
+		{command("PFADD", "h1", "a"), protocol.Integer{Value: 0}},
+		{command("PFADD", "h2", "c", "d"), protocol.Integer{Value: 1}},
+		{command("PFCOUNT", "h1"), protocol.Integer{Value: 3}},
+		{command("PFCOUNT", "h1", "h2", "missing"), protocol.Integer{Value: 4}},
+		{command("PFMERGE", "h3", "h1", "h2"), protocol.SimpleString{Data: "OK"}},
+		{command("PFCOUNT", "h3"), protocol.Integer{Value: 4}},
+		{command("SET", "str", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("PFADD", "str", "a"), protocol.Error{Data: "WRONGTYPE Key is not a valid HyperLogLog string value."}},
+		{command("PFMERGE", "h3", "str"), protocol.Error{Data: "WRONGTYPE Key is not a valid HyperLogLog string value."}},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("PFCOUNT", "list"), wrongType},
+	})
+}
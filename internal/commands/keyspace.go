@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+// errDBIndexOutOfRange is returned when selecting a database that does not exist.
+var errDBIndexOutOfRange = errors.New("ERR DB index is out of range")
+
+func handleKeysCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("keys")
+	}
+	return bulkStringArray(ds.Keys(args[0].String()))
+}
+
+func handleScanCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("scan")
+	}
+	cursor, err := strconv.ParseUint(args[0].String(), 10, 64)
+	if err != nil {
+		return protocol.Error{Data: "ERR invalid cursor"}
+	}
+	var opts datastore.ScanOptions
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "MATCH" && i+1 < len(args):
+			opts.Match = args[i+1].String()
+			i++
+		case opt == "COUNT" && i+1 < len(args):
+			if opts.Count, err = parseInt(args[i+1]); err != nil {
+				return errorReply(err)
+			}
+			if opts.Count < 1 {
+				return errorReply(errSyntax)
+			}
+			i++
+		case opt == "TYPE" && i+1 < len(args):
+			opts.Type = strings.ToLower(args[i+1].String())
+			if !datastore.ValidType(opts.Type) {
+				return protocol.Error{Data: fmt.Sprintf("ERR unknown type name '%s'", args[i+1].String())}
+			}
+			i++
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	next, keys := ds.Scan(cursor, opts)
+	return protocol.Array{Items: []protocol.Resp{
+		bulkString(strconv.FormatUint(next, 10)),
+		bulkStringArray(keys),
+	}}
+}
+
+func handleTypeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("type")
+	}
+	return protocol.SimpleString{Data: ds.Type(args[0].String())}
+}
+
+func handleRandomKeyCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 0 {
+		return wrongNumberOfArgs("randomkey")
+	}
+	key, ok := ds.RandomKey()
+	if !ok {
+		return protocol.BulkString{Data: nil}
+	}
+	return bulkString(key)
+}
+
+func handleDBSizeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 0 {
+		return wrongNumberOfArgs("dbsize")
+	}
+	return protocol.Integer{Value: ds.DBSize()}
+}
+
+func handleRenameCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("rename")
+	}
+	if err := ds.Rename(args[0].String(), args[1].String()); err != nil {
+		return errorReply(err)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleRenameNXCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("renamenx")
+	}
+	renamed, err := ds.RenameNX(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(renamed)
+}
+
+func handleCopyCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("copy")
+	}
+	ds := c.selected()
+	dst := ds
+	var replace bool
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "REPLACE":
+			replace = true
+		case opt == "DB" && i+1 < len(args):
+			db, err := parseInt(args[i+1])
+			if err != nil {
+				return errorReply(err)
+			}
+			if dst = c.database(db); dst == nil {
+				return errorReply(errDBIndexOutOfRange)
+			}
+			i++
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	copied, err := ds.CopyTo(dst, args[0].String(), args[1].String(), replace)
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(copied)
+}
+
+func handleMoveCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("move")
+	}
+	db, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	dst := c.database(db)
+	if dst == nil {
+		return errorReply(errDBIndexOutOfRange)
+	}
+	ds := c.selected()
+	moved, err := ds.MoveTo(dst, args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(moved)
+}
+
+func handleTouchCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("touch")
+	}
+	return protocol.Integer{Value: ds.Touch(stringArgs(args)...)}
+}
+
+func handleUnlinkCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("unlink")
+	}
+	return protocol.Integer{Value: ds.Unlink(stringArgs(args)...)}
+}
+
+func handleSelectCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("select")
+	}
+	db, err := parseInt(args[0])
+	if err != nil {
+		return errorReply(err)
+	}
+	if c.database(db) == nil {
+		return errorReply(errDBIndexOutOfRange)
+	}
+	c.db = int(db)
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleSwapDBCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("swapdb")
+	}
+	first, err := parseInt(args[0])
+	if err != nil {
+		return protocol.Error{Data: "ERR invalid first DB index"}
+	}
+	second, err := parseInt(args[1])
+	if err != nil {
+		return protocol.Error{Data: "ERR invalid second DB index"}
+	}
+	a, b := c.database(first), c.database(second)
+	if a == nil || b == nil {
+		return errorReply(errDBIndexOutOfRange)
+	}
+	if a != b {
+		a.SwapWith(b)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleFlushDBCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	async, err := parseFlushMode(args)
+	if err != nil {
+		return errorReply(err)
+	}
+	ds.Flush(async)
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleFlushAllCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	async, err := parseFlushMode(args)
+	if err != nil {
+		return errorReply(err)
+	}
+	for _, ds := range c.dbs.all {
+		ds.Flush(async)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
+
+// parseFlushMode parses the optional ASYNC or SYNC argument of FLUSHDB and FLUSHALL,
+// returning whether the flush is asynchronous.
+func parseFlushMode(args []protocol.Resp) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	if len(args) == 1 {
+		switch strings.ToUpper(args[0].String()) {
+		case "ASYNC":
+			return true, nil
+		case "SYNC":
+			return false, nil
+		}
+	}
+	return false, errSyntax
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestKeyspaceCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("DBSIZE"), protocol.Integer{Value: 0}},
+		{command("RANDOMKEY"), protocol.BulkString{Data: nil}},
+		{command("KEYS", "*"), protocol.Array{Items: []protocol.Resp{}}},
+		{command("SET", "s", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("RPUSH", "l", "a"), protocol.Integer{Value: 1}},
+		{command("SADD", "set", "a"), protocol.Integer{Value: 1}},
+		{command("ZADD", "z", "1", "a"), protocol.Integer{Value: 1}},
+		{command("HSET", "h", "f", "v"), protocol.Integer{Value: 1}},
+		{command("XADD", "x", "1-1", "f", "v"), bulkString("1-1")},
+		{command("PFADD", "hll", "a"), protocol.Integer{Value: 1}},
+		{command("TYPE", "s"), protocol.SimpleString{Data: "string"}},
+		{command("TYPE", "l"), protocol.SimpleString{Data: "list"}},
+		{command("TYPE", "set"), protocol.SimpleString{Data: "set"}},
+		{command("TYPE", "z"), protocol.SimpleString{Data: "zset"}},
+		{command("TYPE", "h"), protocol.SimpleString{Data: "hash"}},
+		{command("TYPE", "x"), protocol.SimpleString{Data: "stream"}},
+		{command("TYPE", "hll"), protocol.SimpleString{Data: "string"}},
+		{command("TYPE", "missing"), protocol.SimpleString{Data: "none"}},
+		{command("TYPE"), wrongNumberOfArgs("type")},
+		{command("DBSIZE"), protocol.Integer{Value: 7}},
+		{command("DBSIZE", "x"), wrongNumberOfArgs("dbsize")},
+		{command("KEYS", "[xy]"), keysReply("x")},
+		{command("KEYS"), wrongNumberOfArgs("keys")},
+		{command("PEXPIRE", "s", "-1"), protocol.Integer{Value: 1}},
+		{command("KEYS", "s*"), keysReply("set")},
+	})
+}
+
+func TestScanCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SCAN", "0"), protocol.Array{Items: []protocol.Resp{bulkString("0"), protocol.Array{Items: []protocol.Resp{}}}}},
+		{command("SCAN"), wrongNumberOfArgs("scan")},
+		{command("SCAN", "x"), protocol.Error{Data: "ERR invalid cursor"}},
+		{command("SCAN", "-1"), protocol.Error{Data: "ERR invalid cursor"}},
+		{command("SCAN", "0", "COUNT", "0"), errorReply(errSyntax)},
+		{command("SCAN", "0", "COUNT", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("SCAN", "0", "MATCH"), errorReply(errSyntax)},
+		{command("SCAN", "0", "TYPE", "foo"), protocol.Error{Data: "ERR unknown type name 'foo'"}},
+		{command("SET", "k1", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("SET", "k2", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("RPUSH", "l", "a"), protocol.Integer{Value: 1}},
+		{command("SCAN", "0", "TYPE", "LIST"), protocol.Array{Items: []protocol.Resp{bulkString("0"), keysReply("l")}}},
+	})
+	got, _ := HandleCommand(command("SCAN", "0", "MATCH", "k*", "COUNT", "100"), NewClient(NewDatabases(ds)))
+	keys := got.(protocol.Array).Items[1].(protocol.Array)
+	sortKeys(keys)
+	if !reflect.DeepEqual(keys, keysReply("k1", "k2")) {
+		t.Errorf("Expected k1 and k2, got %v", keys)
+	}
+}
+
+// keysReply returns the sorted array reply of the keys.
+func keysReply(keys ...string) protocol.Array {
+	slices.Sort(keys)
+	return bulkStringArray(keys)
+}
+
+func sortKeys(a protocol.Array) {
+	slices.SortFunc(a.Items, func(x, y protocol.Resp) int {
+		return strings.Compare(x.String(), y.String())
+	})
+}
+
+func TestKeyManipulationCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	sameObject := errorReply(datastore.ErrSameObject)
+	outOfRange := protocol.Error{Data: "ERR DB index is out of range"}
+	runSequence(t, ds, []step{
+		{command("RENAME", "missing", "k"), errorReply(datastore.ErrNoSuchKey)},
+		{command("RENAME", "k"), wrongNumberOfArgs("rename")},
+		{command("SET", "k", "v", "EX", "100"), protocol.SimpleString{Data: "OK"}},
+		{command("RENAME", "k", "k2"), protocol.SimpleString{Data: "OK"}},
+		{command("GET", "k2"), bulkString("v")},
+		{command("TTL", "k2"), protocol.Integer{Value: 100}},
+		{command("RENAME", "k2", "k2"), protocol.SimpleString{Data: "OK"}},
+		{command("SET", "k3", "v3"), protocol.SimpleString{Data: "OK"}},
+		{command("RENAMENX", "k2", "k3"), protocol.Integer{Value: 0}},
+		{command("RENAMENX", "k2", "k"), protocol.Integer{Value: 1}},
+		{command("RENAMENX", "missing", "k4"), errorReply(datastore.ErrNoSuchKey)},
+		{command("COPY", "k", "k3"), protocol.Integer{Value: 0}},
+		{command("COPY", "k", "k3", "REPLACE"), protocol.Integer{Value: 1}},
+		{command("GET", "k3"), bulkString("v")},
+		{command("TTL", "k3"), protocol.Integer{Value: 100}},
+		{command("COPY", "k", "k4", "DB", "0"), protocol.Integer{Value: 1}},
+		{command("COPY", "missing", "k5"), protocol.Integer{Value: 0}},
+		{command("COPY", "k", "k"), sameObject},
+		{command("COPY", "k", "k5", "DB", "1"), outOfRange},
+		{command("COPY", "k", "k5", "DB", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("COPY", "k", "k5", "FOO"), errorReply(errSyntax)},
+		{command("COPY", "k"), wrongNumberOfArgs("copy")},
+		{command("MOVE", "k", "0"), sameObject},
+		{command("MOVE", "k", "1"), outOfRange},
+		{command("MOVE", "k", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("TOUCH", "k", "k3", "missing"), protocol.Integer{Value: 2}},
+		{command("TOUCH"), wrongNumberOfArgs("touch")},
+		{command("UNLINK", "k", "k3", "missing"), protocol.Integer{Value: 2}},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+		{command("UNLINK"), wrongNumberOfArgs("unlink")},
+	})
+}
@@ -0,0 +1,317 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleLPushCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handlePush("lpush", args, ds.LPush)
+}
+
+func handleRPushCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handlePush("rpush", args, ds.RPush)
+}
+
+func handlePush(cmd string, args []protocol.Resp, push func(string, ...string) (int64, error)) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	n, err := push(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleLPopCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handlePop("lpop", args, ds.LPop)
+}
+
+func handleRPopCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handlePop("rpop", args, ds.RPop)
+}
+
+func handlePop(cmd string, args []protocol.Resp, pop func(string, int) ([]string, error)) protocol.Resp {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	count := 1
+	if len(args) == 2 {
+		c, err := parseInt(args[1])
+		if err != nil || c < 0 {
+			return protocol.Error{Data: "ERR value is out of range, must be positive"}
+		}
+		count = int(c)
+	}
+	values, err := pop(args[0].String(), count)
+	if err != nil {
+		return errorReply(err)
+	}
+	if len(args) == 2 {
+		if values == nil {
+			return protocol.Array{Items: nil}
+		}
+		return bulkStringArray(values)
+	}
+	if len(values) == 0 {
+		return protocol.BulkString{Data: nil}
+	}
+	return bulkString(values[0])
+}
+
+func handleLRangeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("lrange")
+	}
+	start, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	stop, err := parseInt(args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	values, err := ds.LRange(args[0].String(), start, stop)
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkStringArray(values)
+}
+
+func handleLLenCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("llen")
+	}
+	n, err := ds.LLen(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleLIndexCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("lindex")
+	}
+	index, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	v, err := ds.LIndex(args[0].String(), index)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return protocol.BulkString{Data: nil}
+	} else if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(v)
+}
+
+func handleLSetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("lset")
+	}
+	index, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	if err := ds.LSet(args[0].String(), index, args[2].String()); err != nil {
+		return errorReply(err)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleLRemCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("lrem")
+	}
+	count, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	n, err := ds.LRem(args[0].String(), count, args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleLTrimCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("ltrim")
+	}
+	start, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	stop, err := parseInt(args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	if err := ds.LTrim(args[0].String(), start, stop); err != nil {
+		return errorReply(err)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleLInsertCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 4 {
+		return wrongNumberOfArgs("linsert")
+	}
+	var before bool
+	switch strings.ToUpper(args[1].String()) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return protocol.Error{Data: "ERR syntax error"}
+	}
+	n, err := ds.LInsert(args[0].String(), before, args[2].String(), args[3].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleBLPopCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	return handleBPop("blpop", args, true, c)
+}
+
+func handleBRPopCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	return handleBPop("brpop", args, false, c)
+}
+
+func handleBPop(cmd string, args []protocol.Resp, left bool, c *Client) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return errorReply(err)
+	}
+	pop := datastore.ListPop{Keys: stringArgs(args[:len(args)-1]), Left: left, Count: 1}
+	return c.blockingPop(pop, timeout, func(p datastore.Popped) protocol.Resp {
+		return bulkStringArray([]string{p.Key, p.Values[0]})
+	})
+}
+
+func handleBLMoveCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) != 5 {
+		return wrongNumberOfArgs("blmove")
+	}
+	pop, err := parseMove(args[0], args[1], args[2], args[3])
+	if err != nil {
+		return errorReply(err)
+	}
+	timeout, err := parseTimeout(args[4])
+	if err != nil {
+		return errorReply(err)
+	}
+	return c.blockingPop(pop, timeout, func(p datastore.Popped) protocol.Resp {
+		return bulkString(p.Values[0])
+	})
+}
+
+func handleBRPopLPushCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("brpoplpush")
+	}
+	timeout, err := parseTimeout(args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return c.blockingPop(rpopLPush(args[0], args[1]), timeout, func(p datastore.Popped) protocol.Resp {
+		return bulkString(p.Values[0])
+	})
+}
+
+func handleBLMPopCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if len(args) < 4 {
+		return wrongNumberOfArgs("blmpop")
+	}
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return errorReply(err)
+	}
+	pop, err := parseMPop(args[1:])
+	if err != nil {
+		return errorReply(err)
+	}
+	return c.blockingPop(pop, timeout, mpopReply)
+}
+
+// parseMove parses source destination LEFT|RIGHT LEFT|RIGHT, like the arguments of BLMOVE.
+func parseMove(source, destination, from, to protocol.Resp) (datastore.ListPop, error) {
+	left, err := parseDirection(from)
+	if err != nil {
+		return datastore.ListPop{}, err
+	}
+	toLeft, err := parseDirection(to)
+	if err != nil {
+		return datastore.ListPop{}, err
+	}
+	return datastore.ListPop{
+		Keys: []string{source.String()}, Left: left, Count: 1,
+		Move: true, Destination: destination.String(), ToLeft: toLeft,
+	}, nil
+}
+
+// rpopLPush is the pop of BRPOPLPUSH, which moves the tail of source to the head of destination.
+func rpopLPush(source, destination protocol.Resp) datastore.ListPop {
+	return datastore.ListPop{
+		Keys: []string{source.String()}, Count: 1,
+		Move: true, Destination: destination.String(), ToLeft: true,
+	}
+}
+
+// parseMPop parses numkeys key [key ...] LEFT|RIGHT [COUNT count], like the arguments of BLMPOP
+// following the timeout.
+func parseMPop(args []protocol.Resp) (datastore.ListPop, error) {
+	numKeys, err := parseInt(args[0])
+	if err != nil || numKeys <= 0 {
+		return datastore.ListPop{}, errors.New("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return datastore.ListPop{}, errors.New("ERR Number of keys can't be greater than number of args")
+	}
+	pop := datastore.ListPop{Keys: stringArgs(args[1 : 1+numKeys]), Count: 1}
+	rest := args[1+numKeys:]
+	if len(rest) == 0 {
+		return datastore.ListPop{}, errSyntax
+	}
+	if pop.Left, err = parseDirection(rest[0]); err != nil {
+		return datastore.ListPop{}, err
+	}
+	switch {
+	case len(rest) == 1:
+	case len(rest) == 3 && strings.ToUpper(rest[1].String()) == "COUNT":
+		count, err := parseInt(rest[2])
+		if err != nil || count <= 0 {
+			return datastore.ListPop{}, errors.New("ERR count should be greater than 0")
+		}
+		pop.Count = int(count)
+	default:
+		return datastore.ListPop{}, errSyntax
+	}
+	return pop, nil
+}
+
+// parseDirection parses LEFT or RIGHT, reporting whether it is LEFT.
+func parseDirection(arg protocol.Resp) (bool, error) {
+	switch strings.ToUpper(arg.String()) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	}
+	return false, errSyntax
+}
+
+// mpopReply replies the key popped from followed by the popped elements, like BLMPOP.
+func mpopReply(popped datastore.Popped) protocol.Resp {
+	return protocol.Array{Items: []protocol.Resp{bulkString(popped.Key), bulkStringArray(popped.Values)}}
+}
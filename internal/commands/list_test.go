@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var wrongType = protocol.Error{Data: "WRONGTYPE Operation against a key holding the wrong kind of value"}
+
+func TestListCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("LPUSH", "list"), wrongNumberOfArgs("lpush")},
+		{command("RPUSH", "list", "b", "c"), protocol.Integer{Value: 2}},
+		{command("LPUSH", "list", "a", "z"), protocol.Integer{Value: 4}},
+		{command("LRANGE", "list", "0", "-1"), bulkStringArray([]string{"z", "a", "b", "c"})},
+		{command("LLEN", "list"), protocol.Integer{Value: 4}},
+		{command("LLEN", "missing"), protocol.Integer{Value: 0}},
+		{command("LINDEX", "list", "-1"), bulkString("c")},
+		{command("LINDEX", "list", "10"), protocol.BulkString{Data: nil}},
+		{command("LPOP", "list"), bulkString("z")},
+		{command("RPOP", "list", "2"), bulkStringArray([]string{"c", "b"})},
+		{command("RPOP", "list", "-1"), protocol.Error{Data: "ERR value is out of range, must be positive"}},
+		{command("LPOP", "missing"), protocol.BulkString{Data: nil}},
+		{command("LPOP", "missing", "2"), protocol.Array{Items: nil}},
+		{command("LSET", "list", "0", "x"), protocol.SimpleString{Data: "OK"}},
+		{command("LSET", "list", "5", "x"), protocol.Error{Data: "ERR index out of range"}},
+		{command("LSET", "missing", "0", "x"), protocol.Error{Data: "ERR no such key"}},
+		{command("LINSERT", "list", "BEFORE", "x", "w"), protocol.Integer{Value: 2}},
+		{command("LINSERT", "list", "AFTER", "x", "y"), protocol.Integer{Value: 3}},
+		{command("LINSERT", "list", "AFTER", "nope", "y"), protocol.Integer{Value: -1}},
+		{command("LINSERT", "list", "AROUND", "x", "y"), protocol.Error{Data: "ERR syntax error"}},
+		{command("LRANGE", "list", "0", "-1"), bulkStringArray([]string{"w", "x", "y"})},
+		{command("RPUSH", "list", "x", "x"), protocol.Integer{Value: 5}},
+		{command("LREM", "list", "-1", "x"), protocol.Integer{Value: 1}},
+		{command("LREM", "list", "0", "x"), protocol.Integer{Value: 2}},
+		{command("LRANGE", "list", "0", "-1"), bulkStringArray([]string{"w", "y"})},
+		{command("LTRIM", "list", "1", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("LRANGE", "list", "0", "-1"), bulkStringArray([]string{"y"})},
+		{command("LTRIM", "list", "5", "10"), protocol.SimpleString{Data: "OK"}},
+		{command("EXISTS", "list"), protocol.Integer{Value: 0}},
+	})
+}
+
+func TestListWrongType(t *testing.T) {
+	ds := datastore.NewDatastore()
+	ds.Set("string", "value")
+	runSequence(t, ds, []step{
+		{command("LPUSH", "string", "a"), wrongType},
+		{command("LRANGE", "string", "0", "-1"), wrongType},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("GET", "list"), wrongType},
+		{command("INCR", "list"), wrongType},
+		{command("EXISTS", "list"), protocol.Integer{Value: 1}},
+	})
+}
+
+func TestBlockingListCommandsWithElements(t *testing.T) {
+	c := NewClient(newDatabases(1))
+	runClientSequence(t, c, []step{
+		{command("RPUSH", "list", "a", "b", "c"), protocol.Integer{Value: 3}},
+		{command("BLMOVE", "list", "list", "LEFT", "RIGHT", "0"), bulkString("a")},
+		{command("BLMOVE", "list", "other", "right", "left", "0"), bulkString("a")},
+		{command("BLMOVE", "list", "other", "UP", "LEFT", "0"), errorReply(errSyntax)},
+		{command("BRPOPLPUSH", "list", "other", "0"), bulkString("c")},
+		{command("LRANGE", "other", "0", "-1"), bulkStringArray([]string{"c", "a"})},
+		{command("SET", "s", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("BRPOPLPUSH", "list", "s", "0"), wrongType},
+		{command("BLMPOP", "0", "3", "missing", "other", "list", "LEFT", "COUNT", "5"),
+			protocol.Array{Items: []protocol.Resp{bulkString("other"), bulkStringArray([]string{"c", "a"})}}},
+		{command("BLMPOP", "0", "2", "missing", "list", "RIGHT"),
+			protocol.Array{Items: []protocol.Resp{bulkString("list"), bulkStringArray([]string{"b"})}}},
+		{command("BLMPOP", "0", "1", "s", "LEFT"), wrongType},
+		{command("BLMPOP", "0", "3", "a", "LEFT"), protocol.Error{Data: "ERR Number of keys can't be greater than number of args"}},
+		{command("BLMPOP", "0", "2", "a", "LEFT"), errorReply(errSyntax)},
+		{command("BLMPOP", "0", "1", "a", "LEFT", "COUNT", "0"), protocol.Error{Data: "ERR count should be greater than 0"}},
+		{command("BLMPOP", "0", "1", "a", "LEFT", "LIMIT", "1"), errorReply(errSyntax)},
+	})
+}
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"math"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleSAddCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("sadd")
+	}
+	n, err := ds.SAdd(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleSRemCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("srem")
+	}
+	n, err := ds.SRem(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleSMembersCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("smembers")
+	}
+	members, err := ds.SMembers(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkStringArray(members)
+}
+
+func handleSIsMemberCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("sismember")
+	}
+	ok, err := ds.SIsMember(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(ok)
+}
+
+func handleSMIsMemberCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("smismember")
+	}
+	found, err := ds.SMIsMember(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(found))
+	for i, ok := range found {
+		items[i] = boolInteger(ok)
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleSCardCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("scard")
+	}
+	n, err := ds.SCard(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleSPopCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongNumberOfArgs("spop")
+	}
+	count := 1
+	if len(args) == 2 {
+		c, err := parseInt(args[1])
+		if err != nil || c < 0 {
+			return protocol.Error{Data: "ERR value is out of range, must be positive"}
+		}
+		count = int(c)
+	}
+	members, err := ds.SPop(args[0].String(), count)
+	if err != nil {
+		return errorReply(err)
+	}
+	if len(args) == 2 {
+		return bulkStringArray(members)
+	}
+	if len(members) == 0 {
+		return protocol.BulkString{Data: nil}
+	}
+	return bulkString(members[0])
+}
+
+func handleSRandMemberCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongNumberOfArgs("srandmember")
+	}
+	var count int64 = 1
+	if len(args) == 2 {
+		var err error
+		count, err = parseInt(args[1])
+		if err != nil {
+			return errorReply(err)
+		}
+		if count == math.MinInt64 {
+			return protocol.Error{Data: "ERR value is out of range"}
+		}
+	}
+	members, err := ds.SRandMember(args[0].String(), count)
+	if err != nil {
+		return errorReply(err)
+	}
+	if len(args) == 2 {
+		return bulkStringArray(members)
+	}
+	if len(members) == 0 {
+		return protocol.BulkString{Data: nil}
+	}
+	return bulkString(members[0])
+}
+
+func handleSInterCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSetOperation("sinter", args, ds.SInter)
+}
+
+func handleSUnionCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSetOperation("sunion", args, ds.SUnion)
+}
+
+func handleSDiffCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSetOperation("sdiff", args, ds.SDiff)
+}
+
+func handleSetOperation(cmd string, args []protocol.Resp, op func(...string) ([]string, error)) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs(cmd)
+	}
+	members, err := op(stringArgs(args)...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkStringArray(members)
+}
+
+func handleSInterStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSetOperationStore("sinterstore", args, ds.SInterStore)
+}
+
+func handleSUnionStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSetOperationStore("sunionstore", args, ds.SUnionStore)
+}
+
+func handleSDiffStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSetOperationStore("sdiffstore", args, ds.SDiffStore)
+}
+
+func handleSetOperationStore(cmd string, args []protocol.Resp, op func(string, ...string) (int64, error)) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	n, err := op(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleSInterCardCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("sintercard")
+	}
+	numKeys, err := parseInt(args[0])
+	if err != nil || numKeys <= 0 {
+		return protocol.Error{Data: "ERR numkeys should be greater than 0"}
+	}
+	if numKeys > int64(len(args)-1) {
+		return protocol.Error{Data: "ERR Number of keys can't be greater than number of args"}
+	}
+	keys := stringArgs(args[1 : 1+numKeys])
+	var limit int64
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		if strings.ToUpper(rest[i].String()) != "LIMIT" || i+1 >= len(rest) {
+			return protocol.Error{Data: "ERR syntax error"}
+		}
+		i++
+		limit, err = parseInt(rest[i])
+		if err != nil || limit < 0 {
+			return protocol.Error{Data: "ERR LIMIT can't be negative"}
+		}
+	}
+	n, err := ds.SInterCard(limit, keys...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleSMoveCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("smove")
+	}
+	ok, err := ds.SMove(args[0].String(), args[1].String(), args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolInteger(ok)
+}
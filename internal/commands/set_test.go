@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestSetCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SADD", "s"), wrongNumberOfArgs("sadd")},
+		{command("SADD", "s", "a", "b", "c", "a"), protocol.Integer{Value: 3}},
+		{command("SCARD", "s"), protocol.Integer{Value: 3}},
+		{command("SISMEMBER", "s", "a"), protocol.Integer{Value: 1}},
+		{command("SISMEMBER", "s", "z"), protocol.Integer{Value: 0}},
+		{command("SMISMEMBER", "s", "a", "z"), protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: 1}, protocol.Integer{Value: 0}}}},
+		{command("SREM", "s", "a", "b", "z"), protocol.Integer{Value: 2}},
+		{command("SMEMBERS", "s"), bulkStringArray([]string{"c"})},
+		{command("SRANDMEMBER", "s"), bulkString("c")},
+		{command("SRANDMEMBER", "s", "-3"), bulkStringArray([]string{"c", "c", "c"})},
+		{command("SRANDMEMBER", "s", "-9223372036854775808"), protocol.Error{Data: "ERR value is out of range"}},
+		{command("SRANDMEMBER", "missing"), protocol.BulkString{Data: nil}},
+		{command("SPOP", "s"), bulkString("c")},
+		{command("EXISTS", "s"), protocol.Integer{Value: 0}},
+		{command("SPOP", "s", "2"), bulkStringArray([]string{})},
+	})
+}
+
+func TestSetAlgebraCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	ds.SAdd("s1", "a", "b", "c")
+	ds.SAdd("s2", "b", "c", "d")
+	ds.SAdd("s3", "c", "e")
+	ds.Set("str", "v")
+	runSequence(t, ds, []step{
+		{command("SINTER", "s1", "s2", "s3"), bulkStringArray([]string{"c"})},
+		{command("SINTER", "s1", "missing"), bulkStringArray([]string{})},
+		{command("SDIFF", "s1", "s2"), bulkStringArray([]string{"a"})},
+		{command("SUNIONSTORE", "dst", "s1", "s2", "s3"), protocol.Integer{Value: 5}},
+		{command("SINTERSTORE", "dst", "s1", "s2"), protocol.Integer{Value: 2}},
+		{command("SDIFFSTORE", "dst", "s1", "s2", "s3"), protocol.Integer{Value: 1}},
+		{command("SMEMBERS", "dst"), bulkStringArray([]string{"a"})},
+		{command("SINTERSTORE", "dst", "s1", "missing"), protocol.Integer{Value: 0}},
+		{command("EXISTS", "dst"), protocol.Integer{Value: 0}},
+		{command("SINTERCARD", "2", "s1", "s2"), protocol.Integer{Value: 2}},
+		{command("SINTERCARD", "2", "s1", "s2", "LIMIT", "1"), protocol.Integer{Value: 1}},
+		{command("SINTERCARD", "0", "s1"), protocol.Error{Data: "ERR numkeys should be greater than 0"}},
+		{command("SINTERCARD", "3", "s1", "s2"), protocol.Error{Data: "ERR Number of keys can't be greater than number of args"}},
+		{command("SINTERCARD", "2", "s1", "s2", "LIMIT", "-1"), protocol.Error{Data: "ERR LIMIT can't be negative"}},
+		{command("SMOVE", "s1", "s3", "a"), protocol.Integer{Value: 1}},
+		{command("SMOVE", "s1", "s3", "a"), protocol.Integer{Value: 0}},
+		{command("SISMEMBER", "s3", "a"), protocol.Integer{Value: 1}},
+		{command("SMOVE", "s1", "str", "b"), wrongType},
+		{command("SUNION", "s1", "str"), wrongType},
+		{command("SADD", "str", "a"), wrongType},
+	})
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleSortCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSort("sort", args, ds, false)
+}
+
+// handleSortROCommand handles SORT_RO, the variant of SORT without STORE that never writes.
+func handleSortROCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleSort("sort_ro", args, ds, true)
+}
+
+func handleSort(cmd string, args []protocol.Resp, ds *datastore.Datastore, readOnly bool) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs(cmd)
+	}
+	var opts datastore.SortOptions
+	var store *string
+	for i := 1; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "ASC":
+			opts.Desc = false
+		case opt == "DESC":
+			opts.Desc = true
+		case opt == "ALPHA":
+			opts.Alpha = true
+		case opt == "LIMIT" && remaining >= 2:
+			var err error
+			if opts.Offset, err = parseInt(args[i+1]); err != nil {
+				return errorReply(err)
+			}
+			if opts.Count, err = parseInt(args[i+2]); err != nil {
+				return errorReply(err)
+			}
+			opts.Limit = true
+			i += 2
+		case opt == "STORE" && remaining >= 1 && !readOnly:
+			store = protocol.Ptr(args[i+1].String())
+			i++
+		case opt == "BY" && remaining >= 1:
+			opts.By = args[i+1].String()
+			i++
+		case opt == "GET" && remaining >= 1:
+			opts.Get = append(opts.Get, args[i+1].String())
+			i++
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	if store != nil {
+		n, err := ds.SortStore(args[0].String(), *store, opts)
+		if err != nil {
+			return errorReply(err)
+		}
+		return protocol.Integer{Value: n}
+	}
+	values, err := ds.Sort(args[0].String(), opts)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(values))
+	for i, v := range values {
+		items[i] = protocol.BulkString{Data: v}
+	}
+	return protocol.Array{Items: items}
+}
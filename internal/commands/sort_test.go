@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestSortCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SORT"), wrongNumberOfArgs("sort")},
+		{command("SORT", "ids"), protocol.Array{Items: []protocol.Resp{}}},
+		{command("RPUSH", "ids", "2", "1", "3"), protocol.Integer{Value: 3}},
+		{command("MSET", "weight_1", "3", "weight_2", "2", "weight_3", "1"), protocol.SimpleString{Data: "OK"}},
+		{command("HSET", "obj_1", "name", "one"), protocol.Integer{Value: 1}},
+		{command("HSET", "obj_3", "name", "three"), protocol.Integer{Value: 1}},
+		{command("SORT", "ids"), bulkStringArray([]string{"1", "2", "3"})},
+		{command("SORT", "ids", "desc", "LIMIT", "0", "2"), bulkStringArray([]string{"3", "2"})},
+		{command("SORT", "ids", "BY", "weight_*", "GET", "obj_*->name", "GET", "#"), protocol.Array{Items: []protocol.Resp{
+			bulkString("three"), bulkString("3"), protocol.BulkString{Data: nil}, bulkString("2"), bulkString("one"), bulkString("1"),
+		}}},
+		{command("SORT", "ids", "ALPHA", "STORE", "dst"), protocol.Integer{Value: 3}},
+		{command("LRANGE", "dst", "0", "-1"), bulkStringArray([]string{"1", "2", "3"})},
+		{command("SORT_RO", "ids", "DESC"), bulkStringArray([]string{"3", "2", "1"})},
+		{command("SORT_RO", "ids", "STORE", "dst"), errorReply(errSyntax)},
+		{command("SORT", "ids", "LIMIT", "0"), errorReply(errSyntax)},
+		{command("SORT", "ids", "LIMIT", "x", "1"), errorReply(datastore.ErrNotInteger)},
+		{command("SORT", "ids", "FOO"), errorReply(errSyntax)},
+		{command("RPUSH", "words", "a"), protocol.Integer{Value: 1}},
+		{command("SORT", "words"), errorReply(datastore.ErrSortScore)},
+		{command("SET", "s", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("SORT", "s"), errorReply(datastore.ErrWrongType)},
+	})
+}
@@ -0,0 +1,353 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleXAddCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 4 {
+		return wrongNumberOfArgs("xadd")
+	}
+	var opts datastore.XAddOptions
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].String()) {
+		case "NOMKSTREAM":
+			opts.NoMkStream = true
+		case "MAXLEN", "MINID":
+			trim, next, err := parseStreamTrim(args, i)
+			if err != nil {
+				return errorReply(err)
+			}
+			opts.Trim = &trim
+			i = next - 1
+		default:
+			break options
+		}
+	}
+	if i >= len(args) {
+		return errorReply(errSyntax)
+	}
+	fields := args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return wrongNumberOfArgs("xadd")
+	}
+	switch id := args[i].String(); {
+	case id == "*":
+		opts.AutoID = true
+	case strings.HasSuffix(id, "-*"):
+		ms, err := datastore.ParseStreamID(strings.TrimSuffix(id, "-*"), 0)
+		if err != nil || strings.Contains(strings.TrimSuffix(id, "-*"), "-") {
+			return errorReply(datastore.ErrInvalidStreamID)
+		}
+		opts.ID = ms
+		opts.AutoSeq = true
+	default:
+		parsed, err := datastore.ParseStreamID(id, 0)
+		if err != nil {
+			return errorReply(err)
+		}
+		opts.ID = parsed
+	}
+	id, ok, err := ds.XAdd(args[0].String(), opts, stringArgs(fields))
+	if err != nil {
+		return errorReply(err)
+	}
+	if !ok {
+		return protocol.BulkString{Data: nil}
+	}
+	return bulkString(id.String())
+}
+
+func handleXTrimCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs("xtrim")
+	}
+	trim, next, err := parseStreamTrim(args, 1)
+	if err != nil {
+		return errorReply(err)
+	}
+	if next != len(args) {
+		return errorReply(errSyntax)
+	}
+	n, err := ds.XTrim(args[0].String(), trim)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+// parseStreamTrim parses <MAXLEN | MINID> [= | ~] threshold [LIMIT count] starting at args[i]
+// and returns the index of the first argument following it.
+func parseStreamTrim(args []protocol.Resp, i int) (datastore.StreamTrim, int, error) {
+	var trim datastore.StreamTrim
+	if strings.ToUpper(args[i].String()) == "MINID" {
+		trim.Strategy = datastore.StreamTrimMinID
+	}
+	i++
+	if i < len(args) {
+		switch args[i].String() {
+		case "~":
+			trim.Approx = true
+			i++
+		case "=":
+			i++
+		}
+	}
+	if i >= len(args) {
+		return trim, i, errSyntax
+	}
+	var err error
+	if trim.Strategy == datastore.StreamTrimMaxLen {
+		if trim.MaxLen, err = parseInt(args[i]); err != nil {
+			return trim, i, err
+		}
+		if trim.MaxLen < 0 {
+			return trim, i, errors.New("ERR The MAXLEN argument must be >= 0.")
+		}
+	} else if trim.MinID, err = datastore.ParseStreamID(args[i].String(), 0); err != nil {
+		return trim, i, err
+	}
+	i++
+	if i+1 < len(args) && strings.ToUpper(args[i].String()) == "LIMIT" {
+		if trim.Limit, err = parseInt(args[i+1]); err != nil {
+			return trim, i, err
+		}
+		if trim.Limit < 0 {
+			return trim, i, errors.New("ERR The LIMIT argument must be >= 0.")
+		}
+		if !trim.Approx {
+			return trim, i, errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		i += 2
+	}
+	return trim, i, nil
+}
+
+func handleXDelCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("xdel")
+	}
+	ids, err := parseStreamIDs(args[1:])
+	if err != nil {
+		return errorReply(err)
+	}
+	n, err := ds.XDel(args[0].String(), ids...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleXLenCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("xlen")
+	}
+	n, err := ds.XLen(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleXRangeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleXRange("xrange", args, false, ds)
+}
+
+func handleXRevRangeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleXRange("xrevrange", args, true, ds)
+}
+
+func handleXRange(cmd string, args []protocol.Resp, rev bool, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 && len(args) != 5 {
+		return wrongNumberOfArgs(cmd)
+	}
+	startArg, endArg := args[1], args[2]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := parseStreamRangeBound(startArg.String(), true)
+	if err != nil {
+		return errorReply(err)
+	}
+	end, err := parseStreamRangeBound(endArg.String(), false)
+	if err != nil {
+		return errorReply(err)
+	}
+	var count int64 = -1
+	if len(args) == 5 {
+		if strings.ToUpper(args[3].String()) != "COUNT" {
+			return errorReply(errSyntax)
+		}
+		if count, err = parseInt(args[4]); err != nil {
+			return errorReply(err)
+		}
+		if count <= 0 {
+			return protocol.Array{Items: []protocol.Resp{}}
+		}
+	}
+	entries, err := ds.XRange(args[0].String(), start, end, count, rev)
+	if err != nil {
+		return errorReply(err)
+	}
+	return streamEntriesArray(entries)
+}
+
+// parseStreamRangeBound parses one end of an XRANGE interval: "-", "+", an ID, possibly
+// missing its sequence number, or an ID prefixed with "(" to exclude it from the interval.
+func parseStreamRangeBound(s string, start bool) (datastore.StreamID, error) {
+	switch s {
+	case "-":
+		return datastore.StreamID{}, nil
+	case "+":
+		return datastore.MaxStreamID, nil
+	}
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	var missingSeq uint64
+	if !start {
+		missingSeq = datastore.MaxStreamID.Seq
+	}
+	id, err := datastore.ParseStreamID(s, missingSeq)
+	if err != nil || !exclusive {
+		return id, err
+	}
+	var ok bool
+	if start {
+		if id, ok = id.Next(); !ok {
+			return id, errors.New("ERR invalid start ID for the interval")
+		}
+	} else if id, ok = id.Prev(); !ok {
+		return id, errors.New("ERR invalid end ID for the interval")
+	}
+	return id, nil
+}
+
+func handleXReadCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleXRead("xread", args, ds)
+}
+
+// handleXRead implements both XREAD and XREADGROUP, which only differ by the GROUP and NOACK
+// options and the meaning of the special IDs.
+func handleXRead(cmd string, args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	readGroup := cmd == "xreadgroup"
+	if len(args) < 3 || (readGroup && len(args) < 6) {
+		return wrongNumberOfArgs(cmd)
+	}
+	var count int64 = -1
+	var group, consumer string
+	var hasGroup, noAck bool
+	var err error
+	i := 0
+options:
+	for ; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "STREAMS":
+			break options
+		case opt == "COUNT" && i+1 < len(args):
+			if count, err = parseInt(args[i+1]); err != nil {
+				return errorReply(err)
+			}
+			i++
+		case opt == "BLOCK":
+			// Waiting for new entries is not implemented, and returning right away would
+			// look like a timeout to the caller.
+			return protocol.Error{Data: fmt.Sprintf("ERR BLOCK is not supported by %s", strings.ToUpper(cmd))}
+		case opt == "GROUP" && i+2 < len(args):
+			if !readGroup {
+				return protocol.Error{Data: "ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead."}
+			}
+			group, consumer, hasGroup = args[i+1].String(), args[i+2].String(), true
+			i += 2
+		case opt == "NOACK" && readGroup:
+			noAck = true
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	streams := args[min(i+1, len(args)):]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return protocol.Error{Data: fmt.Sprintf("ERR Unbalanced '%s' list of streams: for each stream key an ID or '$' must be specified.", cmd)}
+	}
+	if readGroup && !hasGroup {
+		return protocol.Error{Data: "ERR Missing GROUP option for XREADGROUP"}
+	}
+	keys := stringArgs(streams[:len(streams)/2])
+	if readGroup {
+		ids := make([]*datastore.StreamID, len(keys))
+		for j, a := range streams[len(keys):] {
+			switch a.String() {
+			case ">":
+				continue
+			case "$":
+				return protocol.Error{Data: "ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set."}
+			}
+			id, err := datastore.ParseStreamID(a.String(), 0)
+			if err != nil {
+				return errorReply(err)
+			}
+			ids[j] = &id
+		}
+		results, err := ds.XReadGroup(group, consumer, keys, ids, count, noAck)
+		if err != nil {
+			return groupErrorReply(cmd, err)
+		}
+		return streamReadResultsArray(results)
+	}
+	ids := make([]datastore.StreamID, len(keys))
+	for j, a := range streams[len(keys):] {
+		switch a.String() {
+		case "$":
+			ids[j], err = ds.XLastID(keys[j])
+		case ">":
+			err = errors.New("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		default:
+			ids[j], err = datastore.ParseStreamID(a.String(), 0)
+		}
+		if err != nil {
+			return errorReply(err)
+		}
+	}
+	results, err := ds.XRead(keys, ids, count)
+	if err != nil {
+		return errorReply(err)
+	}
+	return streamReadResultsArray(results)
+}
+
+func streamEntriesArray(entries []datastore.StreamEntry) protocol.Array {
+	items := make([]protocol.Resp, len(entries))
+	for i, e := range entries {
+		items[i] = streamEntryArray(e)
+	}
+	return protocol.Array{Items: items}
+}
+
+// streamEntryArray encodes an entry as its ID followed by its fields. Entries without fields,
+// such as pending entries deleted from the stream, have a nil array in place of the fields.
+func streamEntryArray(e datastore.StreamEntry) protocol.Array {
+	fields := protocol.Array{Items: nil}
+	if e.Fields != nil {
+		fields = bulkStringArray(e.Fields)
+	}
+	return protocol.Array{Items: []protocol.Resp{bulkString(e.ID.String()), fields}}
+}
+
+func streamReadResultsArray(results []datastore.StreamReadResult) protocol.Resp {
+	if len(results) == 0 {
+		return protocol.Array{Items: nil}
+	}
+	items := make([]protocol.Resp, len(results))
+	for i, r := range results {
+		items[i] = protocol.Array{Items: []protocol.Resp{bulkString(r.Key), streamEntriesArray(r.Entries)}}
+	}
+	return protocol.Array{Items: items}
+}
@@ -0,0 +1,410 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleXGroupCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("xgroup")
+	}
+	sub := strings.ToLower(args[0].String())
+	// arity counts the subcommand and its arguments, negative values being a minimum.
+	arity := map[string]int{"create": -4, "setid": -4, "destroy": 3, "createconsumer": 4, "delconsumer": 4}
+	n, ok := arity[sub]
+	if !ok {
+		return protocol.Error{Data: fmt.Sprintf("ERR unknown subcommand '%s'. Try XGROUP HELP.", args[0].String())}
+	}
+	if (n > 0 && len(args) != n) || (n < 0 && len(args) < -n) {
+		return wrongNumberOfArgs("xgroup|" + sub)
+	}
+	key, group := args[1].String(), args[2].String()
+	switch sub {
+	case "create", "setid":
+		var id datastore.StreamID
+		useLast := args[3].String() == "$"
+		if !useLast {
+			var err error
+			if id, err = datastore.ParseStreamID(args[3].String(), 0); err != nil {
+				return errorReply(err)
+			}
+		}
+		var mkStream bool
+		var entriesRead int64 = -1
+		for i := 4; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i].String()); {
+			case opt == "MKSTREAM" && sub == "create":
+				mkStream = true
+			case opt == "ENTRIESREAD" && i+1 < len(args):
+				var err error
+				if entriesRead, err = parseInt(args[i+1]); err != nil {
+					return errorReply(err)
+				}
+				if entriesRead < -1 {
+					return protocol.Error{Data: "ERR value for ENTRIESREAD must be positive or -1"}
+				}
+				i++
+			default:
+				return errorReply(errSyntax)
+			}
+		}
+		var err error
+		if sub == "create" {
+			err = ds.XGroupCreate(key, group, id, useLast, mkStream, entriesRead)
+		} else {
+			err = ds.XGroupSetID(key, group, id, useLast, entriesRead)
+		}
+		if err != nil {
+			return groupErrorReply("xgroup", err)
+		}
+		return protocol.SimpleString{Data: "OK"}
+	case "destroy":
+		ok, err := ds.XGroupDestroy(key, group)
+		if err != nil {
+			return errorReply(err)
+		}
+		return boolInteger(ok)
+	case "createconsumer":
+		ok, err := ds.XGroupCreateConsumer(key, group, args[3].String())
+		if err != nil {
+			return groupErrorReply("xgroup", err)
+		}
+		return boolInteger(ok)
+	default:
+		pending, err := ds.XGroupDelConsumer(key, group, args[3].String())
+		if err != nil {
+			return groupErrorReply("xgroup", err)
+		}
+		return protocol.Integer{Value: pending}
+	}
+}
+
+func handleXReadGroupCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleXRead("xreadgroup", args, ds)
+}
+
+func handleXAckCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs("xack")
+	}
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		return errorReply(err)
+	}
+	n, err := ds.XAck(args[0].String(), args[1].String(), ids...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleXPendingCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("xpending")
+	}
+	key, group := args[0].String(), args[1].String()
+	if len(args) == 2 {
+		summary, err := ds.XPendingSummary(key, group)
+		if err != nil {
+			return errorReply(err)
+		}
+		if summary.Count == 0 {
+			return protocol.Array{Items: []protocol.Resp{
+				protocol.Integer{Value: 0}, protocol.BulkString{Data: nil}, protocol.BulkString{Data: nil}, protocol.Array{Items: nil},
+			}}
+		}
+		consumers := make([]protocol.Resp, 0, len(summary.Consumers))
+		for _, name := range slices.Sorted(maps.Keys(summary.Consumers)) {
+			consumers = append(consumers, bulkStringArray([]string{name, fmt.Sprint(summary.Consumers[name])}))
+		}
+		return protocol.Array{Items: []protocol.Resp{
+			protocol.Integer{Value: summary.Count},
+			bulkString(summary.Min.String()),
+			bulkString(summary.Max.String()),
+			protocol.Array{Items: consumers},
+		}}
+	}
+	i := 2
+	var minIdle int64
+	var err error
+	if strings.ToUpper(args[i].String()) == "IDLE" {
+		if i+1 >= len(args) {
+			return errorReply(errSyntax)
+		}
+		if minIdle, err = parseInt(args[i+1]); err != nil {
+			return errorReply(err)
+		}
+		i += 2
+	}
+	if len(args)-i != 3 && len(args)-i != 4 {
+		return errorReply(errSyntax)
+	}
+	start, err := parseStreamRangeBound(args[i].String(), true)
+	if err != nil {
+		return errorReply(err)
+	}
+	end, err := parseStreamRangeBound(args[i+1].String(), false)
+	if err != nil {
+		return errorReply(err)
+	}
+	count, err := parseInt(args[i+2])
+	if err != nil {
+		return errorReply(err)
+	}
+	var consumer string
+	if len(args)-i == 4 {
+		consumer = args[i+3].String()
+	}
+	pending, err := ds.XPending(key, group, minIdle, start, end, max(count, 0), consumer)
+	if err != nil {
+		return errorReply(err)
+	}
+	now := ds.Now().UnixMilli()
+	items := make([]protocol.Resp, len(pending))
+	for j, p := range pending {
+		items[j] = protocol.Array{Items: []protocol.Resp{
+			bulkString(p.ID.String()),
+			bulkString(p.Consumer),
+			protocol.Integer{Value: now - p.DeliveryTime},
+			protocol.Integer{Value: p.DeliveryCount},
+		}}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleXClaimCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 5 {
+		return wrongNumberOfArgs("xclaim")
+	}
+	minIdle, err := parseInt(args[3])
+	if err != nil {
+		return protocol.Error{Data: "ERR Invalid min-idle-time argument for XCLAIM"}
+	}
+	i := 4
+	ids := make([]datastore.StreamID, 0)
+	for ; i < len(args); i++ {
+		id, err := datastore.ParseStreamID(args[i].String(), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	opts := datastore.XClaimOptions{DeliveryTime: -1, RetryCount: -1}
+	for ; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "FORCE":
+			opts.Force = true
+		case opt == "JUSTID":
+			opts.JustID = true
+		case opt == "IDLE" && i+1 < len(args):
+			idle, err := parseInt(args[i+1])
+			if err != nil {
+				return protocol.Error{Data: "ERR Invalid IDLE option argument for XCLAIM"}
+			}
+			opts.DeliveryTime = ds.Now().UnixMilli() - idle
+			i++
+		case opt == "TIME" && i+1 < len(args):
+			if opts.DeliveryTime, err = parseInt(args[i+1]); err != nil {
+				return protocol.Error{Data: "ERR Invalid TIME option argument for XCLAIM"}
+			}
+			i++
+		case opt == "RETRYCOUNT" && i+1 < len(args):
+			if opts.RetryCount, err = parseInt(args[i+1]); err != nil {
+				return protocol.Error{Data: "ERR Invalid RETRYCOUNT option argument for XCLAIM"}
+			}
+			i++
+		case opt == "LASTID" && i+1 < len(args):
+			id, err := datastore.ParseStreamID(args[i+1].String(), 0)
+			if err != nil {
+				return errorReply(err)
+			}
+			opts.LastID = &id
+			i++
+		default:
+			return protocol.Error{Data: fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", args[i].String())}
+		}
+	}
+	entries, err := ds.XClaim(args[0].String(), args[1].String(), args[2].String(), max(minIdle, 0), ids, opts)
+	if err != nil {
+		return errorReply(err)
+	}
+	if opts.JustID {
+		return streamIDsArray(entryIDs(entries))
+	}
+	return streamEntriesArray(entries)
+}
+
+func handleXAutoClaimCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 5 {
+		return wrongNumberOfArgs("xautoclaim")
+	}
+	minIdle, err := parseInt(args[3])
+	if err != nil {
+		return protocol.Error{Data: "ERR Invalid min-idle-time argument for XAUTOCLAIM"}
+	}
+	start, err := parseStreamRangeBound(args[4].String(), true)
+	if err != nil {
+		return errorReply(err)
+	}
+	var count int64 = 100
+	var justID bool
+	for i := 5; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "JUSTID":
+			justID = true
+		case opt == "COUNT" && i+1 < len(args):
+			if count, err = parseInt(args[i+1]); err != nil {
+				return errorReply(err)
+			}
+			if count < 1 || count > math.MaxInt64/10 {
+				return protocol.Error{Data: "ERR COUNT must be > 0"}
+			}
+			i++
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	next, claimed, deleted, err := ds.XAutoClaim(args[0].String(), args[1].String(), args[2].String(), max(minIdle, 0), start, count, justID)
+	if err != nil {
+		return errorReply(err)
+	}
+	entries := protocol.Resp(streamEntriesArray(claimed))
+	if justID {
+		entries = streamIDsArray(entryIDs(claimed))
+	}
+	return protocol.Array{Items: []protocol.Resp{bulkString(next.String()), entries, streamIDsArray(deleted)}}
+}
+
+func handleXInfoCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("xinfo")
+	}
+	sub := strings.ToLower(args[0].String())
+	switch sub {
+	case "stream":
+		if len(args) < 2 {
+			return wrongNumberOfArgs("xinfo|stream")
+		}
+		if len(args) > 2 {
+			return errorReply(errSyntax)
+		}
+		info, err := ds.XInfoStream(args[1].String())
+		if err != nil {
+			return errorReply(err)
+		}
+		first, last := protocol.Resp(protocol.BulkString{Data: nil}), protocol.Resp(protocol.BulkString{Data: nil})
+		if info.First != nil {
+			first, last = streamEntryArray(*info.First), streamEntryArray(*info.Last)
+		}
+		return protocol.Array{Items: []protocol.Resp{
+			bulkString("length"), protocol.Integer{Value: info.Length},
+			bulkString("radix-tree-keys"), protocol.Integer{Value: info.RadixTreeKeys},
+			bulkString("radix-tree-nodes"), protocol.Integer{Value: info.RadixTreeNodes},
+			bulkString("last-generated-id"), bulkString(info.LastID.String()),
+			bulkString("max-deleted-entry-id"), bulkString(info.MaxDeletedID.String()),
+			bulkString("entries-added"), protocol.Integer{Value: info.EntriesAdded},
+			bulkString("recorded-first-entry-id"), bulkString(info.FirstID.String()),
+			bulkString("groups"), protocol.Integer{Value: info.Groups},
+			bulkString("first-entry"), first,
+			bulkString("last-entry"), last,
+		}}
+	case "groups":
+		if len(args) != 2 {
+			return wrongNumberOfArgs("xinfo|groups")
+		}
+		groups, err := ds.XInfoGroups(args[1].String())
+		if err != nil {
+			return errorReply(err)
+		}
+		items := make([]protocol.Resp, len(groups))
+		for i, g := range groups {
+			items[i] = protocol.Array{Items: []protocol.Resp{
+				bulkString("name"), bulkString(g.Name),
+				bulkString("consumers"), protocol.Integer{Value: g.Consumers},
+				bulkString("pending"), protocol.Integer{Value: g.Pending},
+				bulkString("last-delivered-id"), bulkString(g.LastDeliveredID.String()),
+				bulkString("entries-read"), optionalInteger(g.EntriesRead),
+				bulkString("lag"), optionalInteger(g.Lag),
+			}}
+		}
+		return protocol.Array{Items: items}
+	case "consumers":
+		if len(args) != 3 {
+			return wrongNumberOfArgs("xinfo|consumers")
+		}
+		consumers, err := ds.XInfoConsumers(args[1].String(), args[2].String())
+		if err != nil {
+			return groupErrorReply("xinfo", err)
+		}
+		items := make([]protocol.Resp, len(consumers))
+		for i, c := range consumers {
+			items[i] = protocol.Array{Items: []protocol.Resp{
+				bulkString("name"), bulkString(c.Name),
+				bulkString("pending"), protocol.Integer{Value: c.Pending},
+				bulkString("idle"), protocol.Integer{Value: c.Idle},
+				bulkString("inactive"), protocol.Integer{Value: c.Inactive},
+			}}
+		}
+		return protocol.Array{Items: items}
+	}
+	return protocol.Error{Data: fmt.Sprintf("ERR unknown subcommand '%s'. Try XINFO HELP.", args[0].String())}
+}
+
+// groupErrorReply reports a missing consumer group with the message cmd uses for it.
+func groupErrorReply(cmd string, err error) protocol.Resp {
+	var noGroup datastore.NoGroupError
+	if !errors.As(err, &noGroup) {
+		return errorReply(err)
+	}
+	switch cmd {
+	case "xreadgroup":
+		return protocol.Error{Data: fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", noGroup.Key, noGroup.Group)}
+	case "xgroup", "xinfo":
+		return protocol.Error{Data: fmt.Sprintf("NOGROUP No such consumer group '%s' for key name '%s'", noGroup.Group, noGroup.Key)}
+	}
+	return errorReply(err)
+}
+
+func parseStreamIDs(args []protocol.Resp) ([]datastore.StreamID, error) {
+	ids := make([]datastore.StreamID, len(args))
+	for i, a := range args {
+		id, err := datastore.ParseStreamID(a.String(), 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func entryIDs(entries []datastore.StreamEntry) []datastore.StreamID {
+	ids := make([]datastore.StreamID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func streamIDsArray(ids []datastore.StreamID) protocol.Array {
+	items := make([]protocol.Resp, len(ids))
+	for i, id := range ids {
+		items[i] = bulkString(id.String())
+	}
+	return protocol.Array{Items: items}
+}
+
+// optionalInteger encodes negative values, which stand for unknown ones, as a null bulk string.
+func optionalInteger(v int64) protocol.Resp {
+	if v < 0 {
+		return protocol.BulkString{Data: nil}
+	}
+	return protocol.Integer{Value: v}
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func streamReadResult(key string, entries ...protocol.Resp) protocol.Resp {
+	return protocol.Array{Items: []protocol.Resp{bulkString(key), protocol.Array{Items: append([]protocol.Resp{}, entries...)}}}
+}
+
+func TestConsumerGroupCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("XGROUP", "CREATE", "s", "g", "$"), protocol.Error{Data: "ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."}},
+		{command("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM"), protocol.SimpleString{Data: "OK"}},
+		{command("XGROUP", "CREATE", "s", "g", "$"), protocol.Error{Data: "BUSYGROUP Consumer Group name already exists"}},
+		{command("XGROUP", "FOO", "s"), protocol.Error{Data: "ERR unknown subcommand 'FOO'. Try XGROUP HELP."}},
+		{command("XGROUP", "DESTROY", "s"), wrongNumberOfArgs("xgroup|destroy")},
+		{command("XADD", "s", "1-0", "f", "a"), bulkString("1-0")},
+		{command("XADD", "s", "2-0", "f", "b"), bulkString("2-0")},
+		{command("XREADGROUP", "GROUP", "g", "alice", "COUNT", "1", "STREAMS", "s", ">"), protocol.Array{Items: []protocol.Resp{
+			streamReadResult("s", streamEntry("1-0", "f", "a")),
+		}}},
+		{command("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", "0"), protocol.Array{Items: []protocol.Resp{
+			streamReadResult("s", streamEntry("1-0", "f", "a")),
+		}}},
+		{command("XREADGROUP", "GROUP", "g", "bob", "NOACK", "STREAMS", "s", ">"), protocol.Array{Items: []protocol.Resp{
+			streamReadResult("s", streamEntry("2-0", "f", "b")),
+		}}},
+		{command("XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"), protocol.Array{Items: nil}},
+		{command("XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", "0"), protocol.Array{Items: []protocol.Resp{streamReadResult("s")}}},
+		{command("XREADGROUP", "GROUP", "nope", "alice", "STREAMS", "s", ">"), protocol.Error{Data: "NOGROUP No such key 's' or consumer group 'nope' in XREADGROUP with GROUP option"}},
+		{command("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", "$"), protocol.Error{Data: "ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set."}},
+		{command("XREADGROUP", "COUNT", "1", "NOACK", "STREAMS", "s", ">"), protocol.Error{Data: "ERR Missing GROUP option for XREADGROUP"}},
+		{command("XREAD", "GROUP", "g", "alice", "STREAMS", "s", ">"), protocol.Error{Data: "ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead."}},
+		{command("XPENDING", "s", "g"), protocol.Array{Items: []protocol.Resp{
+			protocol.Integer{Value: 1}, bulkString("1-0"), bulkString("1-0"),
+			protocol.Array{Items: []protocol.Resp{bulkStringArray([]string{"alice", "1"})}},
+		}}},
+		{command("XCLAIM", "s", "g", "bob", "0", "1-0", "JUSTID"), bulkStringArray([]string{"1-0"})},
+		{command("XCLAIM", "s", "g", "bob", "0", "1-0", "BOGUS"), protocol.Error{Data: "ERR Unrecognized XCLAIM option 'BOGUS'"}},
+		{command("XPENDING", "s", "g", "IDLE", "0", "-", "+", "10", "alice"), protocol.Array{Items: []protocol.Resp{}}},
+		{command("XAUTOCLAIM", "s", "g", "alice", "0", "0", "COUNT", "0"), protocol.Error{Data: "ERR COUNT must be > 0"}},
+		{command("XAUTOCLAIM", "s", "g", "alice", "0", "0", "JUSTID"), protocol.Array{Items: []protocol.Resp{
+			bulkString("0-0"), bulkStringArray([]string{"1-0"}), bulkStringArray([]string{}),
+		}}},
+		{command("XACK", "s", "g", "1-0", "2-0"), protocol.Integer{Value: 1}},
+		{command("XACK", "s", "g", "x"), protocol.Error{Data: "ERR Invalid stream ID specified as stream command argument"}},
+		{command("XPENDING", "s", "g"), protocol.Array{Items: []protocol.Resp{
+			protocol.Integer{Value: 0}, protocol.BulkString{Data: nil}, protocol.BulkString{Data: nil}, protocol.Array{Items: nil},
+		}}},
+		{command("XGROUP", "CREATECONSUMER", "s", "g", "carol"), protocol.Integer{Value: 1}},
+		{command("XGROUP", "DELCONSUMER", "s", "g", "carol"), protocol.Integer{Value: 0}},
+		{command("XGROUP", "SETID", "s", "nope", "0"), protocol.Error{Data: "NOGROUP No such consumer group 'nope' for key name 's'"}},
+		{command("XGROUP", "SETID", "s", "g", "0", "ENTRIESREAD", "0"), protocol.SimpleString{Data: "OK"}},
+		{command("XINFO", "GROUPS", "s"), protocol.Array{Items: []protocol.Resp{protocol.Array{Items: []protocol.Resp{
+			bulkString("name"), bulkString("g"),
+			bulkString("consumers"), protocol.Integer{Value: 2},
+			bulkString("pending"), protocol.Integer{Value: 0},
+			bulkString("last-delivered-id"), bulkString("0-0"),
+			bulkString("entries-read"), protocol.Integer{Value: 0},
+			bulkString("lag"), protocol.Integer{Value: 2},
+		}}}}},
+		{command("XINFO", "STREAM", "missing"), protocol.Error{Data: "ERR no such key"}},
+		{command("XGROUP", "DESTROY", "s", "g"), protocol.Integer{Value: 1}},
+		{command("XGROUP", "DESTROY", "s", "g"), protocol.Integer{Value: 0}},
+	})
+}
+
+func TestXInfoStreamCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("XADD", "s", "1-0", "f", "a"), bulkString("1-0")},
+		{command("XADD", "s", "2-0", "f", "b"), bulkString("2-0")},
+		{command("XDEL", "s", "1-0"), protocol.Integer{Value: 1}},
+		{command("XINFO", "STREAM", "s"), protocol.Array{Items: []protocol.Resp{
+			bulkString("length"), protocol.Integer{Value: 1},
+			bulkString("radix-tree-keys"), protocol.Integer{Value: 1},
+			bulkString("radix-tree-nodes"), protocol.Integer{Value: 2},
+			bulkString("last-generated-id"), bulkString("2-0"),
+			bulkString("max-deleted-entry-id"), bulkString("1-0"),
+			bulkString("entries-added"), protocol.Integer{Value: 2},
+			bulkString("recorded-first-entry-id"), bulkString("2-0"),
+			bulkString("groups"), protocol.Integer{Value: 0},
+			bulkString("first-entry"), streamEntry("2-0", "f", "b"),
+			bulkString("last-entry"), streamEntry("2-0", "f", "b"),
+		}}},
+	})
+}
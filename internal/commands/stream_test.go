@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func streamEntry(id string, fields ...string) protocol.Resp {
+	return protocol.Array{Items: []protocol.Resp{bulkString(id), bulkStringArray(fields)}}
+}
+
+func TestStreamCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("XADD", "s", "1-1", "f"), wrongNumberOfArgs("xadd")},
+		{command("XADD", "s", "NOMKSTREAM", "1-1", "f", "v"), protocol.BulkString{Data: nil}},
+		{command("XADD", "s", "0-0", "f", "v"), protocol.Error{Data: "ERR The ID specified in XADD must be greater than 0-0"}},
+		{command("XADD", "s", "1-1", "f", "v1"), bulkString("1-1")},
+		{command("XADD", "s", "1-1", "f", "v"), protocol.Error{Data: "ERR The ID specified in XADD is equal or smaller than the target stream top item"}},
+		{command("XADD", "s", "1-*", "f", "v2"), bulkString("1-2")},
+		{command("XADD", "s", "2", "f", "v3"), bulkString("2-0")},
+		{command("XADD", "s", "x-1", "f", "v"), protocol.Error{Data: "ERR Invalid stream ID specified as stream command argument"}},
+		{command("XADD", "s", "3-0", "a", "1", "b", "2"), bulkString("3-0")},
+		{command("XLEN", "s"), protocol.Integer{Value: 4}},
+		{command("XRANGE", "s", "-", "+", "COUNT", "2"), protocol.Array{Items: []protocol.Resp{streamEntry("1-1", "f", "v1"), streamEntry("1-2", "f", "v2")}}},
+		{command("XRANGE", "s", "(1-1", "2"), protocol.Array{Items: []protocol.Resp{streamEntry("1-2", "f", "v2"), streamEntry("2-0", "f", "v3")}}},
+		{command("XREVRANGE", "s", "+", "2", "COUNT", "1"), protocol.Array{Items: []protocol.Resp{streamEntry("3-0", "a", "1", "b", "2")}}},
+		{command("XRANGE", "s", "5", "+"), protocol.Array{Items: []protocol.Resp{}}},
+		{command("XDEL", "s", "1-2", "9-9"), protocol.Integer{Value: 1}},
+		{command("XTRIM", "s", "MAXLEN", "2"), protocol.Integer{Value: 1}},
+		{command("XTRIM", "s", "MINID", "=", "3"), protocol.Integer{Value: 1}},
+		{command("XTRIM", "s", "MAXLEN", "~", "0"), protocol.Integer{Value: 0}},
+		{command("XTRIM", "s", "MAXLEN", "0", "LIMIT", "10"), protocol.Error{Data: "ERR syntax error, LIMIT cannot be used without the special ~ option"}},
+		{command("XTRIM", "s", "MAXLEN", "-1"), protocol.Error{Data: "ERR The MAXLEN argument must be >= 0."}},
+		{command("XRANGE", "s", "-", "+"), protocol.Array{Items: []protocol.Resp{streamEntry("3-0", "a", "1", "b", "2")}}},
+	})
+}
+
+func TestXReadCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("XADD", "s1", "1-0", "f", "a"), bulkString("1-0")},
+		{command("XADD", "s1", "2-0", "f", "b"), bulkString("2-0")},
+		{command("XADD", "s2", "1-0", "f", "c"), bulkString("1-0")},
+		{command("XREAD", "STREAMS", "s1", "s2", "1-0", "0"), protocol.Array{Items: []protocol.Resp{
+			protocol.Array{Items: []protocol.Resp{bulkString("s1"), protocol.Array{Items: []protocol.Resp{streamEntry("2-0", "f", "b")}}}},
+			protocol.Array{Items: []protocol.Resp{bulkString("s2"), protocol.Array{Items: []protocol.Resp{streamEntry("1-0", "f", "c")}}}},
+		}}},
+		{command("XREAD", "COUNT", "1", "STREAMS", "s1", "0"), protocol.Array{Items: []protocol.Resp{
+			protocol.Array{Items: []protocol.Resp{bulkString("s1"), protocol.Array{Items: []protocol.Resp{streamEntry("1-0", "f", "a")}}}},
+		}}},
+		{command("XREAD", "STREAMS", "s1", "s2", "$", "$"), protocol.Array{Items: nil}},
+		{command("XREAD", "STREAMS", "s1", "s2", "0"), protocol.Error{Data: "ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."}},
+		{command("SET", "str", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("XREAD", "STREAMS", "str", "0"), wrongType},
+		{command("XREAD", "BLOCK", "0", "STREAMS", "s1", "$"), protocol.Error{Data: "ERR BLOCK is not supported by XREAD"}},
+		{command("XADD", "str", "*", "f", "v"), wrongType},
+	})
+}
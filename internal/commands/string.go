@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func handleAppendCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("append")
+	}
+	n, err := ds.Append(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleStrLenCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("strlen")
+	}
+	n, err := ds.StrLen(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleGetRangeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("getrange")
+	}
+	start, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	end, err := parseInt(args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	s, err := ds.GetRange(args[0].String(), start, end)
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(s)
+}
+
+func handleSetRangeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("setrange")
+	}
+	offset, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	if offset < 0 {
+		return protocol.Error{Data: "ERR offset is out of range"}
+	}
+	n, err := ds.SetRange(args[0].String(), offset, args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleMGetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("mget")
+	}
+	values := ds.MGet(stringArgs(args)...)
+	items := make([]protocol.Resp, len(values))
+	for i, v := range values {
+		items[i] = protocol.BulkString{Data: v}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleMSetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return wrongNumberOfArgs("mset")
+	}
+	ds.MSet(stringArgs(args)...)
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleMSetNXCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return wrongNumberOfArgs("msetnx")
+	}
+	return boolInteger(ds.MSetNX(stringArgs(args)...))
+}
+
+func handleGetSetCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("getset")
+	}
+	old, err := ds.GetSet(args[0].String(), args[1].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.BulkString{Data: old}
+}
+
+func handleGetDelCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("getdel")
+	}
+	value, err := ds.GetDel(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.BulkString{Data: value}
+}
+
+func handleGetExCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 {
+		return wrongNumberOfArgs("getex")
+	}
+	opts, err := parseStringOptions("getex", args[1:], true)
+	if err != nil {
+		return errorReply(err)
+	}
+	value, err := ds.GetEx(args[0].String(), opts.Expiry)
+	if errors.Is(err, datastore.ErrInvalidExpireTime) {
+		return errorReply(errInvalidExpireTime("getex"))
+	} else if err != nil {
+		return errorReply(err)
+	}
+	return protocol.BulkString{Data: value}
+}
+
+// parseStringOptions parses the options of SET, or of GETEX when getEx is set. Conflicting
+// options are syntax errors, while repeating an option is allowed like in Redis.
+func parseStringOptions(cmd string, args []protocol.Resp, getEx bool) (datastore.SetOptions, error) {
+	var opts datastore.SetOptions
+	if !getEx {
+		// Unlike GETEX, SET removes the expiration unless told otherwise.
+		opts.Expiry.Mode = datastore.ExpiryPersist
+	}
+	var keepTTL, persist bool
+	var expiry string
+	var expiryArg protocol.Resp
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "NX" && !getEx && opts.Condition != datastore.SetIfExists:
+			opts.Condition = datastore.SetIfMissing
+		case opt == "XX" && !getEx && opts.Condition != datastore.SetIfMissing:
+			opts.Condition = datastore.SetIfExists
+		case opt == "GET" && !getEx:
+			opts.Get = true
+		case opt == "KEEPTTL" && !getEx && expiry == "":
+			keepTTL = true
+		case opt == "PERSIST" && getEx && expiry == "":
+			persist = true
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && !keepTTL && !persist &&
+			(expiry == "" || expiry == opt) && i+1 < len(args):
+			expiry, expiryArg = opt, args[i+1]
+			i++
+		default:
+			return opts, errSyntax
+		}
+	}
+	switch {
+	case keepTTL:
+		opts.Expiry.Mode = datastore.ExpiryKeep
+	case persist:
+		opts.Expiry.Mode = datastore.ExpiryPersist
+	case expiry != "":
+		var err error
+		if opts.Expiry, err = parseExpiry(cmd, expiry, expiryArg); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// parseExpiry parses the positive value of an EX, PX, EXAT or PXAT option.
+func parseExpiry(cmd, option string, value protocol.Resp) (datastore.Expiry, error) {
+	expiry := datastore.Expiry{Mode: datastore.ExpiryAfter}
+	multiplier := int64(1)
+	switch option {
+	case "EX":
+		multiplier = 1000
+	case "EXAT":
+		expiry.Mode, multiplier = datastore.ExpiryAt, 1000
+	case "PXAT":
+		expiry.Mode = datastore.ExpiryAt
+	}
+	v, err := parseInt(value)
+	if err != nil {
+		return expiry, err
+	}
+	if v <= 0 || v > math.MaxInt64/multiplier {
+		return expiry, errInvalidExpireTime(cmd)
+	}
+	expiry.Millis = v * multiplier
+	return expiry, nil
+}
+
+func errInvalidExpireTime(cmd string) error {
+	return fmt.Errorf("ERR invalid expire time in '%s' command", cmd)
+}
+
+func handleLCSCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("lcs")
+	}
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			getIdx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			var err error
+			if minMatchLen, err = parseInt(args[i+1]); err != nil {
+				return errorReply(err)
+			}
+			minMatchLen = max(minMatchLen, 0)
+			i++
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	if getLen && getIdx {
+		return protocol.Error{Data: "ERR If you want both the length and indexes, please just use IDX."}
+	}
+	lcs, matches, err := ds.LCS(args[0].String(), args[1].String())
+	if errors.Is(err, datastore.ErrWrongType) {
+		return protocol.Error{Data: "ERR The specified keys must contain string values"}
+	} else if err != nil {
+		return errorReply(err)
+	}
+	switch {
+	case getLen:
+		return protocol.Integer{Value: int64(len(lcs))}
+	case !getIdx:
+		return bulkString(lcs)
+	}
+	items := make([]protocol.Resp, 0, len(matches))
+	for _, m := range matches {
+		if m.Len() < minMatchLen {
+			continue
+		}
+		item := []protocol.Resp{
+			protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: m.AStart}, protocol.Integer{Value: m.AEnd}}},
+			protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: m.BStart}, protocol.Integer{Value: m.BEnd}}},
+		}
+		if withMatchLen {
+			item = append(item, protocol.Integer{Value: m.Len()})
+		}
+		items = append(items, protocol.Array{Items: item})
+	}
+	return protocol.Array{Items: []protocol.Resp{
+		bulkString("matches"), protocol.Array{Items: items},
+		bulkString("len"), protocol.Integer{Value: int64(len(lcs))},
+	}}
+}
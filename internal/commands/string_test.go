@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestStringCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("APPEND", "k"), wrongNumberOfArgs("append")},
+		{command("APPEND", "k", "Hello"), protocol.Integer{Value: 5}},
+		{command("APPEND", "k", " World"), protocol.Integer{Value: 11}},
+		{command("STRLEN", "k"), protocol.Integer{Value: 11}},
+		{command("STRLEN", "missing"), protocol.Integer{Value: 0}},
+		{command("GETRANGE", "k", "0", "4"), bulkString("Hello")},
+		{command("GETRANGE", "k", "-5", "-1"), bulkString("World")},
+		{command("GETRANGE", "k", "5", "1"), bulkString("")},
+		{command("GETRANGE", "k", "0", "100"), bulkString("Hello World")},
+		{command("GETRANGE", "missing", "0", "-1"), bulkString("")},
+		{command("SETRANGE", "k", "6", "Redis"), protocol.Integer{Value: 11}},
+		{command("GET", "k"), bulkString("Hello Redis")},
+		{command("SETRANGE", "pad", "3", "x"), protocol.Integer{Value: 4}},
+		{command("GET", "pad"), bulkString("\x00\x00\x00x")},
+		{command("SETRANGE", "empty", "3", ""), protocol.Integer{Value: 0}},
+		{command("EXISTS", "empty"), protocol.Integer{Value: 0}},
+		{command("SETRANGE", "k", "-1", "x"), protocol.Error{Data: "ERR offset is out of range"}},
+		{command("SETRANGE", "k", "536870911", "xx"), protocol.Error{Data: "ERR string exceeds maximum allowed size (proto-max-bulk-len)"}},
+		{command("SETRANGE", "k", "9223372036854775807", "x"), protocol.Error{Data: "ERR string exceeds maximum allowed size (proto-max-bulk-len)"}},
+		{command("SET", "n", "10"), protocol.SimpleString{Data: "OK"}},
+		{command("APPEND", "n", "5"), protocol.Integer{Value: 3}},
+		{command("INCR", "n"), protocol.Integer{Value: 106}},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("APPEND", "list", "a"), wrongType},
+		{command("STRLEN", "list"), wrongType},
+		{command("GETRANGE", "list", "0", "1"), wrongType},
+	})
+}
+
+func TestMultiKeyStringCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	nilBulk := protocol.BulkString{Data: nil}
+	runSequence(t, ds, []step{
+		{command("MSET", "a", "1", "b"), wrongNumberOfArgs("mset")},
+		{command("MSET", "a", "1", "b", "2", "a", "3"), protocol.SimpleString{Data: "OK"}},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("MGET", "a", "b", "missing", "list"), protocol.Array{Items: []protocol.Resp{bulkString("3"), bulkString("2"), nilBulk, nilBulk}}},
+		{command("MSETNX", "c", "1", "a", "2"), protocol.Integer{Value: 0}},
+		{command("EXISTS", "c"), protocol.Integer{Value: 0}},
+		{command("MSETNX", "c", "1", "d", "2"), protocol.Integer{Value: 1}},
+		{command("MGET", "c", "d"), bulkStringArray([]string{"1", "2"})},
+		{command("GETSET", "a", "4"), bulkString("3")},
+		{command("GETSET", "new", "1"), nilBulk},
+		{command("GETSET", "list", "1"), wrongType},
+		{command("GETDEL", "a"), bulkString("4")},
+		{command("GETDEL", "a"), nilBulk},
+		{command("GETDEL", "list"), wrongType},
+	})
+}
+
+func TestGetExCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("SET", "k", "v", "EX", "100"), protocol.SimpleString{Data: "OK"}},
+		{command("GETEX", "k", "PERSIST", "EX", "1"), errorReply(errSyntax)},
+		{command("GETEX", "k", "KEEPTTL"), errorReply(errSyntax)},
+		{command("GETEX", "k", "EX", "0"), protocol.Error{Data: "ERR invalid expire time in 'getex' command"}},
+		{command("GETEX", "k", "EX", "x"), errorReply(datastore.ErrNotInteger)},
+		{command("GETEX", "k", "EX", "9223372036854775807"), protocol.Error{Data: "ERR invalid expire time in 'getex' command"}},
+		{command("GETEX", "k", "PX", "9223372036854775807"), protocol.Error{Data: "ERR invalid expire time in 'getex' command"}},
+		{command("GETEX", "k", "PERSIST"), bulkString("v")},
+		{command("GETEX", "k", "PX", "100000"), bulkString("v")},
+		{command("GETEX", "k"), bulkString("v")},
+		{command("GETEX", "k", "EXAT", "1"), bulkString("v")},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+		{command("GETEX", "k"), protocol.BulkString{Data: nil}},
+	})
+}
+
+func TestLCSCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	match := func(a1, a2, b1, b2 int64, length ...int64) protocol.Resp {
+		items := []protocol.Resp{integerArray(a1, a2), integerArray(b1, b2)}
+		for _, l := range length {
+			items = append(items, protocol.Integer{Value: l})
+		}
+		return protocol.Array{Items: items}
+	}
+	runSequence(t, ds, []step{
+		{command("MSET", "key1", "ohmytext", "key2", "mynewtext"), protocol.SimpleString{Data: "OK"}},
+		{command("LCS", "key1", "key2"), bulkString("mytext")},
+		{command("LCS", "key1", "key2", "LEN"), protocol.Integer{Value: 6}},
+		{command("LCS", "key1", "key2", "IDX"), protocol.Array{Items: []protocol.Resp{
+			bulkString("matches"), protocol.Array{Items: []protocol.Resp{match(4, 7, 5, 8), match(2, 3, 0, 1)}},
+			bulkString("len"), protocol.Integer{Value: 6},
+		}}},
+		{command("LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"), protocol.Array{Items: []protocol.Resp{
+			bulkString("matches"), protocol.Array{Items: []protocol.Resp{match(4, 7, 5, 8, 4)}},
+			bulkString("len"), protocol.Integer{Value: 6},
+		}}},
+		{command("LCS", "key1", "missing"), bulkString("")},
+		{command("SET", "big1", strings.Repeat("a", 12000)), protocol.SimpleString{Data: "OK"}},
+		{command("SET", "big2", strings.Repeat("b", 12000)), protocol.SimpleString{Data: "OK"}},
+		{command("LCS", "big1", "big2"), protocol.Error{Data: "ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len"}},
+		{command("LCS", "key1", "key2", "LEN", "IDX"), protocol.Error{Data: "ERR If you want both the length and indexes, please just use IDX."}},
+		{command("LCS", "key1", "key2", "MINMATCHLEN"), errorReply(errSyntax)},
+		{command("RPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("LCS", "key1", "list"), protocol.Error{Data: "ERR The specified keys must contain string values"}},
+	})
+}
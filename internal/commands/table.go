@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+// commandHandler executes a command on behalf of a client.
+type commandHandler func(args []protocol.Resp, c *Client) protocol.Resp
+
+type commandFlags int
+
+const (
+	// flagTransaction marks the commands controlling transactions, which are executed right
+	// away instead of being queued after MULTI, without holding the lock of the databases.
+	flagTransaction commandFlags = 1 << iota
+)
+
+// redisCommand describes a command like the command table of Redis.
+type redisCommand struct {
+	handler commandHandler
+	// arity is the number of arguments including the command name, or its opposite when it
+	// is the minimum number of arguments.
+	arity int
+	flags commandFlags
+}
+
+// commandTable maps the lowercase command names to their commands.
+var commandTable = map[string]*redisCommand{
+	"ping":             {handler: handlePingCommand, arity: -1},
+	"echo":             {handler: stateless(handleEchoCommand), arity: 2},
+	"set":              {handler: onDatastore(handleSetCommand), arity: -3},
+	"get":              {handler: onDatastore(handleGetCommand), arity: 2},
+	"del":              {handler: onDatastore(handleDelCommand), arity: -2},
+	"exists":           {handler: onDatastore(handleExistsCommand), arity: -2},
+	"incr":             {handler: onDatastore(handleIncrCommand), arity: 2},
+	"decr":             {handler: onDatastore(handleDecrCommand), arity: 2},
+	"incrby":           {handler: onDatastore(handleIncrByCommand), arity: 3},
+	"decrby":           {handler: onDatastore(handleDecrByCommand), arity: 3},
+	"incrbyfloat":      {handler: onDatastore(handleIncrByFloatCommand), arity: 3},
+	"expire":           {handler: onDatastore(handleExpireCommand), arity: -3},
+	"pexpire":          {handler: onDatastore(handlePExpireCommand), arity: -3},
+	"expireat":         {handler: onDatastore(handleExpireAtCommand), arity: -3},
+	"pexpireat":        {handler: onDatastore(handlePExpireAtCommand), arity: -3},
+	"ttl":              {handler: onDatastore(handleTTLCommand), arity: 2},
+	"pttl":             {handler: onDatastore(handlePTTLCommand), arity: 2},
+	"expiretime":       {handler: onDatastore(handleExpireTimeCommand), arity: 2},
+	"pexpiretime":      {handler: onDatastore(handlePExpireTimeCommand), arity: 2},
+	"persist":          {handler: onDatastore(handlePersistCommand), arity: 2},
+	"keys":             {handler: onDatastore(handleKeysCommand), arity: 2},
+	"scan":             {handler: onDatastore(handleScanCommand), arity: -2},
+	"type":             {handler: onDatastore(handleTypeCommand), arity: 2},
+	"randomkey":        {handler: onDatastore(handleRandomKeyCommand), arity: 1},
+	"dbsize":           {handler: onDatastore(handleDBSizeCommand), arity: 1},
+	"rename":           {handler: onDatastore(handleRenameCommand), arity: 3},
+	"renamenx":         {handler: onDatastore(handleRenameNXCommand), arity: 3},
+	"copy":             {handler: handleCopyCommand, arity: -3},
+	"move":             {handler: handleMoveCommand, arity: 3},
+	"select":           {handler: handleSelectCommand, arity: 2},
+	"swapdb":           {handler: handleSwapDBCommand, arity: 3},
+	"flushdb":          {handler: onDatastore(handleFlushDBCommand), arity: -1},
+	"flushall":         {handler: handleFlushAllCommand, arity: -1},
+	"touch":            {handler: onDatastore(handleTouchCommand), arity: -2},
+	"unlink":           {handler: onDatastore(handleUnlinkCommand), arity: -2},
+	"sort":             {handler: onDatastore(handleSortCommand), arity: -2},
+	"sort_ro":          {handler: onDatastore(handleSortROCommand), arity: -2},
+	"append":           {handler: onDatastore(handleAppendCommand), arity: 3},
+	"strlen":           {handler: onDatastore(handleStrLenCommand), arity: 2},
+	"getrange":         {handler: onDatastore(handleGetRangeCommand), arity: 4},
+	"setrange":         {handler: onDatastore(handleSetRangeCommand), arity: 4},
+	"mget":             {handler: onDatastore(handleMGetCommand), arity: -2},
+	"mset":             {handler: onDatastore(handleMSetCommand), arity: -3},
+	"msetnx":           {handler: onDatastore(handleMSetNXCommand), arity: -3},
+	"getset":           {handler: onDatastore(handleGetSetCommand), arity: 3},
+	"getdel":           {handler: onDatastore(handleGetDelCommand), arity: 2},
+	"getex":            {handler: onDatastore(handleGetExCommand), arity: -2},
+	"lcs":              {handler: onDatastore(handleLCSCommand), arity: -3},
+	"lpush":            {handler: onDatastore(handleLPushCommand), arity: -3},
+	"rpush":            {handler: onDatastore(handleRPushCommand), arity: -3},
+	"lpop":             {handler: onDatastore(handleLPopCommand), arity: -2},
+	"rpop":             {handler: onDatastore(handleRPopCommand), arity: -2},
+	"lrange":           {handler: onDatastore(handleLRangeCommand), arity: 4},
+	"llen":             {handler: onDatastore(handleLLenCommand), arity: 2},
+	"lindex":           {handler: onDatastore(handleLIndexCommand), arity: 3},
+	"lset":             {handler: onDatastore(handleLSetCommand), arity: 4},
+	"lrem":             {handler: onDatastore(handleLRemCommand), arity: 4},
+	"ltrim":            {handler: onDatastore(handleLTrimCommand), arity: 4},
+	"linsert":          {handler: onDatastore(handleLInsertCommand), arity: 5},
+	"blpop":            {handler: handleBLPopCommand, arity: -3},
+	"brpop":            {handler: handleBRPopCommand, arity: -3},
+	"blmove":           {handler: handleBLMoveCommand, arity: 6},
+	"brpoplpush":       {handler: handleBRPopLPushCommand, arity: 4},
+	"blmpop":           {handler: handleBLMPopCommand, arity: -5},
+	"hset":             {handler: onDatastore(handleHSetCommand), arity: -4},
+	"hsetnx":           {handler: onDatastore(handleHSetNXCommand), arity: 4},
+	"hget":             {handler: onDatastore(handleHGetCommand), arity: 3},
+	"hmget":            {handler: onDatastore(handleHMGetCommand), arity: -3},
+	"hgetall":          {handler: onDatastore(handleHGetAllCommand), arity: 2},
+	"hkeys":            {handler: onDatastore(handleHKeysCommand), arity: 2},
+	"hvals":            {handler: onDatastore(handleHValsCommand), arity: 2},
+	"hdel":             {handler: onDatastore(handleHDelCommand), arity: -3},
+	"hexists":          {handler: onDatastore(handleHExistsCommand), arity: 3},
+	"hlen":             {handler: onDatastore(handleHLenCommand), arity: 2},
+	"hstrlen":          {handler: onDatastore(handleHStrLenCommand), arity: 3},
+	"hincrby":          {handler: onDatastore(handleHIncrByCommand), arity: 4},
+	"hincrbyfloat":     {handler: onDatastore(handleHIncrByFloatCommand), arity: 4},
+	"sadd":             {handler: onDatastore(handleSAddCommand), arity: -3},
+	"srem":             {handler: onDatastore(handleSRemCommand), arity: -3},
+	"smembers":         {handler: onDatastore(handleSMembersCommand), arity: 2},
+	"sismember":        {handler: onDatastore(handleSIsMemberCommand), arity: 3},
+	"smismember":       {handler: onDatastore(handleSMIsMemberCommand), arity: -3},
+	"scard":            {handler: onDatastore(handleSCardCommand), arity: 2},
+	"spop":             {handler: onDatastore(handleSPopCommand), arity: -2},
+	"srandmember":      {handler: onDatastore(handleSRandMemberCommand), arity: -2},
+	"sinter":           {handler: onDatastore(handleSInterCommand), arity: -2},
+	"sunion":           {handler: onDatastore(handleSUnionCommand), arity: -2},
+	"sdiff":            {handler: onDatastore(handleSDiffCommand), arity: -2},
+	"sinterstore":      {handler: onDatastore(handleSInterStoreCommand), arity: -3},
+	"sunionstore":      {handler: onDatastore(handleSUnionStoreCommand), arity: -3},
+	"sdiffstore":       {handler: onDatastore(handleSDiffStoreCommand), arity: -3},
+	"sintercard":       {handler: onDatastore(handleSInterCardCommand), arity: -3},
+	"smove":            {handler: onDatastore(handleSMoveCommand), arity: 4},
+	"zadd":             {handler: onDatastore(handleZAddCommand), arity: -4},
+	"zincrby":          {handler: onDatastore(handleZIncrByCommand), arity: 4},
+	"zrem":             {handler: onDatastore(handleZRemCommand), arity: -3},
+	"zcard":            {handler: onDatastore(handleZCardCommand), arity: 2},
+	"zscore":           {handler: onDatastore(handleZScoreCommand), arity: 3},
+	"zrank":            {handler: onDatastore(handleZRankCommand), arity: -3},
+	"zcount":           {handler: onDatastore(handleZCountCommand), arity: 4},
+	"zrange":           {handler: onDatastore(handleZRangeCommand), arity: -4},
+	"zremrangebyrank":  {handler: onDatastore(handleZRemRangeByRankCommand), arity: 4},
+	"zremrangebyscore": {handler: onDatastore(handleZRemRangeByScoreCommand), arity: 4},
+	"zremrangebylex":   {handler: onDatastore(handleZRemRangeByLexCommand), arity: 4},
+	"zpopmin":          {handler: onDatastore(handleZPopMinCommand), arity: -2},
+	"zpopmax":          {handler: onDatastore(handleZPopMaxCommand), arity: -2},
+	"zrandmember":      {handler: onDatastore(handleZRandMemberCommand), arity: -2},
+	"zmscore":          {handler: onDatastore(handleZMScoreCommand), arity: -3},
+	"zunionstore":      {handler: onDatastore(handleZUnionStoreCommand), arity: -4},
+	"zinterstore":      {handler: onDatastore(handleZInterStoreCommand), arity: -4},
+	"zdiffstore":       {handler: onDatastore(handleZDiffStoreCommand), arity: -4},
+	"zrangestore":      {handler: onDatastore(handleZRangeStoreCommand), arity: -5},
+	"xadd":             {handler: onDatastore(handleXAddCommand), arity: -5},
+	"xtrim":            {handler: onDatastore(handleXTrimCommand), arity: -4},
+	"xdel":             {handler: onDatastore(handleXDelCommand), arity: -3},
+	"xlen":             {handler: onDatastore(handleXLenCommand), arity: 2},
+	"xrange":           {handler: onDatastore(handleXRangeCommand), arity: -4},
+	"xrevrange":        {handler: onDatastore(handleXRevRangeCommand), arity: -4},
+	"xread":            {handler: onDatastore(handleXReadCommand), arity: -4},
+	"xgroup":           {handler: onDatastore(handleXGroupCommand), arity: -2},
+	"xreadgroup":       {handler: onDatastore(handleXReadGroupCommand), arity: -7},
+	"xack":             {handler: onDatastore(handleXAckCommand), arity: -4},
+	"xpending":         {handler: onDatastore(handleXPendingCommand), arity: -3},
+	"xclaim":           {handler: onDatastore(handleXClaimCommand), arity: -6},
+	"xautoclaim":       {handler: onDatastore(handleXAutoClaimCommand), arity: -6},
+	"xinfo":            {handler: onDatastore(handleXInfoCommand), arity: -2},
+	"pfadd":            {handler: onDatastore(handlePFAddCommand), arity: -2},
+	"pfcount":          {handler: onDatastore(handlePFCountCommand), arity: -2},
+	"pfmerge":          {handler: onDatastore(handlePFMergeCommand), arity: -2},
+	"setbit":           {handler: onDatastore(handleSetBitCommand), arity: 4},
+	"getbit":           {handler: onDatastore(handleGetBitCommand), arity: 3},
+	"bitcount":         {handler: onDatastore(handleBitCountCommand), arity: -2},
+	"bitpos":           {handler: onDatastore(handleBitPosCommand), arity: -3},
+	"bitop":            {handler: onDatastore(handleBitOpCommand), arity: -4},
+	"bitfield":         {handler: onDatastore(handleBitFieldCommand), arity: -2},
+	"bitfield_ro":      {handler: onDatastore(handleBitFieldROCommand), arity: -2},
+	"geoadd":           {handler: onDatastore(handleGeoAddCommand), arity: -5},
+	"geodist":          {handler: onDatastore(handleGeoDistCommand), arity: -4},
+	"geopos":           {handler: onDatastore(handleGeoPosCommand), arity: -2},
+	"geohash":          {handler: onDatastore(handleGeoHashCommand), arity: -2},
+	"geosearch":        {handler: onDatastore(handleGeoSearchCommand), arity: -7},
+	"geosearchstore":   {handler: onDatastore(handleGeoSearchStoreCommand), arity: -8},
+	"multi":            {handler: handleMultiCommand, arity: 1, flags: flagTransaction},
+	"exec":             {handler: handleExecCommand, arity: 1, flags: flagTransaction},
+	"discard":          {handler: handleDiscardCommand, arity: 1, flags: flagTransaction},
+	"watch":            {handler: handleWatchCommand, arity: -2, flags: flagTransaction},
+	"unwatch":          {handler: handleUnwatchCommand, arity: 1},
+}
+
+// subscriberCommands are the commands allowed in subscriber mode.
+var subscriberCommands = map[string]bool{
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+	"ping":         true,
+	"quit":         true,
+	"reset":        true,
+}
+
+// subscriptionCommands are the commands changing the pub/sub subscriptions, whose replies are
+// sent as several messages and cannot be part of the reply of EXEC.
+var subscriptionCommands = map[string]bool{
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+}
+
+// stateless adapts a handler that does not depend on the client.
+func stateless(h func([]protocol.Resp) protocol.Resp) commandHandler {
+	return func(args []protocol.Resp, _ *Client) protocol.Resp {
+		return h(args)
+	}
+}
+
+// onDatastore adapts a handler of the database selected by the client.
+func onDatastore(h func([]protocol.Resp, *datastore.Datastore) protocol.Resp) commandHandler {
+	return func(args []protocol.Resp, c *Client) protocol.Resp {
+		return h(args, c.selected())
+	}
+}
+
+// validArity reports whether the command accepts n arguments, including its name.
+func (cmd *redisCommand) validArity(n int) bool {
+	return cmd.arity == n || (cmd.arity < 0 && n >= -cmd.arity)
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var errExecAbort = protocol.Error{Data: "EXECABORT Transaction discarded because of previous errors."}
+
+func handleMultiCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if c.multi {
+		return protocol.Error{Data: "ERR MULTI calls can not be nested"}
+	}
+	c.multi = true
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleExecCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if !c.multi {
+		return protocol.Error{Data: "ERR EXEC without MULTI"}
+	}
+	queued, abort := c.queued, c.execAbort
+	c.discardMulti()
+	defer c.unwatch()
+	if abort {
+		return errExecAbort
+	}
+	c.dbs.mu.Lock()
+	defer c.dbs.mu.Unlock()
+	// Looking the watched keys up flags those which expired since they were watched.
+	for _, w := range c.watched {
+		w.db.Exists(w.key)
+	}
+	if c.watch != nil && c.watch.Dirty() {
+		return protocol.Array{}
+	}
+	c.exec = true
+	defer func() { c.exec = false }()
+	replies := make([]protocol.Resp, len(queued))
+	for i, q := range queued {
+		replies[i] = q.cmd.handler(q.args, c)
+	}
+	c.dbs.serveBlocked()
+	return protocol.Array{Items: replies}
+}
+
+func handleDiscardCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if !c.multi {
+		return protocol.Error{Data: "ERR DISCARD without MULTI"}
+	}
+	c.discardMulti()
+	c.unwatch()
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleWatchCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	if c.multi {
+		return protocol.Error{Data: "ERR WATCH inside MULTI is not allowed"}
+	}
+	c.dbs.mu.RLock()
+	defer c.dbs.mu.RUnlock()
+	if c.watch == nil {
+		c.watch = datastore.NewWatch()
+	}
+	ds := c.selected()
+	for _, key := range stringArgs(args) {
+		w := watchedKey{db: ds, key: key}
+		if c.watching(w) {
+			continue
+		}
+		ds.Watch(c.watch, key)
+		c.watched = append(c.watched, w)
+	}
+	return protocol.SimpleString{Data: "OK"}
+}
+
+func handleUnwatchCommand(args []protocol.Resp, c *Client) protocol.Resp {
+	c.unwatch()
+	return protocol.SimpleString{Data: "OK"}
+}
+
+// abortMulti makes the transaction of the client fail on EXEC, if there is one.
+func (c *Client) abortMulti() {
+	if c.multi {
+		c.execAbort = true
+	}
+}
+
+// discardMulti ends the transaction of the client, dropping the queued commands.
+func (c *Client) discardMulti() {
+	c.multi = false
+	c.queued = nil
+	c.execAbort = false
+}
+
+// watching reports whether the client already watches the key.
+func (c *Client) watching(w watchedKey) bool {
+	for _, watched := range c.watched {
+		if watched == w {
+			return true
+		}
+	}
+	return false
+}
+
+// unwatch releases the keys watched by the client.
+func (c *Client) unwatch() {
+	for _, w := range c.watched {
+		w.db.Unwatch(c.watch, w.key)
+	}
+	c.watch = nil
+	c.watched = nil
+}
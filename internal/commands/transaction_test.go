@@ -0,0 +1,236 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var (
+	ok     = protocol.SimpleString{Data: "OK"}
+	queued = protocol.SimpleString{Data: "QUEUED"}
+)
+
+func TestMultiExec(t *testing.T) {
+	c := NewClient(newDatabases(16))
+	runClientSequence(t, c, []step{
+		{command("MULTI"), ok},
+		{command("MULTI"), protocol.Error{Data: "ERR MULTI calls can not be nested"}},
+		{command("SET", "k", "1"), queued},
+		{command("INCR", "k"), queued},
+		{command("LPUSH", "k", "a"), queued},
+		{command("SELECT", "1"), queued},
+		{command("GET", "k"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{
+			ok,
+			protocol.Integer{Value: 2},
+			wrongType,
+			ok,
+			protocol.BulkString{Data: nil},
+		}}},
+		{command("EXEC"), protocol.Error{Data: "ERR EXEC without MULTI"}},
+		{command("SELECT", "0"), ok},
+		{command("GET", "k"), bulkString("2")},
+		{command("MULTI"), ok},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{}}},
+	})
+}
+
+func TestDiscard(t *testing.T) {
+	c := NewClient(newDatabases(1))
+	runClientSequence(t, c, []step{
+		{command("DISCARD"), protocol.Error{Data: "ERR DISCARD without MULTI"}},
+		{command("MULTI"), ok},
+		{command("SET", "k", "v"), queued},
+		{command("DISCARD"), ok},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+		{command("EXEC"), protocol.Error{Data: "ERR EXEC without MULTI"}},
+	})
+}
+
+func TestExecAbort(t *testing.T) {
+	c := NewClient(newDatabases(1))
+	runClientSequence(t, c, []step{
+		{command("MULTI"), ok},
+		{command("SET", "k", "v"), queued},
+		{command("NOPE", "x"), handleUnknownCommand("nope", command("x").Items)},
+		{command("GET"), wrongNumberOfArgs("get")},
+		{command("EXEC"), errExecAbort},
+		{command("EXISTS", "k"), protocol.Integer{Value: 0}},
+		{command("MULTI"), ok},
+		{command("SET", "k", "v"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{ok}}},
+	})
+}
+
+func TestWatch(t *testing.T) {
+	dbs := newDatabases(16)
+	c, other := NewClient(dbs), NewClient(dbs)
+	runClientSequence(t, c, []step{
+		{command("WATCH", "k", "k"), ok},
+		{command("MULTI"), ok},
+		{command("WATCH", "k"), protocol.Error{Data: "ERR WATCH inside MULTI is not allowed"}},
+		{command("SET", "k", "mine"), queued},
+	})
+	runClientSequence(t, other, []step{
+		{command("SET", "k", "theirs"), ok},
+	})
+	runClientSequence(t, c, []step{
+		{command("EXEC"), protocol.Array{}},
+		{command("GET", "k"), bulkString("theirs")},
+		// The keys are no longer watched after EXEC.
+		{command("MULTI"), ok},
+		{command("SET", "k", "mine"), queued},
+	})
+	runClientSequence(t, other, []step{
+		{command("SET", "k", "theirs"), ok},
+	})
+	runClientSequence(t, c, []step{
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{ok}}},
+		{command("GET", "k"), bulkString("mine")},
+	})
+}
+
+func TestWatchIgnoresReadsAndOwnWrites(t *testing.T) {
+	dbs := newDatabases(16)
+	c, other := NewClient(dbs), NewClient(dbs)
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "v"), ok},
+		{command("WATCH", "k"), ok},
+		{command("SET", "other", "v"), ok},
+	})
+	runClientSequence(t, other, []step{
+		{command("GET", "k"), bulkString("v")},
+		{command("SET", "k2", "v"), ok},
+		{command("SELECT", "1"), ok},
+		{command("SET", "k", "v"), ok},
+	})
+	runClientSequence(t, c, []step{
+		{command("MULTI"), ok},
+		{command("INCR", "other"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{errorReply(datastore.ErrNotInteger)}}},
+	})
+}
+
+func TestUnwatch(t *testing.T) {
+	dbs := newDatabases(1)
+	c, other := NewClient(dbs), NewClient(dbs)
+	runClientSequence(t, c, []step{
+		{command("WATCH", "k"), ok},
+		{command("UNWATCH"), ok},
+	})
+	runClientSequence(t, other, []step{
+		{command("SET", "k", "v"), ok},
+	})
+	runClientSequence(t, c, []step{
+		{command("MULTI"), ok},
+		{command("GET", "k"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{bulkString("v")}}},
+		// DISCARD also unwatches the keys.
+		{command("WATCH", "k"), ok},
+		{command("MULTI"), ok},
+		{command("DISCARD"), ok},
+	})
+	runClientSequence(t, other, []step{
+		{command("DEL", "k"), protocol.Integer{Value: 1}},
+	})
+	runClientSequence(t, c, []step{
+		{command("MULTI"), ok},
+		{command("EXISTS", "k"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: 0}}}},
+	})
+}
+
+func TestWatchSignaledByDatabaseCommands(t *testing.T) {
+	tests := map[string][]protocol.Array{
+		"flushdb":  {command("FLUSHDB")},
+		"flushall": {command("FLUSHALL")},
+		"swapdb":   {command("SWAPDB", "0", "1")},
+		"move":     {command("MOVE", "k", "1")},
+		"copy":     {command("SELECT", "1"), command("SET", "k", "v"), command("COPY", "k", "k", "DB", "0", "REPLACE")},
+		"rename":   {command("RENAME", "k", "k2")},
+	}
+	for name, cmds := range tests {
+		t.Run(name, func(t *testing.T) {
+			dbs := newDatabases(16)
+			c, other := NewClient(dbs), NewClient(dbs)
+			runClientSequence(t, c, []step{
+				{command("SET", "k", "v"), ok},
+				{command("WATCH", "k"), ok},
+			})
+			for _, cmd := range cmds {
+				HandleCommand(cmd, other)
+			}
+			runClientSequence(t, c, []step{
+				{command("MULTI"), ok},
+				{command("PING"), queued},
+				{command("EXEC"), protocol.Array{}},
+			})
+		})
+	}
+}
+
+func TestWatchIgnoresNoOpWrites(t *testing.T) {
+	dbs := newDatabases(1)
+	c, other := NewClient(dbs), NewClient(dbs)
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "v"), ok},
+		{command("WATCH", "k", "missing"), ok},
+	})
+	runClientSequence(t, other, []step{
+		{command("SET", "k", "w", "NX"), protocol.BulkString{}},
+		{command("LPOP", "missing"), protocol.BulkString{}},
+		{command("DEL", "missing"), protocol.Integer{Value: 0}},
+		{command("EXPIRE", "missing", "10"), protocol.Integer{Value: 0}},
+		{command("PERSIST", "k"), protocol.Integer{Value: 0}},
+		{command("SREM", "missing", "a"), protocol.Integer{Value: 0}},
+	})
+	runClientSequence(t, c, []step{
+		{command("MULTI"), ok},
+		{command("GET", "k"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{bulkString("v")}}},
+	})
+}
+
+func TestWatchExpiredKey(t *testing.T) {
+	clock := datastore.NewManualClock(time.UnixMilli(1000000))
+	ds := datastore.NewDatastore(datastore.WithClock(clock))
+	c := NewClient(NewDatabases(ds))
+	runClientSequence(t, c, []step{
+		{command("SET", "k", "v", "PX", "100"), ok},
+		{command("WATCH", "k"), ok},
+		{command("MULTI"), ok},
+		{command("PING"), queued},
+	})
+	clock.Advance(100 * time.Millisecond)
+	runClientSequence(t, c, []step{
+		{command("EXEC"), protocol.Array{}},
+		// A key which has already expired when watched is not modified by its deletion.
+		{command("SET", "k", "v", "PX", "100"), ok},
+	})
+	clock.Advance(100 * time.Millisecond)
+	runClientSequence(t, c, []step{
+		{command("WATCH", "k"), ok},
+		{command("MULTI"), ok},
+		{command("PING"), queued},
+		{command("EXEC"), protocol.Array{Items: []protocol.Resp{protocol.SimpleString{Data: "PONG"}}}},
+	})
+}
+
+func TestClientClose(t *testing.T) {
+	dbs := newDatabases(1)
+	c, other := NewClient(dbs), NewClient(dbs)
+	runClientSequence(t, c, []step{
+		{command("WATCH", "k"), ok},
+	})
+	watch := c.watch
+	c.Close()
+	runClientSequence(t, other, []step{
+		{command("SET", "k", "v"), ok},
+	})
+	if watch.Dirty() {
+		t.Errorf("Expected the keys of a closed client not to be watched")
+	}
+}
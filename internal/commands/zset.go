@@ -0,0 +1,533 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+var (
+	errMinMaxNotFloat  = errors.New("ERR min or max is not a float")
+	errMinMaxNotString = errors.New("ERR min or max not valid string range item")
+)
+
+func handleZAddCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs("zadd")
+	}
+	var opts datastore.ZAddOptions
+	var ch, incr bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].String()) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errorReply(errSyntax)
+	}
+	if opts.NX && opts.XX {
+		return protocol.Error{Data: "ERR XX and NX options at the same time are not compatible"}
+	}
+	if (opts.GT && opts.NX) || (opts.LT && opts.NX) || (opts.GT && opts.LT) {
+		return protocol.Error{Data: "ERR GT, LT, and/or NX options at the same time are not compatible"}
+	}
+	if incr && len(pairs) > 2 {
+		return protocol.Error{Data: "ERR INCR option supports a single increment-element pair"}
+	}
+	members := make([]datastore.ScoredMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseFloat(pairs[j])
+		if err != nil {
+			return errorReply(err)
+		}
+		members = append(members, datastore.ScoredMember{Member: pairs[j+1].String(), Score: score})
+	}
+	key := args[0].String()
+	if incr {
+		score, ok, err := ds.ZIncrBy(key, opts, members[0].Score, members[0].Member)
+		if err != nil {
+			return errorReply(err)
+		}
+		if !ok {
+			return protocol.BulkString{Data: nil}
+		}
+		return bulkString(formatScore(score))
+	}
+	added, updated, err := ds.ZAdd(key, opts, members)
+	if err != nil {
+		return errorReply(err)
+	}
+	if ch {
+		return protocol.Integer{Value: added + updated}
+	}
+	return protocol.Integer{Value: added}
+}
+
+func handleZIncrByCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("zincrby")
+	}
+	increment, err := parseFloat(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	score, _, err := ds.ZIncrBy(args[0].String(), datastore.ZAddOptions{}, increment, args[2].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(formatScore(score))
+}
+
+func handleZRemCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("zrem")
+	}
+	n, err := ds.ZRem(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleZCardCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 1 {
+		return wrongNumberOfArgs("zcard")
+	}
+	n, err := ds.ZCard(args[0].String())
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleZScoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 2 {
+		return wrongNumberOfArgs("zscore")
+	}
+	score, err := ds.ZScore(args[0].String(), args[1].String())
+	if errors.Is(err, datastore.ErrNotFound) {
+		return protocol.BulkString{Data: nil}
+	} else if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(formatScore(score))
+}
+
+func handleZRankCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 || len(args) > 3 {
+		return wrongNumberOfArgs("zrank")
+	}
+	withScore := len(args) == 3
+	if withScore && strings.ToUpper(args[2].String()) != "WITHSCORE" {
+		return errorReply(errSyntax)
+	}
+	rank, score, err := ds.ZRank(args[0].String(), args[1].String(), false)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return protocol.BulkString{Data: nil}
+	} else if err != nil {
+		return errorReply(err)
+	}
+	if withScore {
+		return protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: rank}, bulkString(formatScore(score))}}
+	}
+	return protocol.Integer{Value: rank}
+}
+
+func handleZCountCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("zcount")
+	}
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	n, err := ds.ZCount(args[0].String(), r)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleZRangeCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs("zrange")
+	}
+	spec, withScores, err := parseZRangeSpec(args[1:])
+	if err != nil {
+		return errorReply(err)
+	}
+	members, err := ds.ZRange(args[0].String(), spec)
+	if err != nil {
+		return errorReply(err)
+	}
+	return scoredMembersArray(members, withScores)
+}
+
+// parseZRangeSpec parses the arguments of ZRANGE following the key:
+// start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func parseZRangeSpec(args []protocol.Resp) (datastore.ZRangeSpec, bool, error) {
+	spec := datastore.ZRangeSpec{Count: -1}
+	var withScores, limit bool
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i].String()) {
+		case "BYSCORE":
+			spec.By = datastore.ZRangeByScore
+		case "BYLEX":
+			spec.By = datastore.ZRangeByLex
+		case "REV":
+			spec.Rev = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return spec, false, errSyntax
+			}
+			var err error
+			if spec.Offset, err = parseInt(args[i+1]); err != nil {
+				return spec, false, err
+			}
+			if spec.Count, err = parseInt(args[i+2]); err != nil {
+				return spec, false, err
+			}
+			limit = true
+			i += 2
+		default:
+			return spec, false, errSyntax
+		}
+	}
+	if limit && spec.By == datastore.ZRangeByRank {
+		return spec, false, errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && spec.By == datastore.ZRangeByLex {
+		return spec, false, errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	start, stop := args[0], args[1]
+	if spec.Rev && spec.By != datastore.ZRangeByRank {
+		// Score and lex ranges are given as max followed by min when reversed.
+		start, stop = stop, start
+	}
+	var err error
+	switch spec.By {
+	case datastore.ZRangeByRank:
+		if spec.Start, err = parseInt(start); err != nil {
+			return spec, false, err
+		}
+		if spec.Stop, err = parseInt(stop); err != nil {
+			return spec, false, err
+		}
+	case datastore.ZRangeByScore:
+		spec.Score, err = parseScoreRange(start, stop)
+	case datastore.ZRangeByLex:
+		spec.Lex, err = parseLexRange(start, stop)
+	}
+	return spec, withScores, err
+}
+
+func parseScoreRange(min, max protocol.Resp) (datastore.ScoreRange, error) {
+	var r datastore.ScoreRange
+	var err error
+	if r.Min, r.MinEx, err = parseScoreBound(min.String()); err != nil {
+		return r, err
+	}
+	if r.Max, r.MaxEx, err = parseScoreBound(max.String()); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func parseScoreBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, false, errMinMaxNotFloat
+	}
+	return v, exclusive, nil
+}
+
+func parseLexRange(min, max protocol.Resp) (datastore.LexRange, error) {
+	var r datastore.LexRange
+	var err error
+	if r.Min, err = parseLexBound(min.String()); err != nil {
+		return r, err
+	}
+	if r.Max, err = parseLexBound(max.String()); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func parseLexBound(s string) (datastore.LexBound, error) {
+	switch {
+	case s == "-":
+		return datastore.LexBound{Inf: -1}, nil
+	case s == "+":
+		return datastore.LexBound{Inf: 1}, nil
+	case strings.HasPrefix(s, "["):
+		return datastore.LexBound{Value: s[1:]}, nil
+	case strings.HasPrefix(s, "("):
+		return datastore.LexBound{Value: s[1:], Exclusive: true}, nil
+	}
+	return datastore.LexBound{}, errMinMaxNotString
+}
+
+func scoredMembersArray(members []datastore.ScoredMember, withScores bool) protocol.Resp {
+	items := make([]protocol.Resp, 0, len(members))
+	for _, m := range members {
+		items = append(items, bulkString(m.Member))
+		if withScores {
+			items = append(items, bulkString(formatScore(m.Score)))
+		}
+	}
+	return protocol.Array{Items: items}
+}
+
+// formatScore formats a score the way Redis replies with doubles: the shortest
+// representation that round-trips, using an exponent only for very small or large values.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	if a := math.Abs(score); a != 0 && (a < 1e-4 || a >= 1e17) {
+		return strconv.FormatFloat(score, 'e', -1, 64)
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func handleZRemRangeByRankCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("zremrangebyrank")
+	}
+	spec := datastore.ZRangeSpec{By: datastore.ZRangeByRank}
+	var err error
+	if spec.Start, err = parseInt(args[1]); err != nil {
+		return errorReply(err)
+	}
+	if spec.Stop, err = parseInt(args[2]); err != nil {
+		return errorReply(err)
+	}
+	return zRemRange(args[0].String(), spec, ds)
+}
+
+func handleZRemRangeByScoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("zremrangebyscore")
+	}
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return zRemRange(args[0].String(), datastore.ZRangeSpec{By: datastore.ZRangeByScore, Score: r, Count: -1}, ds)
+}
+
+func handleZRemRangeByLexCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) != 3 {
+		return wrongNumberOfArgs("zremrangebylex")
+	}
+	r, err := parseLexRange(args[1], args[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return zRemRange(args[0].String(), datastore.ZRangeSpec{By: datastore.ZRangeByLex, Lex: r, Count: -1}, ds)
+}
+
+func zRemRange(key string, spec datastore.ZRangeSpec, ds *datastore.Datastore) protocol.Resp {
+	n, err := ds.ZRemRange(key, spec)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleZPopMinCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleZPop("zpopmin", args, false, ds)
+}
+
+func handleZPopMaxCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleZPop("zpopmax", args, true, ds)
+}
+
+func handleZPop(cmd string, args []protocol.Resp, max bool, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	count := 1
+	if len(args) == 2 {
+		c, err := parseInt(args[1])
+		if err != nil || c < 0 {
+			return protocol.Error{Data: "ERR value is out of range, must be positive"}
+		}
+		count = int(c)
+	}
+	if count == 0 {
+		return protocol.Array{Items: []protocol.Resp{}}
+	}
+	members, err := ds.ZPop(args[0].String(), count, max)
+	if err != nil {
+		return errorReply(err)
+	}
+	return scoredMembersArray(members, true)
+}
+
+func handleZRandMemberCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 1 || len(args) > 3 {
+		return wrongNumberOfArgs("zrandmember")
+	}
+	var count int64 = 1
+	var withScores bool
+	if len(args) >= 2 {
+		var err error
+		if count, err = parseInt(args[1]); err != nil {
+			return errorReply(err)
+		}
+	}
+	if len(args) == 3 {
+		if strings.ToUpper(args[2].String()) != "WITHSCORES" {
+			return errorReply(errSyntax)
+		}
+		withScores = true
+	}
+	// WITHSCORES doubles the length of the reply.
+	if count == math.MinInt64 || (withScores && (count < -math.MaxInt64/2 || count > math.MaxInt64/2)) {
+		return protocol.Error{Data: "ERR value is out of range"}
+	}
+	members, err := ds.ZRandMember(args[0].String(), count)
+	if err != nil {
+		return errorReply(err)
+	}
+	if len(args) == 1 {
+		if len(members) == 0 {
+			return protocol.BulkString{Data: nil}
+		}
+		return bulkString(members[0].Member)
+	}
+	return scoredMembersArray(members, withScores)
+}
+
+func handleZMScoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 2 {
+		return wrongNumberOfArgs("zmscore")
+	}
+	scores, err := ds.ZMScore(args[0].String(), stringArgs(args[1:])...)
+	if err != nil {
+		return errorReply(err)
+	}
+	items := make([]protocol.Resp, len(scores))
+	for i, s := range scores {
+		if s == nil {
+			items[i] = protocol.BulkString{Data: nil}
+		} else {
+			items[i] = bulkString(formatScore(*s))
+		}
+	}
+	return protocol.Array{Items: items}
+}
+
+func handleZUnionStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleZStore("zunionstore", datastore.ZUnion, args, ds)
+}
+
+func handleZInterStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleZStore("zinterstore", datastore.ZInter, args, ds)
+}
+
+func handleZDiffStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	return handleZStore("zdiffstore", datastore.ZDiff, args, ds)
+}
+
+// handleZStore parses destination numkeys key [key ...] followed, except for ZDIFFSTORE,
+// by [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX].
+func handleZStore(cmd string, op datastore.ZSetOperation, args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 3 {
+		return wrongNumberOfArgs(cmd)
+	}
+	numKeys, err := parseInt(args[1])
+	if err != nil {
+		return errorReply(err)
+	}
+	if numKeys < 1 {
+		return protocol.Error{Data: fmt.Sprintf("ERR at least 1 input key is needed for '%s' command", cmd)}
+	}
+	if numKeys > int64(len(args)-2) {
+		return errorReply(errSyntax)
+	}
+	keys := stringArgs(args[2 : 2+numKeys])
+	var weights []float64
+	aggregate := datastore.ZAggregateSum
+	rest := args[2+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		switch opt := strings.ToUpper(rest[i].String()); {
+		case opt == "WEIGHTS" && op != datastore.ZDiff && len(rest)-i-1 >= len(keys):
+			weights = make([]float64, len(keys))
+			for j := range weights {
+				i++
+				if weights[j], err = strconv.ParseFloat(rest[i].String(), 64); err != nil || math.IsNaN(weights[j]) {
+					return protocol.Error{Data: "ERR weight value is not a float"}
+				}
+			}
+		case opt == "AGGREGATE" && op != datastore.ZDiff && i+1 < len(rest):
+			i++
+			switch strings.ToUpper(rest[i].String()) {
+			case "SUM":
+				aggregate = datastore.ZAggregateSum
+			case "MIN":
+				aggregate = datastore.ZAggregateMin
+			case "MAX":
+				aggregate = datastore.ZAggregateMax
+			default:
+				return errorReply(errSyntax)
+			}
+		default:
+			return errorReply(errSyntax)
+		}
+	}
+	n, err := ds.ZStore(args[0].String(), op, keys, weights, aggregate)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
+
+func handleZRangeStoreCommand(args []protocol.Resp, ds *datastore.Datastore) protocol.Resp {
+	if len(args) < 4 {
+		return wrongNumberOfArgs("zrangestore")
+	}
+	spec, withScores, err := parseZRangeSpec(args[2:])
+	if err != nil {
+		return errorReply(err)
+	}
+	if withScores {
+		return errorReply(errSyntax)
+	}
+	n, err := ds.ZRangeStore(args[0].String(), args[1].String(), spec)
+	if err != nil {
+		return errorReply(err)
+	}
+	return protocol.Integer{Value: n}
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestSortedSetCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("ZADD", "z", "1"), wrongNumberOfArgs("zadd")},
+		{command("ZADD", "z", "1", "a", "2"), protocol.Error{Data: "ERR syntax error"}},
+		{command("ZADD", "z", "x", "a"), protocol.Error{Data: "ERR value is not a valid float"}},
+		{command("ZADD", "z", "NX", "XX", "1", "a"), protocol.Error{Data: "ERR XX and NX options at the same time are not compatible"}},
+		{command("ZADD", "z", "GT", "LT", "1", "a"), protocol.Error{Data: "ERR GT, LT, and/or NX options at the same time are not compatible"}},
+		{command("ZADD", "z", "INCR", "1", "a", "2", "b"), protocol.Error{Data: "ERR INCR option supports a single increment-element pair"}},
+		{command("ZADD", "z", "1", "a", "2", "b", "3", "c"), protocol.Integer{Value: 3}},
+		{command("ZADD", "z", "CH", "1", "a", "5", "b", "4", "d"), protocol.Integer{Value: 2}},
+		{command("ZADD", "z", "XX", "INCR", "1", "nope"), protocol.BulkString{Data: nil}},
+		{command("ZADD", "z", "GT", "INCR", "-1", "a"), protocol.BulkString{Data: nil}},
+		{command("ZADD", "z", "INCR", "0.5", "a"), bulkString("1.5")},
+		{command("ZINCRBY", "z", "-1", "a"), bulkString("0.5")},
+		{command("ZSCORE", "z", "b"), bulkString("5")},
+		{command("ZSCORE", "z", "nope"), protocol.BulkString{Data: nil}},
+		{command("ZCARD", "z"), protocol.Integer{Value: 4}},
+		{command("ZRANK", "z", "c"), protocol.Integer{Value: 1}},
+		{command("ZRANK", "z", "b", "WITHSCORE"), protocol.Array{Items: []protocol.Resp{protocol.Integer{Value: 3}, bulkString("5")}}},
+		{command("ZRANK", "z", "nope"), protocol.BulkString{Data: nil}},
+		{command("ZCOUNT", "z", "(0.5", "+inf"), protocol.Integer{Value: 3}},
+		{command("ZCOUNT", "z", "a", "1"), protocol.Error{Data: "ERR min or max is not a float"}},
+		{command("ZREM", "z", "a", "nope"), protocol.Integer{Value: 1}},
+		{command("ZCARD", "missing"), protocol.Integer{Value: 0}},
+	})
+}
+
+func TestZRangeCommand(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("ZADD", "z", "1", "a", "2", "b", "3", "c", "4", "d"), protocol.Integer{Value: 4}},
+		{command("ZRANGE", "z", "0", "-1"), bulkStringArray([]string{"a", "b", "c", "d"})},
+		{command("ZRANGE", "z", "0", "1", "WITHSCORES"), bulkStringArray([]string{"a", "1", "b", "2"})},
+		{command("ZRANGE", "z", "0", "1", "REV"), bulkStringArray([]string{"d", "c"})},
+		{command("ZRANGE", "z", "(1", "3", "BYSCORE"), bulkStringArray([]string{"b", "c"})},
+		{command("ZRANGE", "z", "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"), bulkStringArray([]string{"c", "b"})},
+		{command("ZRANGE", "z", "[b", "(d", "BYLEX"), bulkStringArray([]string{"b", "c"})},
+		{command("ZRANGE", "z", "+", "-", "BYLEX", "REV", "LIMIT", "0", "1"), bulkStringArray([]string{"d"})},
+		{command("ZRANGE", "z", "0", "-1", "LIMIT", "0", "1"), protocol.Error{Data: "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"}},
+		{command("ZRANGE", "z", "-", "+", "BYLEX", "WITHSCORES"), protocol.Error{Data: "ERR syntax error, WITHSCORES not supported in combination with BYLEX"}},
+		{command("ZRANGE", "z", "a", "+", "BYLEX"), protocol.Error{Data: "ERR min or max not valid string range item"}},
+		{command("ZRANGE", "z", "0", "1", "FOO"), protocol.Error{Data: "ERR syntax error"}},
+		{command("ZRANGE", "missing", "0", "-1"), bulkStringArray([]string{})},
+		{command("LPUSH", "list", "a"), protocol.Integer{Value: 1}},
+		{command("ZRANGE", "list", "0", "-1"), wrongType},
+	})
+}
+
+func TestFormatScore(t *testing.T) {
+	tests := map[float64]string{1: "1", 1.5: "1.5", -0.25: "-0.25", 1e8: "100000000", 1e21: "1e+21", 0.00001: "1e-05"}
+	for in, expected := range tests {
+		if got := formatScore(in); got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestSortedSetRemoveAndPopCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	runSequence(t, ds, []step{
+		{command("ZADD", "z", "1", "a", "2", "b", "3", "c", "4", "d", "5", "e", "6", "f"), protocol.Integer{Value: 6}},
+		{command("ZREMRANGEBYRANK", "z", "0", "0"), protocol.Integer{Value: 1}},
+		{command("ZREMRANGEBYSCORE", "z", "(2", "3"), protocol.Integer{Value: 1}},
+		{command("ZREMRANGEBYLEX", "z", "[f", "+"), protocol.Integer{Value: 1}},
+		{command("ZRANGE", "z", "0", "-1"), bulkStringArray([]string{"b", "d", "e"})},
+		{command("ZPOPMIN", "z", "0"), bulkStringArray([]string{})},
+		{command("ZCARD", "z"), protocol.Integer{Value: 3}},
+		{command("ZPOPMIN", "z"), bulkStringArray([]string{"b", "2"})},
+		{command("ZPOPMAX", "z", "5"), bulkStringArray([]string{"e", "5", "d", "4"})},
+		{command("ZPOPMAX", "z", "-1"), protocol.Error{Data: "ERR value is out of range, must be positive"}},
+		{command("ZPOPMIN", "z"), bulkStringArray([]string{})},
+		{command("EXISTS", "z"), protocol.Integer{Value: 0}},
+		{command("ZADD", "z", "1", "a"), protocol.Integer{Value: 1}},
+		{command("ZRANDMEMBER", "z"), bulkString("a")},
+		{command("ZRANDMEMBER", "z", "-2", "WITHSCORES"), bulkStringArray([]string{"a", "1", "a", "1"})},
+		{command("ZRANDMEMBER", "missing"), protocol.BulkString{Data: nil}},
+		{command("ZRANDMEMBER", "z", "-9223372036854775808"), protocol.Error{Data: "ERR value is out of range"}},
+		{command("ZRANDMEMBER", "z", "-4611686018427387904", "WITHSCORES"), protocol.Error{Data: "ERR value is out of range"}},
+		{command("ZMSCORE", "z", "a", "nope"), protocol.Array{Items: []protocol.Resp{bulkString("1"), protocol.BulkString{Data: nil}}}},
+	})
+}
+
+func TestSortedSetStoreCommands(t *testing.T) {
+	ds := datastore.NewDatastore()
+	ds.ZAdd("z1", datastore.ZAddOptions{}, []datastore.ScoredMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}})
+	ds.ZAdd("z2", datastore.ZAddOptions{}, []datastore.ScoredMember{{Member: "b", Score: 3}, {Member: "c", Score: 4}})
+	ds.SAdd("s", "a", "c")
+	runSequence(t, ds, []step{
+		{command("ZUNIONSTORE", "out", "2", "z1", "z2"), protocol.Integer{Value: 3}},
+		{command("ZRANGE", "out", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"a", "1", "c", "4", "b", "5"})},
+		{command("ZUNIONSTORE", "out", "2", "z1", "z2", "WEIGHTS", "2", "1", "AGGREGATE", "MIN"), protocol.Integer{Value: 3}},
+		{command("ZRANGE", "out", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"a", "2", "b", "3", "c", "4"})},
+		{command("ZINTERSTORE", "out", "2", "z1", "z2", "AGGREGATE", "MAX"), protocol.Integer{Value: 1}},
+		{command("ZRANGE", "out", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"b", "3"})},
+		{command("ZINTERSTORE", "out", "2", "z1", "s"), protocol.Integer{Value: 1}},
+		{command("ZRANGE", "out", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"a", "2"})},
+		{command("ZDIFFSTORE", "out", "2", "z1", "z2"), protocol.Integer{Value: 1}},
+		{command("ZRANGE", "out", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"a", "1"})},
+		{command("ZDIFFSTORE", "out", "2", "z1", "z2", "WEIGHTS", "1", "1"), protocol.Error{Data: "ERR syntax error"}},
+		{command("ZUNIONSTORE", "out", "0", "z1"), protocol.Error{Data: "ERR at least 1 input key is needed for 'zunionstore' command"}},
+		{command("ZUNIONSTORE", "out", "3", "z1", "z2"), protocol.Error{Data: "ERR syntax error"}},
+		{command("ZUNIONSTORE", "out", "2", "z1", "z2", "WEIGHTS", "1", "x"), protocol.Error{Data: "ERR weight value is not a float"}},
+		{command("ZRANGESTORE", "out", "z2", "0", "0", "REV"), protocol.Integer{Value: 1}},
+		{command("ZRANGE", "out", "0", "-1", "WITHSCORES"), bulkStringArray([]string{"c", "4"})},
+		{command("ZRANGESTORE", "out", "z2", "10", "20"), protocol.Integer{Value: 0}},
+		{command("EXISTS", "out"), protocol.Integer{Value: 0}},
+		{command("ZRANGESTORE", "out", "z2", "0", "1", "WITHSCORES"), protocol.Error{Data: "ERR syntax error"}},
+		{command("SET", "str", "v"), protocol.SimpleString{Data: "OK"}},
+		{command("ZUNIONSTORE", "out", "2", "z1", "str"), wrongType},
+	})
+}
@@ -0,0 +1,409 @@
+package datastore
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// maxBitOffset mirrors the 512MB proto-max-bulk-len limit of Redis strings.
+const maxBitOffset = 512*1024*1024*8 - 1
+
+// ErrBitOffset is returned when a bit offset is negative or past the maximum string size.
+var ErrBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// BitRange is an interval of a string, with negative values counting from its end.
+// Start and End index bytes, or bits when Bit is set.
+type BitRange struct {
+	Start, End int64
+	Bit        bool
+}
+
+// BitOperation is the operation applied by BITOP.
+type BitOperation int
+
+const (
+	BitAnd BitOperation = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitFieldOpcode selects what a BITFIELD operation does.
+type BitFieldOpcode int
+
+const (
+	BitFieldGet BitFieldOpcode = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOverflow selects how BITFIELD handles overflows of SET and INCRBY.
+type BitFieldOverflow int
+
+const (
+	BitFieldWrap BitFieldOverflow = iota
+	BitFieldSat
+	BitFieldFail
+)
+
+// BitFieldOp is a single operation of BITFIELD on the integer of Bits bits at Offset.
+type BitFieldOp struct {
+	Opcode   BitFieldOpcode
+	Signed   bool
+	Bits     uint
+	Offset   int64
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+// SetBit sets or clears the bit at offset in the string stored at key, growing it as needed.
+// Returns the previous value of the bit.
+func (d *Datastore) SetBit(key string, offset int64, value byte) (byte, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+	d.mu.Lock()
+	defer d.unlock()
+	e, b, err := d.getBytesForBits(key, offset)
+	if err != nil {
+		return 0, err
+	}
+	old := bitAt(b, offset)
+	mask := byte(1) << (7 - offset&7)
+	if value == 1 {
+		b[offset>>3] |= mask
+	} else {
+		b[offset>>3] &^= mask
+	}
+	e.Value = b
+	d.notify(EventString, "setbit", key)
+	return old, nil
+}
+
+// GetBit returns the bit at offset in the string stored at key. Bits past the end are zero.
+func (d *Datastore) GetBit(key string, offset int64) (byte, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+	d.mu.RLock()
+	defer d.runlock()
+	b, _, err := d.readBytes(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitAt(b, offset), nil
+}
+
+// BitCount returns the number of set bits in the string stored at key,
+// limited to the range when it is not nil.
+func (d *Datastore) BitCount(key string, r *BitRange) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	b, _, err := d.readBytes(key)
+	if err != nil {
+		return 0, err
+	}
+	from, to := int64(0), int64(len(b))*8-1
+	if r != nil {
+		from, to = r.bits(len(b))
+	}
+	var count int64
+	for i := from; i <= to; {
+		if i&7 == 0 && i+7 <= to {
+			count += int64(bits.OnesCount8(b[i>>3]))
+			i += 8
+			continue
+		}
+		count += int64(bitAt(b, i))
+		i++
+	}
+	return count, nil
+}
+
+// BitPos returns the position of the first bit set to bit in the string stored at key,
+// limited to the range when it is not nil, or -1 if there is none. When looking for a clear
+// bit without an explicit end, the string is considered to be padded with zeroes on the right.
+func (d *Datastore) BitPos(key string, bit byte, r *BitRange, endGiven bool) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	b, ok, err := d.readBytes(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	from, to := int64(0), int64(len(b))*8-1
+	if r != nil {
+		from, to = r.bits(len(b))
+	}
+	if from > to {
+		return -1, nil
+	}
+	for i := from; i <= to; {
+		// Skip whole bytes that cannot contain the bit.
+		if i&7 == 0 && i+7 <= to && (bit == 1 && b[i>>3] == 0 || bit == 0 && b[i>>3] == 0xff) {
+			i += 8
+			continue
+		}
+		if bitAt(b, i) == bit {
+			return i, nil
+		}
+		i++
+	}
+	if bit == 0 && !endGiven {
+		return to + 1, nil
+	}
+	return -1, nil
+}
+
+// BitOp stores at dest the result of the operation between the strings stored at keys,
+// missing keys and the end of shorter strings counting as zeroes. dest is deleted when
+// the result is empty. Returns the length of the result.
+func (d *Datastore) BitOp(op BitOperation, dest string, keys ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	values := make([][]byte, len(keys))
+	length := 0
+	for i, key := range keys {
+		b, _, err := d.readBytes(key)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = b
+		length = max(length, len(b))
+	}
+	if length == 0 {
+		if d.lookup(dest) != nil {
+			d.remove(dest)
+			d.notify(EventGeneric, "del", dest)
+		}
+		return 0, nil
+	}
+	res := make([]byte, length)
+	for i := range res {
+		var acc byte
+		for j, v := range values {
+			var c byte
+			if i < len(v) {
+				c = v[i]
+			}
+			switch {
+			case j == 0:
+				acc = c
+			case op == BitAnd:
+				acc &= c
+			case op == BitOr:
+				acc |= c
+			case op == BitXor:
+				acc ^= c
+			}
+		}
+		if op == BitNot {
+			acc = ^acc
+		}
+		res[i] = acc
+	}
+	d.store(dest, newEntry(res, -1))
+	d.notify(EventString, "set", dest)
+	return int64(length), nil
+}
+
+// BitField runs the operations on the string stored at key, growing it as needed when there are
+// SET or INCRBY operations. Returns the result of each operation: the value for GET, the previous
+// value for SET and the new one for INCRBY, or nil when an operation fails because of an overflow.
+func (d *Datastore) BitField(key string, ops []BitFieldOp) ([]*int64, error) {
+	for _, op := range ops {
+		if op.Offset < 0 || op.Offset+int64(op.Bits)-1 > maxBitOffset {
+			return nil, ErrBitOffset
+		}
+	}
+	d.mu.Lock()
+	defer d.unlock()
+	var e *Entry
+	var b []byte
+	var err error
+	highest := int64(-1)
+	for _, op := range ops {
+		if op.Opcode != BitFieldGet {
+			highest = max(highest, op.Offset+int64(op.Bits)-1)
+		}
+	}
+	if highest >= 0 {
+		if e, b, err = d.getBytesForBits(key, highest); err != nil {
+			return nil, err
+		}
+	} else if b, _, err = d.readBytes(key); err != nil {
+		return nil, err
+	}
+	ret := make([]*int64, len(ops))
+	changed := false
+	for i, op := range ops {
+		old := getBitField(b, op.Offset, op.Bits)
+		if op.Signed && op.Bits < 64 && old&(1<<(op.Bits-1)) != 0 {
+			old |= math.MaxUint64 << op.Bits
+		}
+		if op.Opcode == BitFieldGet {
+			v := int64(old)
+			ret[i] = &v
+			continue
+		}
+		value, incr := old, op.Value
+		if op.Opcode == BitFieldSet {
+			value, incr = uint64(op.Value), 0
+		}
+		var res uint64
+		var overflow bool
+		if op.Signed {
+			res, overflow = signedBitFieldIncr(int64(value), incr, op.Bits, op.Overflow)
+		} else {
+			res, overflow = unsignedBitFieldIncr(value, incr, op.Bits, op.Overflow)
+		}
+		if overflow && op.Overflow == BitFieldFail {
+			continue
+		}
+		setBitField(b, op.Offset, op.Bits, res)
+		changed = true
+		v := int64(res)
+		if op.Opcode == BitFieldSet {
+			v = int64(old)
+		}
+		ret[i] = &v
+	}
+	if e != nil {
+		e.Value = b
+	}
+	if changed {
+		d.notify(EventString, "setbit", key)
+	}
+	return ret, nil
+
+}
+
+// getBytesForBits returns the string stored at key grown to hold the bit at offset,
+// creating it if needed. The caller must hold d.mu for writing.
+func (d *Datastore) getBytesForBits(key string, offset int64) (*Entry, []byte, error) {
+	e, b, err := d.getBytes(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e == nil {
+		e = newEntry([]byte{}, -1)
+		d.store(key, e)
+	}
+	if need := int(offset>>3) + 1; len(b) < need {
+		b = append(b, make([]byte, need-len(b))...)
+	}
+	return e, b, nil
+}
+
+// bits converts the range to an inclusive interval of bits within a string of length bytes.
+// The interval is empty when from is greater than to.
+func (r BitRange) bits(length int) (from, to int64) {
+	total := int64(length)
+	if r.Bit {
+		total *= 8
+	}
+	start, end := r.Start, r.End
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, total-1)
+	if start > end {
+		return 0, -1
+	}
+	if r.Bit {
+		return start, end
+	}
+	return start * 8, end*8 + 7
+}
+
+func bitAt(b []byte, offset int64) byte {
+	if offset>>3 >= int64(len(b)) {
+		return 0
+	}
+	return b[offset>>3] >> (7 - offset&7) & 1
+}
+
+// getBitField reads the unsigned integer of n bits at offset, most significant bit first.
+func getBitField(b []byte, offset int64, n uint) uint64 {
+	var v uint64
+	for i := int64(0); i < int64(n); i++ {
+		v = v<<1 | uint64(bitAt(b, offset+i))
+	}
+	return v
+}
+
+// setBitField writes the n least significant bits of v at offset, most significant bit first.
+func setBitField(b []byte, offset int64, n uint, v uint64) {
+	for i := int64(0); i < int64(n); i++ {
+		mask := byte(1) << (7 - (offset+i)&7)
+		if v>>(int64(n)-1-i)&1 == 1 {
+			b[(offset+i)>>3] |= mask
+		} else {
+			b[(offset+i)>>3] &^= mask
+		}
+	}
+}
+
+// unsignedBitFieldIncr adds incr to the unsigned integer of n bits value. Returns the result,
+// wrapped or saturated according to the overflow mode, and whether it overflowed.
+func unsignedBitFieldIncr(value uint64, incr int64, n uint, overflow BitFieldOverflow) (uint64, bool) {
+	maxValue := uint64(1)<<n - 1
+	maxIncr := int64(maxValue - value)
+	minIncr := -int64(value)
+	switch {
+	case value > maxValue || (incr > 0 && incr > maxIncr):
+		if overflow == BitFieldSat {
+			return maxValue, true
+		}
+	case incr < 0 && incr < minIncr:
+		if overflow == BitFieldSat {
+			return 0, true
+		}
+	default:
+		return value + uint64(incr), false
+	}
+	return (value + uint64(incr)) &^ (math.MaxUint64 << n), true
+}
+
+// signedBitFieldIncr adds incr to the signed integer of n bits value. Returns the result,
+// wrapped or saturated according to the overflow mode, and whether it overflowed.
+func signedBitFieldIncr(value, incr int64, n uint, overflow BitFieldOverflow) (uint64, bool) {
+	maxValue := int64(math.MaxInt64)
+	if n < 64 {
+		maxValue = int64(1)<<(n-1) - 1
+	}
+	minValue := -maxValue - 1
+	maxIncr := maxValue - value
+	minIncr := minValue - value
+	switch {
+	case value > maxValue || (n != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		if overflow == BitFieldSat {
+			return uint64(maxValue), true
+		}
+	case value < minValue || (n != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		if overflow == BitFieldSat {
+			return uint64(minValue), true
+		}
+	default:
+		return uint64(value + incr), false
+	}
+	res := uint64(value) + uint64(incr)
+	if n < 64 {
+		if res&(1<<(n-1)) != 0 {
+			res |= math.MaxUint64 << n
+		} else {
+			res &^= math.MaxUint64 << n
+		}
+	}
+	return res, true
+}
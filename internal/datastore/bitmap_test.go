@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"testing"
+)
+
+func TestBitFieldOverflow(t *testing.T) {
+	tests := map[string]struct {
+		op       BitFieldOp
+		initial  int64
+		expected *int64
+		stored   int64
+	}{
+		"Unsigned wrap":      {op: BitFieldOp{Opcode: BitFieldIncrBy, Bits: 4, Value: 3, Overflow: BitFieldWrap}, initial: 14, expected: ptrTo(1), stored: 1},
+		"Unsigned sat":       {op: BitFieldOp{Opcode: BitFieldIncrBy, Bits: 4, Value: 3, Overflow: BitFieldSat}, initial: 14, expected: ptrTo(15), stored: 15},
+		"Unsigned sat below": {op: BitFieldOp{Opcode: BitFieldIncrBy, Bits: 4, Value: -20, Overflow: BitFieldSat}, initial: 14, expected: ptrTo(0), stored: 0},
+		"Unsigned fail":      {op: BitFieldOp{Opcode: BitFieldIncrBy, Bits: 4, Value: 3, Overflow: BitFieldFail}, initial: 14, expected: nil, stored: 14},
+		"Signed wrap":        {op: BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 8, Value: 1, Overflow: BitFieldWrap}, initial: 127, expected: ptrTo(-128), stored: -128},
+		"Signed sat":         {op: BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 8, Value: -300, Overflow: BitFieldSat}, initial: 0, expected: ptrTo(-128), stored: -128},
+		"Signed set wrap":    {op: BitFieldOp{Opcode: BitFieldSet, Signed: true, Bits: 8, Value: 255, Overflow: BitFieldWrap}, initial: 5, expected: ptrTo(5), stored: -1},
+		"Signed 64 bits":     {op: BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 64, Value: 1, Overflow: BitFieldSat}, initial: 1<<63 - 1, expected: ptrTo(1<<63 - 1), stored: 1<<63 - 1},
+		"Unsigned set sat":   {op: BitFieldOp{Opcode: BitFieldSet, Bits: 3, Value: -1, Overflow: BitFieldSat}, initial: 2, expected: ptrTo(2), stored: 7},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ds := NewDatastore()
+			set := BitFieldOp{Opcode: BitFieldSet, Signed: test.op.Signed, Bits: test.op.Bits, Value: test.initial}
+			get := BitFieldOp{Opcode: BitFieldGet, Signed: test.op.Signed, Bits: test.op.Bits}
+			res, err := ds.BitField("key", []BitFieldOp{set, test.op, get})
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if (res[1] == nil) != (test.expected == nil) || (res[1] != nil && *res[1] != *test.expected) {
+				t.Errorf("Expected %v, got %v", deref(test.expected), deref(res[1]))
+			}
+			if *res[2] != test.stored {
+				t.Errorf("Expected %d to be stored, got %d", test.stored, *res[2])
+			}
+		})
+	}
+}
+
+func TestBitRange(t *testing.T) {
+	tests := map[string]struct {
+		r        BitRange
+		from, to int64
+	}{
+		"Bytes":          {r: BitRange{Start: 1, End: 2}, from: 8, to: 23},
+		"Negative bytes": {r: BitRange{Start: -2, End: -1}, from: 24, to: 39},
+		"Past the end":   {r: BitRange{Start: 0, End: 100}, from: 0, to: 39},
+		"Bits":           {r: BitRange{Start: 3, End: -3, Bit: true}, from: 3, to: 37},
+		"Empty":          {r: BitRange{Start: 3, End: 2}, from: 0, to: -1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			from, to := test.r.bits(5)
+			if from != test.from || to != test.to {
+				t.Errorf("Expected [%d, %d], got [%d, %d]", test.from, test.to, from, to)
+			}
+		})
+	}
+}
+
+func TestSetBitGrowsValue(t *testing.T) {
+	ds := NewDatastore()
+	ds.Set("key", "1")
+	if old, _ := ds.SetBit("key", 23, 1); old != 0 {
+		t.Errorf("Expected the previous bit to be 0, got %d", old)
+	}
+	if got, _ := ds.Get("key"); got != "1\x00\x01" {
+		t.Errorf("Expected %q, got %q", "1\x00\x01", got)
+	}
+	if n, _ := ds.BitCount("key", nil); n != 4 {
+		t.Errorf("Expected 4 set bits, got %d", n)
+	}
+}
+
+func ptrTo(v int64) *int64 {
+	return &v
+}
+
+func deref(v *int64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -0,0 +1,203 @@
+package datastore
+
+import (
+	"maps"
+	"slices"
+)
+
+// ListPop describes a pop from the first non-empty of a number of lists, like BLMPOP, or the
+// move of an element to a destination list, like BLMOVE.
+type ListPop struct {
+	Keys []string
+	// Left pops from the head of the lists, otherwise from their tail.
+	Left  bool
+	Count int
+	// Move pushes the popped element to Destination, at its head if ToLeft.
+	Move        bool
+	Destination string
+	ToLeft      bool
+}
+
+// Popped is the outcome of a ListPop: the key of the list popped from and the popped elements.
+// Key is empty when all the lists were empty.
+type Popped struct {
+	Key    string
+	Values []string
+}
+
+// Blocked is a client blocked by BLPOP, BRPOP, BLMOVE, BLMPOP or BRPOPLPUSH until one of the
+// lists it pops from gets elements.
+type Blocked struct {
+	pop    ListPop
+	popped Popped
+	err    error
+	// served is closed once the client has been served.
+	served chan struct{}
+}
+
+// NewBlocked returns a client blocked on the lists of the pop.
+func NewBlocked(pop ListPop) *Blocked {
+	return &Blocked{pop: pop, served: make(chan struct{})}
+}
+
+// Served returns a channel closed once the client has been served.
+func (b *Blocked) Served() <-chan struct{} {
+	return b.served
+}
+
+// Result returns what the client has been served, once Served is closed.
+func (b *Blocked) Result() (Popped, error) {
+	return b.popped, b.err
+}
+
+// PopFirst executes the pop on the first of its lists that is not empty.
+func (d *Datastore) PopFirst(pop ListPop) (Popped, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	return d.popFirst(pop)
+}
+
+// PopOrBlock executes the pop of b on the first of its lists that is not empty and reports true,
+// or queues b behind the clients already blocked on its keys until ServeBlocked gives one of
+// them elements.
+func (d *Datastore) PopOrBlock(b *Blocked) (Popped, bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	popped, err := d.popFirst(b.pop)
+	if err != nil || popped.Key != "" {
+		return popped, true, err
+	}
+	if d.blocked == nil {
+		d.blocked = make(map[string][]*Blocked)
+	}
+	for _, key := range b.pop.Keys {
+		if !slices.Contains(d.blocked[key], b) {
+			d.blocked[key] = append(d.blocked[key], b)
+		}
+	}
+	return Popped{}, false, nil
+}
+
+// Unblock removes b from the blocked clients once it timed out. Returns false if b has been
+// served meanwhile.
+func (d *Datastore) Unblock(b *Blocked) bool {
+	d.mu.Lock()
+	defer d.unlock()
+	select {
+	case <-b.served:
+		return false
+	default:
+	}
+	d.removeBlocked(b)
+	return true
+}
+
+// NumBlocked returns the number of clients blocked on the lists of the datastore.
+func (d *Datastore) NumBlocked() int {
+	d.mu.RLock()
+	defer d.runlock()
+	clients := make(map[*Blocked]struct{})
+	for _, blocked := range d.blocked {
+		for _, b := range blocked {
+			clients[b] = struct{}{}
+		}
+	}
+	return len(clients)
+}
+
+// ServeBlocked serves the clients blocked on the keys written since the last call, in the order
+// they blocked, for as long as the lists stored at the keys have elements. Writes only mark the
+// keys as ready, so that the clients are served once the command or the transaction writing
+// them has completed, and never see its intermediate states.
+func (d *Datastore) ServeBlocked() {
+	if !d.hasReady.Load() {
+		return
+	}
+	d.mu.Lock()
+	defer d.unlock()
+	d.serveBlocked()
+}
+
+// signalReady marks the key as ready if clients are blocked on it. The caller must hold d.mu
+// for writing.
+func (d *Datastore) signalReady(key string) {
+	if len(d.blocked[key]) == 0 || slices.Contains(d.ready, key) {
+		return
+	}
+	d.ready = append(d.ready, key)
+	d.hasReady.Store(true)
+}
+
+// signalAllReady marks all the keys clients are blocked on as ready, like after the keys were
+// swapped with the ones of another datastore. The caller must hold d.mu for writing.
+func (d *Datastore) signalAllReady() {
+	for _, key := range slices.Sorted(maps.Keys(d.blocked)) {
+		d.signalReady(key)
+	}
+}
+
+// serveBlocked serves the clients blocked on the ready keys. Moving elements to a list marks it
+// as ready in turn. The caller must hold d.mu.
+func (d *Datastore) serveBlocked() {
+	for len(d.ready) > 0 {
+		key := d.ready[0]
+		d.ready = d.ready[1:]
+		for len(d.blocked[key]) > 0 {
+			l, err := d.getList(key)
+			if err != nil || l == nil {
+				break
+			}
+			b := d.blocked[key][0]
+			d.removeBlocked(b)
+			b.popped, b.err = d.popList(key, l, b.pop)
+			close(b.served)
+		}
+	}
+	d.ready = nil
+	d.hasReady.Store(false)
+}
+
+// removeBlocked removes b from the clients blocked on its keys. The caller must hold d.mu.
+func (d *Datastore) removeBlocked(b *Blocked) {
+	for _, key := range b.pop.Keys {
+		d.blocked[key] = slices.DeleteFunc(d.blocked[key], func(other *Blocked) bool {
+			return other == b
+		})
+		if len(d.blocked[key]) == 0 {
+			delete(d.blocked, key)
+		}
+	}
+}
+
+// popFirst executes the pop on the first of its lists that is not empty, failing if one of
+// the keys before it holds another kind of value. The caller must hold d.mu.
+func (d *Datastore) popFirst(pop ListPop) (Popped, error) {
+	for _, key := range pop.Keys {
+		l, err := d.getList(key)
+		if err != nil {
+			return Popped{}, err
+		}
+		if l != nil {
+			return d.popList(key, l, pop)
+		}
+	}
+	return Popped{}, nil
+}
+
+// popList executes the pop on the non-empty list stored at key. The caller must hold d.mu.
+func (d *Datastore) popList(key string, l *List, pop ListPop) (Popped, error) {
+	if !pop.Move {
+		values := d.popElements(key, l, pop.Left, pop.Count)
+		d.deleteIfEmpty(key, l)
+		return Popped{Key: key, Values: values}, nil
+	}
+	dst, err := d.getList(pop.Destination)
+	if err != nil {
+		return Popped{}, err
+	}
+	values := d.popElements(key, l, pop.Left, 1)
+	d.pushElements(pop.Destination, dst, pop.ToLeft, values)
+	// When the source is the destination, the element has been pushed back into the list.
+	d.deleteIfEmpty(key, l)
+	return Popped{Key: key, Values: values}, nil
+}
@@ -0,0 +1,120 @@
+package datastore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPopFirst(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("b", "1", "2", "3")
+	ds.Set("s", "value")
+	popped, err := ds.PopFirst(ListPop{Keys: []string{"a", "b"}, Count: 5})
+	if err != nil || !reflect.DeepEqual(popped, Popped{Key: "b", Values: []string{"3", "2", "1"}}) {
+		t.Errorf("Expected the elements of b, got %v, %v", popped, err)
+	}
+	if ds.Exists("b") {
+		t.Errorf("Expected the emptied list to be deleted")
+	}
+	if popped, err := ds.PopFirst(ListPop{Keys: []string{"a", "b"}, Count: 1}); err != nil || popped.Key != "" {
+		t.Errorf("Expected nothing to pop, got %v, %v", popped, err)
+	}
+	if _, err := ds.PopFirst(ListPop{Keys: []string{"s", "b"}, Count: 1}); err != ErrWrongType {
+		t.Errorf("Expected %v, got %v", ErrWrongType, err)
+	}
+}
+
+func TestPopFirstMove(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("list", "a", "b", "c")
+	rotate := ListPop{Keys: []string{"list"}, Left: true, Count: 1, Move: true, Destination: "list"}
+	if popped, err := ds.PopFirst(rotate); err != nil || popped.Values[0] != "a" {
+		t.Errorf("Expected a to be moved, got %v, %v", popped, err)
+	}
+	if values, _ := ds.LRange("list", 0, -1); !reflect.DeepEqual(values, []string{"b", "c", "a"}) {
+		t.Errorf("Expected the list to be rotated, got %v", values)
+	}
+	ds.Set("s", "value")
+	if _, err := ds.PopFirst(ListPop{Keys: []string{"list"}, Count: 1, Move: true, Destination: "s"}); err != ErrWrongType {
+		t.Errorf("Expected %v, got %v", ErrWrongType, err)
+	}
+	move := ListPop{Keys: []string{"list"}, Count: 1, Move: true, Destination: "other", ToLeft: true}
+	for range 3 {
+		ds.PopFirst(move)
+	}
+	if values, _ := ds.LRange("other", 0, -1); !reflect.DeepEqual(values, []string{"b", "c", "a"}) || ds.Exists("list") {
+		t.Errorf("Expected the list to be moved, got %v", values)
+	}
+}
+
+func TestPopOrBlock(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("b", "x")
+	pop := ListPop{Keys: []string{"a", "b"}, Left: true, Count: 1}
+	if popped, served, err := ds.PopOrBlock(NewBlocked(pop)); err != nil || !served || popped.Key != "b" {
+		t.Errorf("Expected to be served right away, got %v, %v, %v", popped, served, err)
+	}
+	first, second, third := NewBlocked(pop), NewBlocked(pop), NewBlocked(pop)
+	for _, b := range []*Blocked{first, second, third} {
+		if _, served, _ := ds.PopOrBlock(b); served {
+			t.Fatalf("Expected to block on empty lists")
+		}
+	}
+	if n := ds.NumBlocked(); n != 3 {
+		t.Errorf("Expected 3 blocked clients, got %d", n)
+	}
+	ds.RPush("b", "1", "2")
+	select {
+	case <-first.Served():
+		t.Fatalf("Expected the clients to be served only by ServeBlocked")
+	default:
+	}
+	ds.ServeBlocked()
+	for i, b := range []*Blocked{first, second} {
+		select {
+		case <-b.Served():
+		default:
+			t.Fatalf("Expected the client %d to be served", i)
+		}
+		popped, _ := b.Result()
+		if expected := (Popped{Key: "b", Values: []string{[]string{"1", "2"}[i]}}); !reflect.DeepEqual(popped, expected) {
+			t.Errorf("Expected %v, got %v", expected, popped)
+		}
+	}
+	if !ds.Unblock(third) || ds.NumBlocked() != 0 {
+		t.Errorf("Expected the last client to time out")
+	}
+	if ds.Unblock(first) {
+		t.Errorf("Expected a served client not to time out")
+	}
+}
+
+func TestServeBlockedMove(t *testing.T) {
+	ds := NewDatastore()
+	watch := NewWatch()
+	ds.Watch(watch, "dst")
+	move := NewBlocked(ListPop{Keys: []string{"src"}, Count: 1, Move: true, Destination: "dst", ToLeft: true})
+	pop := NewBlocked(ListPop{Keys: []string{"dst"}, Left: true, Count: 1})
+	ds.PopOrBlock(move)
+	ds.PopOrBlock(pop)
+	ds.RPush("src", "x")
+	ds.ServeBlocked()
+	if popped, err := pop.Result(); err != nil || !reflect.DeepEqual(popped, Popped{Key: "dst", Values: []string{"x"}}) {
+		t.Errorf("Expected the moved element to be served, got %v, %v", popped, err)
+	}
+	if !watch.Dirty() {
+		t.Errorf("Expected serving a blocked client to flag the watches")
+	}
+}
+
+func TestSwapServesBlocked(t *testing.T) {
+	ds, other := NewDatastore(), NewDatastore()
+	b := NewBlocked(ListPop{Keys: []string{"list"}, Left: true, Count: 1})
+	ds.PopOrBlock(b)
+	other.RPush("list", "x")
+	ds.SwapWith(other)
+	ds.ServeBlocked()
+	if popped, _ := b.Result(); popped.Key != "list" {
+		t.Errorf("Expected the blocked client to be served the swapped list, got %v", popped)
+	}
+}
@@ -0,0 +1,55 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.SetWithExpiry("key", "value", 1000)
+	clock.Advance(250 * time.Millisecond)
+	if ttl := ds.TTL("key"); ttl != 750 {
+		t.Errorf("Expected a TTL of 750, got %d", ttl)
+	}
+	clock.Set(time.UnixMilli(1001000))
+	if ds.Exists("key") {
+		t.Errorf("Expected the key to expire once the clock reaches its expiration")
+	}
+}
+
+func TestManualClockDrivesStreams(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	id, _, _ := ds.XAdd("s", XAddOptions{AutoID: true}, []string{"f", "v"})
+	if id.Ms != 1000000 {
+		t.Errorf("Expected an ID generated from the clock, got %s", id)
+	}
+	ds.XGroupCreate("s", "g", StreamID{}, false, false, -1)
+	ds.XReadGroup("g", "c", []string{"s"}, []*StreamID{nil}, 0, false)
+	clock.Advance(time.Second)
+	pending, _ := ds.XPending("s", "g", 500, StreamID{}, StreamID{Ms: ^uint64(0), Seq: ^uint64(0)}, 10, "")
+	if len(pending) != 1 || ds.Now().UnixMilli()-pending[0].DeliveryTime != 1000 {
+		t.Errorf("Expected the entry to be idle for 1000ms, got %v", pending)
+	}
+}
+
+func TestManualClockDrivesIdleTime(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.Set("a", "1")
+	ds.Set("b", "2")
+	clock.Advance(90 * time.Second)
+	ds.Get("a")
+	ds.Type("b")
+	if idle, _ := ds.IdleTime("a"); idle != 0 {
+		t.Errorf("Expected a read to reset the idle time, got %v", idle)
+	}
+	if idle, _ := ds.IdleTime("b"); idle != 90*time.Second {
+		t.Errorf("Expected an idle time of 90s, got %v", idle)
+	}
+	if _, ok := ds.IdleTime("missing"); ok {
+		t.Errorf("Expected no idle time for a missing key")
+	}
+}
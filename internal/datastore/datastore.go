@@ -3,118 +3,319 @@ package datastore
 import (
 	"errors"
 	"fmt"
-	"maps"
+	"hash/maphash"
+	"math/big"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// datastoreIDs numbers the datastores, giving an order to lock several of them in.
+var datastoreIDs atomic.Uint64
+
 type Datastore struct {
+	id   uint64
 	mu   sync.RWMutex
 	data map[string]*Entry
 
-	expChunkSize int
+	// lazyExpired holds the keys found expired on access, deleted as soon as d.mu is
+	// held for writing.
+	lazyMu      sync.Mutex
+	lazyExpired []string
+
+	// watches maps the watched keys to the watches they are part of.
+	watchMu sync.Mutex
+	watches map[string]map[*Watch]struct{}
+	// blocked maps the keys to the clients blocked on them, in the order they blocked.
+	blocked map[string][]*Blocked
+	// ready holds the keys written while clients are blocked on them, until ServeBlocked.
+	ready    []string
+	hasReady atomic.Bool
+
+	clock    Clock
+	hz       int
+	notifier Notifier
+	// index orders the keys of data by hash for SCAN, which visits them from a cursor.
+	index *skiplist
+}
+
+// scanSeed hashes the keys to SCAN cursors. It is shared by the datastores, so that their keys
+// can be swapped along with their index.
+var scanSeed = maphash.MakeSeed()
+
+// Option configures a Datastore created by NewDatastore.
+type Option func(*Datastore)
+
+// WithHz sets how many times per second the active expire cycle runs, between 1 and 500
+// like the hz setting of Redis. It defaults to 10.
+func WithHz(hz int) Option {
+	return func(d *Datastore) {
+		d.hz = min(max(hz, 1), 500)
+	}
 }
 
+const (
+	// expireKeysPerLoop is the number of keys with an expiration sampled by each
+	// iteration of the active expire cycle.
+	expireKeysPerLoop = 20
+	// expireVisitsPerLoop bounds the keys visited to find the samples, so that the cycle
+	// stays cheap when few keys have an expiration.
+	expireVisitsPerLoop = expireKeysPerLoop * 20
+	// expireCyclePercent is the share of each 1/hz period the active expire cycle may use.
+	expireCyclePercent = 25
+)
+
 // Entry is a struct that holds the value and the metadata related to it
 type Entry struct {
+	// Value is an int64, a string or a []byte for string values, the latter being used
+	// for values updated in place such as HyperLogLogs.
 	Value interface{}
 	//The expiration date in unix millis
 	Expiry int64
+	// access is the time of the last access in unix millis, updated by readers holding d.mu
+	// for reading.
+	access atomic.Int64
 }
 
+var (
+	// ErrNotFound is returned when a key is not present or has expired.
+	ErrNotFound = errors.New("not found")
+	// ErrWrongType is returned when an operation is applied to a key holding a different kind of value.
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	// ErrNotInteger is returned when a value cannot be represented as an integer.
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	// ErrNotFloat is returned when a value cannot be represented as a float.
+	ErrNotFloat = errors.New("ERR value is not a valid float")
+)
+
 // KeyNotFoundError is an error struct which holds the missing key.
 type KeyNotFoundError struct {
 	key string
 }
 
-func NewDatastore() *Datastore {
-	return &Datastore{data: make(map[string]*Entry), expChunkSize: 20}
+func NewDatastore(opts ...Option) *Datastore {
+	d := &Datastore{
+		id:    datastoreIDs.Add(1),
+		data:  make(map[string]*Entry),
+		clock: systemClock{},
+		hz:    10,
+		index: newSkiplist(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 func (d *Datastore) Set(key, value string) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	defer d.unlock()
 
-	d.data[key] = newEntry(value, -1)
+	d.store(key, newEntry(value, -1))
+	d.notify(EventString, "set", key)
 }
 
 // SetWithExpiry sets the key/value pair with expiration.
 // Expiry is the amount of millis after which the key will expire
 func (d *Datastore) SetWithExpiry(key, value string, expiry int64) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.data[key] = newEntry(value, time.Now().UnixMilli()+expiry)
+	defer d.unlock()
+	d.store(key, newEntry(value, d.nowMillis()+expiry))
+	d.notify(EventString, "set", key)
+	d.notify(EventGeneric, "expire", key)
 }
 
 // SetWithExpiry sets the key/value pair with expiration.
 // Expiry is the amount the timestamp in millis when the key becomes invalid
 func (d *Datastore) SetWithExactExpiry(key, value string, expiry int64) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.data[key] = newEntry(value, expiry)
+	defer d.unlock()
+	d.store(key, newEntry(value, expiry))
+	d.notify(EventString, "set", key)
+	d.notify(EventGeneric, "expire", key)
 }
 
+// StartExpiryCheck runs the active expire cycle hz times per second, forever.
 func (d *Datastore) StartExpiryCheck() {
 	for {
-		//TODO pause checks if no new keys are added
 		d.ExpiryCheck()
-		time.Sleep(100 * time.Millisecond)
+		time.Sleep(time.Second / time.Duration(d.hz))
 	}
 }
 
-func (d *Datastore) ExpiryCheck() {
-	keys := make([]string, 0)
-	d.mu.RLock()
-	keyCount := len(d.data)
-	sampleSize := min(d.expChunkSize, keyCount)
-	//copy the keys who have a set expiry
-	for k := range maps.Keys(d.data) {
-		if d.data[k].Expiry != -1 {
-			if sampleSize > 0 {
-				keys = append(keys, k)
-				sampleSize -= 1
-			} else {
-				break
-			}
+// ExpiryCheck runs an active expire cycle, deleting expired keys that are not accessed anymore.
+// It samples keys with an expiration and deletes the expired ones, repeating while more than
+// a quarter of the samples were expired and the time budget of the cycle is not exhausted.
+// Returns the number of deleted keys.
+func (d *Datastore) ExpiryCheck() int {
+	start := d.Now()
+	budget := time.Second / time.Duration(d.hz) * expireCyclePercent / 100
+	deleted := 0
+	for {
+		sampled, expired := d.expireSample()
+		deleted += expired
+		if sampled == 0 || expired*4 <= sampled || d.Now().Sub(start) > budget {
+			return deleted
 		}
 	}
-	d.mu.RUnlock()
+}
 
-	for _, k := range keys {
-		d.mu.Lock()
-		delete(d.data, k)
-		d.mu.Unlock()
+// expireSample samples keys with an expiration and deletes the expired ones.
+// Returns the number of sampled and deleted keys.
+func (d *Datastore) expireSample() (sampled, expired int) {
+	d.mu.Lock()
+	defer d.unlock()
+	now := d.nowMillis()
+	visited := 0
+	// Map iteration starts at a random position, which makes it a cheap sampler.
+	for k, e := range d.data {
+		if visited++; visited > expireVisitsPerLoop || sampled == expireKeysPerLoop {
+			break
+		}
+		if e.Expiry == -1 {
+			continue
+		}
+		sampled++
+		if e.expired(now) {
+			d.remove(k)
+			d.SignalModified(k)
+			d.notify(EventExpired, "expired", k)
+			expired++
+		}
 	}
+	return sampled, expired
 }
 
 func (d *Datastore) Get(key string) (string, error) {
 	d.mu.RLock()
-	defer d.mu.RUnlock()
-	if value, ok := d.data[key]; ok {
-		now := time.Now().UnixMilli()
-		if value.Expiry == -1 || now < value.Expiry {
-			var ret string
-			switch value.Value.(type) {
-			case int64:
-				ret = fmt.Sprintf("%d", value.Value.(int64))
-			case string:
-				ret = value.Value.(string)
-			}
-			return ret, nil
+	defer d.runlock()
+	if value := d.lookup(key); value != nil {
+		switch value.Value.(type) {
+		case int64:
+			return fmt.Sprintf("%d", value.Value.(int64)), nil
+		case string:
+			return value.Value.(string), nil
+		case []byte:
+			return string(value.Value.([]byte)), nil
+		default:
+			return "", ErrWrongType
 		}
 	}
-	return "", errors.New("not found")
+	return "", ErrNotFound
 }
 
-func (d *Datastore) Delete(key string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// Exists reports whether the key is present and has not expired, regardless of its type.
+func (d *Datastore) Exists(key string) bool {
+	d.mu.RLock()
+	defer d.runlock()
+	return d.lookup(key) != nil
+}
+
+// store sets the entry of the key, indexing the key if it is new. The caller must hold d.mu for
+// writing.
+func (d *Datastore) store(key string, e *Entry) {
+	if _, ok := d.data[key]; !ok {
+		d.index.insert(scanHash(key), key)
+	}
+	d.data[key] = e
+}
+
+// remove deletes the key and its entry, if present. The caller must hold d.mu for writing.
+func (d *Datastore) remove(key string) {
 	if _, ok := d.data[key]; ok {
+		d.index.delete(scanHash(key), key)
 		delete(d.data, key)
+	}
+}
+
+// lookup returns the entry for the key, or nil if it is missing or expired, and records the
+// access for IdleTime. The caller must hold d.mu.
+func (d *Datastore) lookup(key string) *Entry {
+	e := d.peek(key)
+	if e != nil {
+		e.access.Store(d.nowMillis())
+	}
+	return e
+}
+
+// peek is lookup without recording an access, for the commands that go over the keys such as
+// KEYS or SCAN. Expired keys are deleted when d.mu is released, their watches being flagged
+// right away. The caller must hold d.mu.
+func (d *Datastore) peek(key string) *Entry {
+	value, ok := d.data[key]
+	if !ok {
+		return nil
+	}
+	if value.expired(d.nowMillis()) {
+		d.lazyMu.Lock()
+		d.lazyExpired = append(d.lazyExpired, key)
+		d.lazyMu.Unlock()
+		d.SignalModified(key)
+		return nil
+	}
+	return value
+}
+
+// unlock deletes the keys found expired while d.mu was held for writing, then releases it.
+func (d *Datastore) unlock() {
+	d.deleteLazyExpired()
+	d.mu.Unlock()
+}
+
+// runlock releases d.mu held for reading, then deletes the keys found expired meanwhile.
+func (d *Datastore) runlock() {
+	d.mu.RUnlock()
+	d.lazyMu.Lock()
+	pending := len(d.lazyExpired) > 0
+	d.lazyMu.Unlock()
+	if pending {
+		d.mu.Lock()
+		d.unlock()
+	}
+}
+
+// deleteLazyExpired deletes the keys found expired on access, unless they have been
+// written since. The caller must hold d.mu for writing.
+func (d *Datastore) deleteLazyExpired() {
+	d.lazyMu.Lock()
+	keys := d.lazyExpired
+	d.lazyExpired = nil
+	d.lazyMu.Unlock()
+	now := d.nowMillis()
+	for _, key := range keys {
+		if e, ok := d.data[key]; ok && e.expired(now) {
+			d.remove(key)
+			d.notify(EventExpired, "expired", key)
+		}
+	}
+}
+
+// Now returns the current time according to the clock of the datastore.
+func (d *Datastore) Now() time.Time {
+	return d.clock.Now()
+}
+
+// nowMillis returns the current unix time in milliseconds.
+func (d *Datastore) nowMillis() int64 {
+	return d.clock.Now().UnixMilli()
+}
+
+// expired reports whether the entry has an expiration that is not after now, in unix millis.
+func (e *Entry) expired(now int64) bool {
+	return e.Expiry != -1 && now >= e.Expiry
+}
+
+func (d *Datastore) Delete(key string) error {
+	d.mu.Lock()
+	defer d.unlock()
+	if d.lookup(key) != nil {
+		d.remove(key)
+		d.notify(EventGeneric, "del", key)
 		return nil
 	}
-	return errors.New("not found")
+	return ErrNotFound
 }
 
 func (d *Datastore) Increment(key string) (int64, error) {
@@ -125,13 +326,55 @@ func (d *Datastore) Decrement(key string) (int64, error) {
 	return d.sumWith(key, -1)
 }
 
+// IncrementBy increments the integer stored at key by delta, failing with ErrOverflow
+// instead of wrapping around.
+func (d *Datastore) IncrementBy(key string, delta int64) (int64, error) {
+	return d.sumWith(key, delta)
+}
+
+// IncrementByFloat increments the number stored at key by delta. The sum is computed with the
+// 64 bits mantissa of the long doubles used by Redis and returned formatted the way it is stored.
+func (d *Datastore) IncrementByFloat(key, delta string) (string, error) {
+	incr, err := parseLongDouble(delta)
+	if err != nil {
+		return "", err
+	}
+	d.mu.Lock()
+	defer d.unlock()
+	b, ok, err := d.readBytes(key)
+	if err != nil {
+		return "", err
+	}
+	val := new(big.Float).SetPrec(longDoublePrec)
+	if ok {
+		if val, err = parseLongDouble(string(b)); err != nil {
+			return "", err
+		}
+	}
+	if val.IsInf() || incr.IsInf() {
+		return "", ErrNaNOrInfinity
+	}
+	val.Add(val, incr)
+	if val.MantExp(nil) > longDoubleMaxExp {
+		return "", ErrNaNOrInfinity
+	}
+	s := formatLongDouble(val)
+	exp := int64(-1)
+	if ok {
+		exp = d.data[key].Expiry
+	}
+	d.store(key, newEntry(s, exp))
+	d.notify(EventString, "incrbyfloat", key)
+	return s, nil
+}
+
 func (d *Datastore) sumWith(key string, change int64) (int64, error) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	defer d.unlock()
 	var val int64
 	var exp int64 = -1
-	value, ok := d.data[key]
-	if ok {
+	value := d.lookup(key)
+	if value != nil {
 		switch value.Value.(type) {
 		case int64:
 			val = value.Value.(int64)
@@ -139,33 +382,110 @@ func (d *Datastore) sumWith(key string, change int64) (int64, error) {
 			var err error
 			val, err = strconv.ParseInt(value.Value.(string), 10, 64)
 			if err != nil {
-				return 0, err
+				return 0, ErrNotInteger
+			}
+		case []byte:
+			var err error
+			val, err = strconv.ParseInt(string(value.Value.([]byte)), 10, 64)
+			if err != nil {
+				return 0, ErrNotInteger
 			}
+		default:
+			return 0, ErrWrongType
+		}
+		var ok bool
+		if val, ok = addInt64(val, change); !ok {
+			return 0, ErrOverflow
 		}
-		val += change
 		exp = value.Expiry
 		newEntry := Entry{Value: val, Expiry: exp}
-		d.data[key] = &newEntry
+		d.store(key, &newEntry)
+		d.notify(EventString, "incrby", key)
 		return val, nil
 	} else {
 		v := 0 + change
-		d.data[key] = newEntry(v, -1)
+		d.store(key, newEntry(v, -1))
+		d.notify(EventString, "incrby", key)
 		return v, nil
 	}
+
 }
 
 func (e KeyNotFoundError) Error() string {
 	return fmt.Sprintf("%s not found in datastore", e.key)
 }
 
+// longDoublePrec and longDoubleMaxExp describe the x87 80 bits extended precision format.
+const (
+	longDoublePrec   = 64
+	longDoubleMaxExp = 16384
+)
+
+// parseLongDouble parses a float the way Redis parses long doubles, rejecting NaN,
+// surrounding spaces and overly long strings.
+func parseLongDouble(s string) (*big.Float, error) {
+	if len(s) == 0 || len(s) > 5*1024 || strings.TrimSpace(s) != s {
+		return nil, ErrNotFloat
+	}
+	v, _, err := big.ParseFloat(s, 10, longDoublePrec, big.ToNearestEven)
+	if err != nil {
+		return nil, ErrNotFloat
+	}
+	return v, nil
+}
+
+// formatLongDouble formats a long double with 17 decimals, trimming trailing zeroes
+// like Redis does so that small decimal numbers look the way users typed them.
+func formatLongDouble(v *big.Float) string {
+	s := v.Text('f', 17)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
 func newEntry(value interface{}, expiry int64) *Entry {
 	switch value.(type) {
 	case string:
+		// Only strings that format back to themselves are stored as integers,
+		// so that values such as "007" or "+1" are returned unchanged.
 		v, err := strconv.ParseInt(value.(string), 10, 64)
-		if err == nil {
+		if err == nil && strconv.FormatInt(v, 10) == value.(string) {
 			return &Entry{Value: v, Expiry: expiry}
 		}
 
 	}
 	return &Entry{Value: value, Expiry: expiry}
 }
+
+// readBytes returns the string stored at key as a byte slice that must not be modified,
+// and false if the key does not exist. The caller must hold d.mu.
+func (d *Datastore) readBytes(key string) ([]byte, bool, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, false, nil
+	}
+	switch v := e.Value.(type) {
+	case []byte:
+		return v, true, nil
+	case string:
+		return []byte(v), true, nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), true, nil
+	}
+	return nil, false, ErrWrongType
+}
+
+// getBytes returns the entry holding the string stored at key, or nil if the key does not
+// exist, switching its value to a byte slice so that it can be updated in place.
+// The caller must hold d.mu for writing.
+func (d *Datastore) getBytes(key string) (*Entry, []byte, error) {
+	b, ok, err := d.readBytes(key)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	e := d.data[key]
+	e.Value = b
+	return e, b, nil
+}
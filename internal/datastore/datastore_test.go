@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 	"time"
@@ -37,8 +38,10 @@ func TestSetAndGetFromDatastore(t *testing.T) {
 }
 
 func TestSetWithExpiry(t *testing.T) {
-	ds := NewDatastore()
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
 	ds.SetWithExpiry("key", "value", 500) //expire in 500 millis
+	clock.Advance(499 * time.Millisecond)
 	got, err := ds.Get("key")
 	if err != nil {
 		t.Errorf("Unexpected error %v", err)
@@ -46,7 +49,7 @@ func TestSetWithExpiry(t *testing.T) {
 	if got != "value" {
 		t.Errorf("Expected 'value', got '%s'", got)
 	}
-	time.Sleep(500 * time.Millisecond)
+	clock.Advance(time.Millisecond)
 	_, err = ds.Get("key")
 	if err == nil {
 		t.Errorf("Key has not expired, but it should have")
@@ -54,8 +57,9 @@ func TestSetWithExpiry(t *testing.T) {
 }
 
 func TestSetWithExactExpiry(t *testing.T) {
-	ds := NewDatastore()
-	ds.SetWithExactExpiry("key", "value", time.Now().Add(500*time.Millisecond).UnixMilli()) //expire in 500 millis
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.SetWithExactExpiry("key", "value", clock.Now().Add(500*time.Millisecond).UnixMilli()) //expire in 500 millis
 	got, err := ds.Get("key")
 	if err != nil {
 		t.Errorf("Unexpected error %v", err)
@@ -63,7 +67,7 @@ func TestSetWithExactExpiry(t *testing.T) {
 	if got != "value" {
 		t.Errorf("Expected 'value', got '%s'", got)
 	}
-	time.Sleep(500 * time.Millisecond)
+	clock.Advance(500 * time.Millisecond)
 	_, err = ds.Get("key")
 	if err == nil {
 		t.Errorf("Key has not expired, but it should have")
@@ -71,39 +75,115 @@ func TestSetWithExactExpiry(t *testing.T) {
 }
 
 func TestExpiryCheck(t *testing.T) {
-	ds := NewDatastore()
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
 	for i := range 100 { //100 permanent keys
 		key := fmt.Sprintf("key%d", i)
 		ds.Set(key, "value")
 	}
 	for i := range 100 { //100 perishable keys
 		key := fmt.Sprintf("key%d", i+100)
-		ds.SetWithExactExpiry(key, "value", time.Now().Add(1*time.Millisecond).UnixMilli())
+		ds.SetWithExpiry(key, "value", 1)
 	}
 	if len(ds.data) != 200 {
 		t.Errorf("Expected 200 items, got %d", len(ds.data))
 	}
-	ds.ExpiryCheck() //should remove 20 items by default
-	if len(ds.data) != 180 {
-		t.Errorf("Expected 180 items, got %d", len(ds.data))
+	if deleted := ds.ExpiryCheck(); deleted != 0 || len(ds.data) != 200 {
+		t.Errorf("Expected no key to be deleted before they expire, got %d", deleted)
+	}
+	clock.Advance(time.Millisecond)
+	// Every sample is expired, so the cycle goes on until none is left.
+	if deleted := ds.ExpiryCheck(); deleted != 100 || len(ds.data) != 100 {
+		t.Errorf("Expected the 100 expired keys to be deleted, got %d", deleted)
+	}
+}
+
+func TestExpiryCheckStopsWhenFewKeysAreExpired(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	for i := range 100 {
+		ds.SetWithExpiry(fmt.Sprintf("key%d", i), "value", 10000)
+	}
+	for i := range 5 {
+		ds.SetWithExpiry(fmt.Sprintf("key%d", i+100), "value", 1)
+	}
+	clock.Advance(time.Millisecond)
+	// At most 5 of the 20 samples can be expired, which does not warrant another iteration.
+	if deleted := ds.ExpiryCheck(); deleted > 5 || len(ds.data) != 105-deleted {
+		t.Errorf("Expected a single iteration of the cycle, got %d deleted keys", deleted)
+	}
+	for i := range 100 {
+		if _, ok := ds.data[fmt.Sprintf("key%d", i)]; !ok {
+			t.Errorf("Expected key%d not to be deleted", i)
+		}
+	}
+}
+
+func TestExpiryCheckTimeBudget(t *testing.T) {
+	clock := &slowClock{ManualClock: NewManualClock(time.UnixMilli(1000000)), step: 10 * time.Millisecond}
+	ds := NewDatastore(WithHz(10), WithClock(clock))
+	for i := range 1000 {
+		ds.SetWithExactExpiry(fmt.Sprintf("key%d", i), "value", 1000000)
+	}
+	// Each reading of the clock takes 10ms, so the 25ms budget is exhausted after a few samples.
+	if deleted := ds.ExpiryCheck(); deleted == 0 || deleted > 3*expireKeysPerLoop {
+		t.Errorf("Expected the cycle to stop after a few samples, deleted %d keys", deleted)
+	}
+}
+
+// slowClock is a ManualClock advancing by step each time it is read.
+type slowClock struct {
+	*ManualClock
+	step time.Duration
+}
+
+func (c *slowClock) Now() time.Time {
+	c.Advance(c.step)
+	return c.ManualClock.Now()
+}
+
+func TestLazyExpiry(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.SetWithExpiry("key", "value", 100)
+	ds.RPush("list", "a")
+	ds.Expire("list", Expiry{Mode: ExpiryAfter, Millis: 100}, ExpireOptions{})
+	clock.Advance(100 * time.Millisecond)
+	if _, err := ds.Get("key"); err != ErrNotFound {
+		t.Errorf("Expected %v, got %v", ErrNotFound, err)
+	}
+	if _, ok := ds.data["key"]; ok {
+		t.Errorf("Expected the expired key to be deleted on read")
+	}
+	if n, _ := ds.RPush("list", "b"); n != 1 {
+		t.Errorf("Expected an expired list to be replaced, got length %d", n)
+	}
+	if ds.TTL("list") != -1 {
+		t.Errorf("Expected the new list not to expire")
+	}
+	ds.SetWithExpiry("key", "value", 100)
+	clock.Advance(100 * time.Millisecond)
+	if err := ds.Delete("key"); err != ErrNotFound {
+		t.Errorf("Expected deleting an expired key to report it missing, got %v", err)
 	}
 }
 
 func TestStartExpiryCheck(t *testing.T) {
-	ds := NewDatastore()
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithHz(100), WithClock(clock))
 	for i := range 100 {
 		key := fmt.Sprintf("key%d", i)
 		ds.Set(key, "value")
 	}
 	for i := range 100 {
 		key := fmt.Sprintf("key%d", i+100)
-		ds.SetWithExactExpiry(key, "value", time.Now().Add(1*time.Millisecond).UnixMilli())
-	}
-	if len(ds.data) != 200 {
-		t.Errorf("Expected 200 items, got %d", len(ds.data))
+		ds.SetWithExpiry(key, "value", 1)
 	}
+	clock.Advance(time.Millisecond)
 	go ds.StartExpiryCheck()
-	time.Sleep(2 * time.Second)
+	time.Sleep(500 * time.Millisecond)
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
 	if len(ds.data) != 100 {
 		t.Errorf("Expected 100 items, got %d", len(ds.data))
 	}
@@ -185,3 +265,67 @@ func TestDecrementNotInt(t *testing.T) {
 		t.Errorf("Expected an error when decrementing a non-integer value")
 	}
 }
+
+func TestNonCanonicalIntegersAreKept(t *testing.T) {
+	ds := NewDatastore()
+	for _, value := range []string{"007", "+1", "-0", " 1", "12", "-12"} {
+		ds.Set("key", value)
+		if got, _ := ds.Get("key"); got != value {
+			t.Errorf("Expected %q, got %q", value, got)
+		}
+	}
+	ds.Set("key", "12")
+	if _, ok := ds.data["key"].Value.(int64); !ok {
+		t.Errorf("Expected a canonical integer to be stored as int64")
+	}
+}
+
+func TestIncrementByOverflow(t *testing.T) {
+	ds := NewDatastore()
+	ds.Set("key", "9223372036854775800")
+	if _, err := ds.IncrementBy("key", 8); err != ErrOverflow {
+		t.Errorf("Expected %v, got %v", ErrOverflow, err)
+	}
+	if val, _ := ds.Get("key"); val != "9223372036854775800" {
+		t.Errorf("Expected the value to be unchanged, got %s", val)
+	}
+	if val, err := ds.IncrementBy("key", 7); err != nil || val != math.MaxInt64 {
+		t.Errorf("Expected %d, got %d %v", int64(math.MaxInt64), val, err)
+	}
+}
+
+func TestIncrementByFloat(t *testing.T) {
+	ds := NewDatastore()
+	tests := []struct {
+		initial, delta, expected string
+	}{
+		{"10.50", "0.1", "10.6"},
+		{"0.1", "0.2", "0.3"},
+		{"5.0e3", "2.0e2", "5200"},
+		{"3", "-3", "0"},
+		{"", "1.5", "1.5"},
+	}
+	for _, test := range tests {
+		if test.initial != "" {
+			ds.Set("key", test.initial)
+		} else {
+			ds.Delete("key")
+		}
+		val, err := ds.IncrementByFloat("key", test.delta)
+		if err != nil || val != test.expected {
+			t.Errorf("%s + %s: expected %s, got %s %v", test.initial, test.delta, test.expected, val, err)
+		}
+	}
+	if _, err := ds.IncrementByFloat("key", "inf"); err != ErrNaNOrInfinity {
+		t.Errorf("Expected %v, got %v", ErrNaNOrInfinity, err)
+	}
+	for _, delta := range []string{"x", " 1", "nan", ""} {
+		if _, err := ds.IncrementByFloat("key", delta); err != ErrNotFloat {
+			t.Errorf("Expected %q to be rejected, got %v", delta, err)
+		}
+	}
+	ds.Set("key", "one")
+	if _, err := ds.IncrementByFloat("key", "1"); err != ErrNotFloat {
+		t.Errorf("Expected %v, got %v", ErrNotFloat, err)
+	}
+}
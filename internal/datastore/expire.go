@@ -0,0 +1,74 @@
+package datastore
+
+// ExpireOptions are the conditions of EXPIRE and its variants. NX and XX require the key to
+// have no expiration or an expiration, GT and LT require the new one to be later or earlier
+// than the current one, a key without expiration counting as expiring never.
+type ExpireOptions struct {
+	NX, XX, GT, LT bool
+}
+
+// Expire sets the expiration of the key when the conditions hold, deleting the key when the
+// expiration is in the past. Returns whether the key exists and the expiration was changed.
+func (d *Datastore) Expire(key string, expiry Expiry, opts ExpireOptions) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	e := d.lookup(key)
+	now := d.nowMillis()
+	at, err := expiry.at(-1, now)
+	if err != nil || e == nil {
+		return false, err
+	}
+	current := e.Expiry
+	switch {
+	case opts.NX && current != -1,
+		opts.XX && current == -1,
+		opts.GT && (current == -1 || at <= current),
+		opts.LT && current != -1 && at >= current:
+		return false, nil
+	}
+	if at <= now {
+		d.remove(key)
+		d.notify(EventGeneric, "del", key)
+	} else {
+		e.Expiry = at
+		d.notify(EventGeneric, "expire", key)
+	}
+	return true, nil
+}
+
+// TTL returns the remaining time to live of the key in milliseconds,
+// -1 if it has no expiration and -2 if it does not exist.
+func (d *Datastore) TTL(key string) int64 {
+	at := d.ExpireTime(key)
+	if at < 0 {
+		return at
+	}
+	return max(at-d.nowMillis(), 0)
+}
+
+// ExpireTime returns the unix time in milliseconds at which the key expires,
+// -1 if it has no expiration and -2 if it does not exist.
+func (d *Datastore) ExpireTime(key string) int64 {
+	d.mu.RLock()
+	defer d.runlock()
+	e := d.lookup(key)
+	if e == nil {
+		return -2
+	}
+	return e.Expiry
+}
+
+// Persist removes the expiration of the key. Returns false if the key
+// does not exist or has no expiration.
+func (d *Datastore) Persist(key string) bool {
+	d.mu.Lock()
+	defer d.unlock()
+	e := d.lookup(key)
+	if e == nil || e.Expiry == -1 {
+		return false
+	}
+	e.Expiry = -1
+	d.notify(EventGeneric, "persist", key)
+	return true
+
+}
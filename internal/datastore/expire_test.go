@@ -0,0 +1,44 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.Set("k", "v")
+	if ok, _ := ds.Expire("missing", Expiry{Mode: ExpiryAfter, Millis: 1000}, ExpireOptions{}); ok {
+		t.Errorf("Expected a missing key not to be expired")
+	}
+	if ok, _ := ds.Expire("k", Expiry{Mode: ExpiryAfter, Millis: 10000}, ExpireOptions{GT: true}); ok {
+		t.Errorf("Expected GT to fail on a key without expiration")
+	}
+	if ok, _ := ds.Expire("k", Expiry{Mode: ExpiryAfter, Millis: 10000}, ExpireOptions{NX: true}); !ok {
+		t.Errorf("Expected NX to succeed on a key without expiration")
+	}
+	clock.Advance(time.Second)
+	if ttl := ds.TTL("k"); ttl != 9000 {
+		t.Errorf("Expected a TTL of 9000, got %d", ttl)
+	}
+	if ok, _ := ds.Expire("k", Expiry{Mode: ExpiryAfter, Millis: 20000}, ExpireOptions{LT: true}); ok {
+		t.Errorf("Expected LT to fail with a later expiration")
+	}
+	at := clock.Now().UnixMilli() + 5000
+	if ok, _ := ds.Expire("k", Expiry{Mode: ExpiryAt, Millis: at}, ExpireOptions{XX: true, LT: true}); !ok {
+		t.Errorf("Expected XX LT to succeed with an earlier expiration")
+	}
+	if got := ds.ExpireTime("k"); got != at {
+		t.Errorf("Expected expiration %d, got %d", at, got)
+	}
+	if !ds.Persist("k") || ds.TTL("k") != -1 {
+		t.Errorf("Expected PERSIST to remove the expiration")
+	}
+	if ok, _ := ds.Expire("k", Expiry{Mode: ExpiryAt, Millis: 1}, ExpireOptions{}); !ok || ds.Exists("k") {
+		t.Errorf("Expected an expiration in the past to delete the key")
+	}
+	if ds.TTL("k") != -2 || ds.ExpireTime("k") != -2 {
+		t.Errorf("Expected -2 for a missing key")
+	}
+}
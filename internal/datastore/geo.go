@@ -0,0 +1,511 @@
+package datastore
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Geo members are stored in sorted sets scored by the 52 bits interleaved geohash of their
+// coordinates. The encoding and the search algorithm follow Redis so that scores, positions
+// and search results are identical to it.
+const (
+	geoLongMin  = -180.0
+	geoLongMax  = 180.0
+	geoLatMin   = -85.05112878
+	geoLatMax   = 85.05112878
+	geoStepMax  = 26
+	mercatorMax = 20037726.37
+	// earthRadius is the radius used by Redis for the haversine formula, in meters.
+	earthRadius = 6372797.560856
+)
+
+// ErrGeoMemberNotFound is returned when the member at the center of a search does not exist.
+var ErrGeoMemberNotFound = errors.New("ERR could not decode requested zset member")
+
+// GeoPoint is a position in degrees.
+type GeoPoint struct {
+	Longitude, Latitude float64
+}
+
+// GeoMember is a member of a geospatial index and its position.
+type GeoMember struct {
+	Member string
+	GeoPoint
+}
+
+// GeoSort selects the order of search results by distance.
+type GeoSort int
+
+const (
+	GeoSortNone GeoSort = iota
+	GeoSortAsc
+	GeoSortDesc
+)
+
+// GeoQuery describes the area searched by GEOSEARCH and how results are returned.
+type GeoQuery struct {
+	// FromMember centers the search on Member, otherwise it is centered on Center.
+	FromMember bool
+	Member     string
+	Center     GeoPoint
+	// Box searches a Width by Height rectangle, otherwise a circle of Radius.
+	// Distances are expressed in a unit of Conversion meters.
+	Box                   bool
+	Radius, Width, Height float64
+	Conversion            float64
+	Sort                  GeoSort
+	// Count limits the number of results when it is positive. With Any the search stops as soon
+	// as enough matches are found instead of returning the closest ones.
+	Count int64
+	Any   bool
+}
+
+// GeoResult is a member found by a search, with its distance from the center in the query unit.
+type GeoResult struct {
+	GeoMember
+	Distance float64
+	Hash     uint64
+}
+
+// geoHashBits is a geohash of step*2 bits.
+type geoHashBits struct {
+	bits uint64
+	step uint
+}
+
+type geoRange struct {
+	min, max float64
+}
+
+type geoArea struct {
+	longitude, latitude geoRange
+}
+
+// GeoAdd adds the members to the geospatial index stored at key, or updates their positions,
+// subject to the options. Returns the number of added and of updated members.
+func (d *Datastore) GeoAdd(key string, opts ZAddOptions, members []GeoMember) (int64, int64, error) {
+	scored := make([]ScoredMember, len(members))
+	for i, m := range members {
+		scored[i] = ScoredMember{Member: m.Member, Score: float64(geoEncode(m.GeoPoint))}
+	}
+	return d.ZAdd(key, opts, scored)
+}
+
+// GeoPos returns the positions of the members of the geospatial index stored at key,
+// nil for missing members.
+func (d *Datastore) GeoPos(key string, members ...string) ([]*GeoPoint, error) {
+	scores, err := d.ZMScore(key, members...)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*GeoPoint, len(scores))
+	for i, score := range scores {
+		if score != nil {
+			p := geoDecode(uint64(*score))
+			ret[i] = &p
+		}
+	}
+	return ret, nil
+}
+
+// GeoDist returns the distance in meters between two members of the geospatial index
+// stored at key, or false if either of them does not exist.
+func (d *Datastore) GeoDist(key, member1, member2 string) (float64, bool, error) {
+	pos, err := d.GeoPos(key, member1, member2)
+	if err != nil || pos[0] == nil || pos[1] == nil {
+		return 0, false, err
+	}
+	return geoDistance(*pos[0], *pos[1]), true, nil
+}
+
+// GeoHash returns the standard 11 characters geohash of the members of the geospatial index
+// stored at key, nil for missing members.
+func (d *Datastore) GeoHash(key string, members ...string) ([]*string, error) {
+	const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+	pos, err := d.GeoPos(key, members...)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*string, len(pos))
+	for i, p := range pos {
+		if p == nil {
+			continue
+		}
+		// Unlike scores, standard geohashes cover latitudes up to the poles.
+		hash, _ := geoEncodeRange(geoRange{geoLongMin, geoLongMax}, geoRange{-90, 90}, *p, geoStepMax)
+		buf := make([]byte, 11)
+		for j := range buf {
+			idx := 0
+			// The last character only has zero bits, as there are just 52 of them.
+			if j < 10 {
+				idx = int(hash.bits>>(52-(j+1)*5)) & 0x1f
+			}
+			buf[j] = alphabet[idx]
+		}
+		s := string(buf)
+		ret[i] = &s
+	}
+	return ret, nil
+}
+
+// GeoSearch returns the members of the geospatial index stored at key within the area of the query.
+func (d *Datastore) GeoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil {
+		return []GeoResult{}, err
+	}
+	return z.geoSearch(q)
+}
+
+// GeoSearchStore stores at destination the members of the geospatial index stored at source
+// within the area of the query, scored by their distance from the center when storeDist is set.
+// destination is deleted when there are no results. Returns the number of stored members.
+func (d *Datastore) GeoSearchStore(destination, source string, q GeoQuery, storeDist bool) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(source)
+	if err != nil {
+		return 0, err
+	}
+	results := []GeoResult{}
+	if z != nil {
+		if results, err = z.geoSearch(q); err != nil {
+			return 0, err
+		}
+	}
+	dst := NewSortedSet()
+	for _, r := range results {
+		score := float64(r.Hash)
+		if storeDist {
+			score = r.Distance
+		}
+		dst.Add(r.Member, score)
+	}
+	d.storeSortedSet(destination, dst, "geosearchstore")
+	return int64(dst.Len()), nil
+}
+
+// geoSearch scans the geohash box containing the center of the query and its neighbors,
+// keeping the members within the area.
+func (z *SortedSet) geoSearch(q GeoQuery) ([]GeoResult, error) {
+	center := q.Center
+	if q.FromMember {
+		score, ok := z.Score(q.Member)
+		if !ok {
+			return nil, ErrGeoMemberNotFound
+		}
+		center = geoDecode(uint64(score))
+	}
+	var limit int64
+	if q.Any {
+		limit = q.Count
+	}
+	boxes := geoSearchBoxes(q, center)
+	results := make([]GeoResult, 0)
+	last := -1
+	for i, box := range boxes {
+		if box.bits == 0 && box.step == 0 {
+			continue
+		}
+		// Adjacent boxes can be the same with huge radiuses. The center box is never
+		// compared, as in Redis.
+		if last > 0 && box == boxes[last] {
+			continue
+		}
+		if limit > 0 && int64(len(results)) >= limit {
+			break
+		}
+		min := box.bits << (52 - box.step*2)
+		max := (box.bits + 1) << (52 - box.step*2)
+		members := z.Range(ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: float64(min), Max: float64(max), MaxEx: true}, Count: -1})
+		for _, m := range members {
+			if limit > 0 && int64(len(results)) >= limit {
+				break
+			}
+			p := geoDecode(uint64(m.Score))
+			distance, ok := q.contains(center, p)
+			if !ok {
+				continue
+			}
+			results = append(results, GeoResult{
+				GeoMember: GeoMember{Member: m.Member, GeoPoint: p},
+				Distance:  distance / q.Conversion,
+				Hash:      uint64(m.Score),
+			})
+		}
+		last = i
+	}
+	order := q.Sort
+	if order == GeoSortNone && q.Count > 0 && !q.Any {
+		// The closest members are returned when COUNT is given without ANY.
+		order = GeoSortAsc
+	}
+	switch order {
+	case GeoSortAsc:
+		sortGeoResults(results, func(a, b float64) bool { return a < b })
+	case GeoSortDesc:
+		sortGeoResults(results, func(a, b float64) bool { return a > b })
+	}
+	if q.Count > 0 && int64(len(results)) > q.Count {
+		results = results[:q.Count]
+	}
+	return results, nil
+}
+
+func sortGeoResults(results []GeoResult, less func(a, b float64) bool) {
+	sort.SliceStable(results, func(i, j int) bool { return less(results[i].Distance, results[j].Distance) })
+}
+
+// contains returns the distance in meters of p from the center and whether p is within the area.
+func (q GeoQuery) contains(center, p GeoPoint) (float64, bool) {
+	if !q.Box {
+		distance := geoDistance(center, p)
+		return distance, distance <= q.Radius*q.Conversion
+	}
+	// The latitude distance is cheaper to compute, so it is checked first.
+	if geoLatDistance(p.Latitude, center.Latitude) > q.Height*q.Conversion/2 {
+		return 0, false
+	}
+	if geoDistance(p, GeoPoint{Longitude: center.Longitude, Latitude: p.Latitude}) > q.Width*q.Conversion/2 {
+		return 0, false
+	}
+	return geoDistance(center, p), true
+}
+
+// geoSearchBoxes returns the geohash box containing the center, followed by its north, south,
+// east, west, north east, north west, south east and south west neighbors. The step is chosen
+// so that the boxes cover the whole area; neighbors that cannot intersect it are zeroed.
+func geoSearchBoxes(q GeoQuery, center GeoPoint) [9]geoHashBits {
+	height, width := q.Radius, q.Radius
+	radius := q.Radius
+	if q.Box {
+		height, width = q.Height/2, q.Width/2
+		radius = math.Sqrt(q.Width/2*(q.Width/2) + q.Height/2*(q.Height/2))
+	}
+	height *= q.Conversion
+	width *= q.Conversion
+	radius *= q.Conversion
+
+	// Bounding box of the area.
+	latDelta := radDeg(height / earthRadius)
+	longDeltaTop := radDeg(width / earthRadius / math.Cos(degRad(center.Latitude+latDelta)))
+	longDeltaBottom := radDeg(width / earthRadius / math.Cos(degRad(center.Latitude-latDelta)))
+	minLon, maxLon := center.Longitude-longDeltaTop, center.Longitude+longDeltaTop
+	if center.Latitude < 0 {
+		minLon, maxLon = center.Longitude-longDeltaBottom, center.Longitude+longDeltaBottom
+	}
+	minLat, maxLat := center.Latitude-latDelta, center.Latitude+latDelta
+
+	longRange, latRange := geoRange{geoLongMin, geoLongMax}, geoRange{geoLatMin, geoLatMax}
+	step := geoEstimateSteps(radius, center.Latitude)
+	hash, _ := geoEncodeRange(longRange, latRange, center, step)
+	neighbors := geoNeighbors(hash)
+	area := geoDecodeArea(longRange, latRange, hash)
+
+	// The estimated step may be too big when the area is near the edge of the box.
+	north := geoDecodeArea(longRange, latRange, neighbors[0])
+	south := geoDecodeArea(longRange, latRange, neighbors[1])
+	east := geoDecodeArea(longRange, latRange, neighbors[2])
+	west := geoDecodeArea(longRange, latRange, neighbors[3])
+	decrease := north.latitude.max < maxLat || south.latitude.min > minLat ||
+		east.longitude.max < maxLon || west.longitude.min > minLon
+	if step > 1 && decrease {
+		step--
+		hash, _ = geoEncodeRange(longRange, latRange, center, step)
+		neighbors = geoNeighbors(hash)
+		area = geoDecodeArea(longRange, latRange, hash)
+	}
+
+	// Exclude the neighbors that cannot intersect the area.
+	const (
+		n, s, e, w, ne, nw, se, sw = 0, 1, 2, 3, 4, 5, 6, 7
+	)
+	if step >= 2 {
+		if area.latitude.min < minLat {
+			neighbors[s], neighbors[sw], neighbors[se] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.latitude.max > maxLat {
+			neighbors[n], neighbors[ne], neighbors[nw] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.longitude.min < minLon {
+			neighbors[w], neighbors[sw], neighbors[nw] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.longitude.max > maxLon {
+			neighbors[e], neighbors[se], neighbors[ne] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+	}
+	var boxes [9]geoHashBits
+	boxes[0] = hash
+	copy(boxes[1:], neighbors[:])
+	return boxes
+}
+
+// geoEstimateSteps returns the geohash step whose boxes are big enough to cover the radius.
+func geoEstimateSteps(radius, latitude float64) uint {
+	if radius == 0 {
+		return geoStepMax
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	// Boxes are narrower towards the poles.
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+	return uint(min(max(step, 1), geoStepMax))
+}
+
+// geoNeighbors returns the north, south, east, west, north east, north west, south east
+// and south west neighbors of the box.
+func geoNeighbors(hash geoHashBits) [8]geoHashBits {
+	move := func(dx, dy int) geoHashBits {
+		return geoMoveY(geoMoveX(hash, dx), dy)
+	}
+	return [8]geoHashBits{move(0, 1), move(0, -1), move(1, 0), move(-1, 0), move(1, 1), move(-1, 1), move(1, -1), move(-1, -1)}
+}
+
+// geoMoveX moves the box east or west. Longitude bits are the odd ones.
+func geoMoveX(hash geoHashBits, d int) geoHashBits {
+	if d == 0 {
+		return hash
+	}
+	x := hash.bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - hash.step*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - hash.step*2)
+	return geoHashBits{bits: x | y, step: hash.step}
+}
+
+// geoMoveY moves the box north or south. Latitude bits are the even ones.
+func geoMoveY(hash geoHashBits, d int) geoHashBits {
+	if d == 0 {
+		return hash
+	}
+	x := hash.bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - hash.step*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - hash.step*2)
+	return geoHashBits{bits: x | y, step: hash.step}
+}
+
+// geoEncode returns the 52 bits geohash used as the score of the point.
+func geoEncode(p GeoPoint) uint64 {
+	hash, _ := geoEncodeRange(geoRange{geoLongMin, geoLongMax}, geoRange{geoLatMin, geoLatMax}, p, geoStepMax)
+	return hash.bits
+}
+
+// GeoValid reports whether the point can be indexed.
+func GeoValid(p GeoPoint) bool {
+	return p.Longitude >= geoLongMin && p.Longitude <= geoLongMax && p.Latitude >= geoLatMin && p.Latitude <= geoLatMax
+}
+
+func geoEncodeRange(longRange, latRange geoRange, p GeoPoint, step uint) (geoHashBits, bool) {
+	if !GeoValid(p) {
+		return geoHashBits{}, false
+	}
+	latOffset := (p.Latitude - latRange.min) / (latRange.max - latRange.min)
+	longOffset := (p.Longitude - longRange.min) / (longRange.max - longRange.min)
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+	return geoHashBits{bits: interleave64(uint32(latOffset), uint32(longOffset)), step: step}, true
+}
+
+// geoDecode returns the center of the box of the 52 bits geohash.
+func geoDecode(bits uint64) GeoPoint {
+	area := geoDecodeArea(geoRange{geoLongMin, geoLongMax}, geoRange{geoLatMin, geoLatMax}, geoHashBits{bits: bits, step: geoStepMax})
+	return GeoPoint{
+		Longitude: min(max((area.longitude.min+area.longitude.max)/2, geoLongMin), geoLongMax),
+		Latitude:  min(max((area.latitude.min+area.latitude.max)/2, geoLatMin), geoLatMax),
+	}
+}
+
+func geoDecodeArea(longRange, latRange geoRange, hash geoHashBits) geoArea {
+	sep := deinterleave64(hash.bits)
+	latScale := latRange.max - latRange.min
+	longScale := longRange.max - longRange.min
+	ilato := uint64(uint32(sep))
+	ilono := uint64(uint32(sep >> 32))
+	steps := float64(uint64(1) << hash.step)
+	return geoArea{
+		latitude: geoRange{
+			min: latRange.min + (float64(ilato)*1.0/steps)*latScale,
+			max: latRange.min + (float64(ilato+1)*1.0/steps)*latScale,
+		},
+		longitude: geoRange{
+			min: longRange.min + (float64(ilono)*1.0/steps)*longScale,
+			max: longRange.min + (float64(ilono+1)*1.0/steps)*longScale,
+		},
+	}
+}
+
+// interleave64 interleaves the bits of x and y, x taking the even positions.
+func interleave64(xlo, ylo uint32) uint64 {
+	b := [...]uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F, 0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF}
+	s := [...]uint{1, 2, 4, 8, 16}
+	x, y := uint64(xlo), uint64(ylo)
+	for i := 4; i >= 0; i-- {
+		x = (x | (x << s[i])) & b[i]
+		y = (y | (y << s[i])) & b[i]
+	}
+	return x | (y << 1)
+}
+
+// deinterleave64 reverses interleave64, returning x in the low and y in the high 32 bits.
+func deinterleave64(interleaved uint64) uint64 {
+	b := [...]uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F, 0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF, 0x00000000FFFFFFFF}
+	s := [...]uint{0, 1, 2, 4, 8, 16}
+	x := interleaved
+	y := interleaved >> 1
+	for i := 0; i < 6; i++ {
+		x = (x | (x >> s[i])) & b[i]
+		y = (y | (y >> s[i])) & b[i]
+	}
+	return x | (y << 32)
+}
+
+func degRad(deg float64) float64 {
+	return deg * (math.Pi / 180.0)
+}
+
+func radDeg(rad float64) float64 {
+	return rad / (math.Pi / 180.0)
+}
+
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// geoDistance returns the distance in meters between two points using the haversine formula.
+func geoDistance(p1, p2 GeoPoint) float64 {
+	lon1r, lon2r := degRad(p1.Longitude), degRad(p2.Longitude)
+	v := math.Sin((lon2r - lon1r) / 2)
+	// The longitudes are practically the same, avoid the expensive math.
+	if v == 0 {
+		return geoLatDistance(p1.Latitude, p2.Latitude)
+	}
+	lat1r, lat2r := degRad(p1.Latitude), degRad(p2.Latitude)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2.0 * earthRadius * math.Asin(math.Sqrt(a))
+}
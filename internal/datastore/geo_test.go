@@ -0,0 +1,101 @@
+package datastore
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGeoEncodeDecode(t *testing.T) {
+	p := GeoPoint{Longitude: 13.361389, Latitude: 38.115556}
+	bits := geoEncode(p)
+	if bits != 3479099956230698 {
+		t.Errorf("Expected score 3479099956230698, got %d", bits)
+	}
+	decoded := geoDecode(bits)
+	if geoDistance(p, decoded) > 1 {
+		t.Errorf("Expected %v to decode close to %v", decoded, p)
+	}
+	if GeoValid(GeoPoint{Longitude: 0, Latitude: 86}) || GeoValid(GeoPoint{Longitude: -181, Latitude: 0}) {
+		t.Errorf("Expected coordinates out of range to be rejected")
+	}
+}
+
+func TestGeoDist(t *testing.T) {
+	ds := NewDatastore()
+	ds.GeoAdd("Sicily", ZAddOptions{}, []GeoMember{
+		{Member: "Palermo", GeoPoint: GeoPoint{Longitude: 13.361389, Latitude: 38.115556}},
+		{Member: "Catania", GeoPoint: GeoPoint{Longitude: 15.087269, Latitude: 37.502669}},
+	})
+	d, ok, err := ds.GeoDist("Sicily", "Palermo", "Catania")
+	if err != nil || !ok || strconv.FormatFloat(d, 'f', 4, 64) != "166274.1516" {
+		t.Errorf("Expected a distance of 166274.1516, got %f %v %v", d, ok, err)
+	}
+	if _, ok, _ := ds.GeoDist("Sicily", "Palermo", "missing"); ok {
+		t.Errorf("Expected no distance to a missing member")
+	}
+}
+
+// TestGeoSearchCoverage checks the geohash boxes scanned by searches against a linear scan,
+// including areas near the poles and the antimeridian.
+func TestGeoSearchCoverage(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, center := range []GeoPoint{{Longitude: 15, Latitude: 37}, {Longitude: 179.9, Latitude: -10}, {Longitude: -40, Latitude: 82}} {
+		ds := NewDatastore()
+		members := make([]GeoMember, 2000)
+		for i := 0; i < len(members); {
+			p := GeoPoint{Longitude: center.Longitude + r.Float64()*20 - 10, Latitude: center.Latitude + r.Float64()*10 - 5}
+			if GeoValid(p) {
+				members[i] = GeoMember{Member: strconv.Itoa(i), GeoPoint: p}
+				i++
+			}
+		}
+		ds.GeoAdd("points", ZAddOptions{}, members)
+		for _, q := range []GeoQuery{
+			{Center: center, Radius: 300, Conversion: 1000},
+			{Center: center, Box: true, Width: 500, Height: 200, Conversion: 1000},
+			{Center: center, Radius: 100, Conversion: 1609.34},
+		} {
+			results, err := ds.GeoSearch("points", q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			z, _ := ds.getSortedSet("points")
+			var expected []string
+			for _, m := range members {
+				score, _ := z.Score(m.Member)
+				if _, ok := q.contains(center, geoDecode(uint64(score))); ok {
+					expected = append(expected, m.Member)
+				}
+			}
+			got := make([]string, len(results))
+			for i, res := range results {
+				got[i] = res.Member
+			}
+			sort.Strings(expected)
+			sort.Strings(got)
+			if len(expected) == 0 || strings.Join(got, ",") != strings.Join(expected, ",") {
+				t.Errorf("Search %+v: expected %d members, got %d", q, len(expected), len(got))
+			}
+		}
+	}
+}
+
+func TestGeoSearchCount(t *testing.T) {
+	ds := NewDatastore()
+	members := make([]GeoMember, 0)
+	for i := 0; i < 10; i++ {
+		members = append(members, GeoMember{Member: strconv.Itoa(i), GeoPoint: GeoPoint{Longitude: float64(i) / 10, Latitude: 0}})
+	}
+	ds.GeoAdd("points", ZAddOptions{}, members)
+	results, _ := ds.GeoSearch("points", GeoQuery{Radius: 1000, Conversion: 1000, Count: 3})
+	if len(results) != 3 || results[0].Member != "0" || results[1].Member != "1" || results[2].Member != "2" {
+		t.Errorf("Expected the 3 closest members, got %v", results)
+	}
+	results, _ = ds.GeoSearch("points", GeoQuery{Radius: 1000, Conversion: 1000, Count: 3, Any: true, Sort: GeoSortDesc})
+	if len(results) != 3 || results[0].Distance < results[1].Distance || results[1].Distance < results[2].Distance {
+		t.Errorf("Expected any 3 members sorted by descending distance, got %v", results)
+	}
+}
@@ -0,0 +1,72 @@
+package datastore
+
+// GlobMatch reports whether s matches the glob-style pattern the way Redis matches KEYS
+// patterns: * matches any sequence, ? any byte, [abc], [^abc] and [a-z] a class of bytes,
+// and \ escapes the next byte.
+func GlobMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// Only the last star has to be backtracked to, as it can absorb whatever an earlier one did.
+	star, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				star, starI = p, i
+				p++
+				continue
+			}
+			if next, ok := globMatchByte(pattern, p, s[i]); ok {
+				p = next
+				i++
+				continue
+			}
+		}
+		if star == -1 {
+			return false
+		}
+		starI++
+		p, i = star+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// globMatchByte matches c against the pattern element starting at p, which is not a star.
+// Returns the position of the next element and whether c matched.
+func globMatchByte(pattern string, p int, c byte) (int, bool) {
+	switch pattern[p] {
+	case '?':
+		return p + 1, true
+	case '[':
+		p++
+		not := p < len(pattern) && pattern[p] == '^'
+		if not {
+			p++
+		}
+		match := false
+		// An unterminated class ends with the pattern.
+		for ; p < len(pattern) && pattern[p] != ']'; p++ {
+			switch {
+			case pattern[p] == '\\' && p+1 < len(pattern):
+				p++
+				match = match || pattern[p] == c
+			case p+2 < len(pattern) && pattern[p+1] == '-':
+				lo, hi := pattern[p], pattern[p+2]
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				match = match || (c >= lo && c <= hi)
+				p += 2
+			default:
+				match = match || pattern[p] == c
+			}
+		}
+		return min(p+1, len(pattern)), match != not
+	case '\\':
+		if p+1 < len(pattern) {
+			p++
+		}
+	}
+	return p + 1, pattern[p] == c
+}
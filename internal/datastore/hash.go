@@ -0,0 +1,295 @@
+package datastore
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+var (
+	// ErrHashNotInteger is returned when incrementing a hash field that does not hold an integer.
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	// ErrHashNotFloat is returned when incrementing a hash field that does not hold a float.
+	ErrHashNotFloat = errors.New("ERR hash value is not a float")
+	// ErrOverflow is returned when an increment would overflow a 64 bit integer.
+	ErrOverflow = errors.New("ERR increment or decrement would overflow")
+	// ErrNaNOrInfinity is returned when a float increment would produce NaN or Infinity.
+	ErrNaNOrInfinity = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// Hash is a map of fields to values stored under a single key.
+type Hash map[string]string
+
+// Len returns the number of fields in the hash.
+func (h Hash) Len() int {
+	return len(h)
+}
+
+// HSet sets the field/value pairs in the hash stored at key, creating it if needed.
+// Returns the number of fields that were added.
+func (d *Datastore) HSet(key string, pairs ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	h, err := d.getOrCreateHash(key)
+	if err != nil {
+		return 0, err
+	}
+	var added int64
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if _, ok := h[pairs[i]]; !ok {
+			added++
+		}
+		h[pairs[i]] = pairs[i+1]
+	}
+	d.notify(EventHash, "hset", key)
+	return added, nil
+}
+
+// HSetNX sets the field in the hash stored at key only if it does not exist yet.
+// Returns true if the field was set.
+func (d *Datastore) HSetNX(key, field, value string) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	h, err := d.getOrCreateHash(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := h[field]; ok {
+		return false, nil
+	}
+	h[field] = value
+	d.notify(EventHash, "hset", key)
+	return true, nil
+}
+
+// HGet returns the value of the field in the hash stored at key.
+func (d *Datastore) HGet(key, field string) (string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return "", err
+	}
+	v, ok := h[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// HMGet returns the values of the fields in the hash stored at key.
+// Missing fields are returned as nil.
+func (d *Datastore) HMGet(key string, fields ...string) ([]*string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*string, len(fields))
+	for i, f := range fields {
+		if v, ok := h[f]; ok {
+			ret[i] = &v
+		}
+	}
+	return ret, nil
+}
+
+// HGetAll returns the fields and values of the hash stored at key as a flat list of pairs.
+func (d *Datastore) HGetAll(key string) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, 2*len(h))
+	for f, v := range h {
+		ret = append(ret, f, v)
+	}
+	return ret, nil
+}
+
+// HKeys returns the fields of the hash stored at key.
+func (d *Datastore) HKeys(key string) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(h))
+	for f := range h {
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// HVals returns the values of the hash stored at key.
+func (d *Datastore) HVals(key string) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(h))
+	for _, v := range h {
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// HDel removes the fields from the hash stored at key.
+// Returns the number of fields that were removed.
+func (d *Datastore) HDel(key string, fields ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	h, err := d.getHash(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, f := range fields {
+		if _, ok := h[f]; ok {
+			delete(h, f)
+			removed++
+		}
+	}
+	if removed > 0 {
+		d.notify(EventHash, "hdel", key)
+	}
+	d.deleteIfEmpty(key, h)
+	return removed, nil
+}
+
+// HExists reports whether the field exists in the hash stored at key.
+func (d *Datastore) HExists(key, field string) (bool, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := h[field]
+	return ok, nil
+}
+
+// HLen returns the number of fields in the hash stored at key.
+func (d *Datastore) HLen(key string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(h)), nil
+}
+
+// HStrLen returns the length of the value of the field in the hash stored at key.
+func (d *Datastore) HStrLen(key, field string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	h, err := d.getHash(key)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(h[field])), nil
+}
+
+// HIncrBy increments the integer value of the field in the hash stored at key by delta.
+func (d *Datastore) HIncrBy(key, field string, delta int64) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	h, err := d.getOrCreateHash(key)
+	if err != nil {
+		return 0, err
+	}
+	var val int64
+	if s, ok := h[field]; ok {
+		val, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+	val, ok := addInt64(val, delta)
+	if !ok {
+		d.discardIfEmpty(key, h)
+		return 0, ErrOverflow
+	}
+	h[field] = strconv.FormatInt(val, 10)
+	d.notify(EventHash, "hincrby", key)
+	return val, nil
+}
+
+// HIncrByFloat increments the float value of the field in the hash stored at key by delta.
+// The sum is computed with long doubles like IncrementByFloat does, and returned formatted
+// the way it is stored.
+func (d *Datastore) HIncrByFloat(key, field, delta string) (string, error) {
+	incr, err := parseLongDouble(delta)
+	if err != nil {
+		return "", err
+	}
+	d.mu.Lock()
+	defer d.unlock()
+	h, err := d.getOrCreateHash(key)
+	if err != nil {
+		return "", err
+	}
+	val := new(big.Float).SetPrec(longDoublePrec)
+	if s, ok := h[field]; ok {
+		if val, err = parseLongDouble(s); err != nil {
+			d.discardIfEmpty(key, h)
+			return "", ErrHashNotFloat
+		}
+	}
+	if val.IsInf() || incr.IsInf() {
+		d.discardIfEmpty(key, h)
+		return "", ErrNaNOrInfinity
+	}
+	val.Add(val, incr)
+	if val.MantExp(nil) > longDoubleMaxExp {
+		d.discardIfEmpty(key, h)
+		return "", ErrNaNOrInfinity
+	}
+	h[field] = formatLongDouble(val)
+	d.notify(EventHash, "hincrbyfloat", key)
+	return h[field], nil
+}
+
+// getHash returns the hash stored at key, nil if the key does not exist or
+// ErrWrongType if the key holds another kind of value. The caller must hold d.mu.
+func (d *Datastore) getHash(key string) (Hash, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	h, ok := e.Value.(Hash)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return h, nil
+}
+
+// getOrCreateHash is like getHash, but stores a new empty hash at key if it does not exist.
+// The caller must hold d.mu for writing.
+func (d *Datastore) getOrCreateHash(key string) (Hash, error) {
+	h, err := d.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = make(Hash)
+		d.store(key, newEntry(h, -1))
+	}
+	return h, nil
+}
+
+// addInt64 returns a+b and whether the sum fits into an int64.
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
@@ -0,0 +1,50 @@
+package datastore
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHGetAll(t *testing.T) {
+	ds := NewDatastore()
+	ds.HSet("key", "f1", "v1", "f2", "v2")
+	got, err := ds.HGetAll("key")
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 items, got %v", got)
+	}
+	for i := 0; i < len(got); i += 2 {
+		if "v"+got[i][1:] != got[i+1] {
+			t.Errorf("Field %s paired with unexpected value %s", got[i], got[i+1])
+		}
+	}
+	keys, _ := ds.HKeys("key")
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"f1", "f2"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestHIncrByOverflow(t *testing.T) {
+	ds := NewDatastore()
+	ds.HSet("key", "f", "9223372036854775807")
+	if _, err := ds.HIncrBy("key", "f", 1); err != ErrOverflow {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	if v, _ := ds.HGet("key", "f"); v != "9223372036854775807" {
+		t.Errorf("Expected value to be unchanged, got %s", v)
+	}
+}
+
+func TestHIncrByFloatDoesNotCreateEmptyHash(t *testing.T) {
+	ds := NewDatastore()
+	if _, err := ds.HIncrByFloat("key", "f", "0"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	ds.HDel("key", "f")
+	if ds.Exists("key") {
+		t.Errorf("Expected empty hash to be removed")
+	}
+}
@@ -0,0 +1,551 @@
+package datastore
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The HyperLogLog layout follows Redis so that the values returned by GET are interchangeable
+// with it: a 16 bytes header ("HYLL", the encoding, three unused bytes and the cached cardinality
+// as a little endian integer whose most significant bit flags it as stale) followed by the registers.
+const (
+	hllP              = 14
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllBits           = 6
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHeaderSize     = 16
+	hllDenseSize      = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllEncodingDense  = 0
+	hllEncodingSparse = 1
+	hllAlphaInf       = 0.721347520444481703680
+	// hllSparseMaxBytes mirrors the hll-sparse-max-bytes setting: sparse values growing past it
+	// are converted to the dense encoding.
+	hllSparseMaxBytes = 3000
+
+	hllSparseValMaxValue = 32
+	hllSparseValMaxLen   = 4
+	hllSparseZeroMaxLen  = 64
+
+	murmurSeed = 0xadc83b19
+)
+
+var (
+	// ErrNotHLL is returned when a key holds a string that is not a HyperLogLog.
+	ErrNotHLL = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	// ErrInvalidHLL is returned when the registers of a HyperLogLog cannot be decoded.
+	ErrInvalidHLL = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// PFAdd adds the elements to the HyperLogLog stored at key, creating it if needed.
+// Returns true if the key was created or at least one register was altered.
+func (d *Datastore) PFAdd(key string, elements ...string) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	e, hll, err := d.getHLL(key)
+	if err != nil {
+		return false, err
+	}
+	updated := false
+	if e == nil {
+		hll = newHLL()
+		e = newEntry(hll, -1)
+		d.store(key, e)
+		updated = true
+	}
+	for _, element := range elements {
+		var changed bool
+		if hll, changed, err = hllAdd(hll, element); err != nil {
+			return false, err
+		}
+		updated = updated || changed
+	}
+	if updated {
+		hllInvalidateCache(hll)
+	}
+	e.Value = hll
+	if updated {
+		d.notify(EventString, "pfadd", key)
+	}
+	return updated, nil
+}
+
+// PFCount returns the approximated cardinality of the HyperLogLog stored at key. With multiple
+// keys the cardinality of their union is returned, merging them without altering any of them.
+func (d *Datastore) PFCount(keys ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	if len(keys) == 1 {
+		_, hll, err := d.getHLL(keys[0])
+		if err != nil || hll == nil {
+			return 0, err
+		}
+		card := binary.LittleEndian.Uint64(hll[8:hllHeaderSize])
+		if card&(1<<63) == 0 {
+			return int64(card), nil
+		}
+		histogram, err := hllHistogram(hll)
+		if err != nil {
+			return 0, err
+		}
+		count := hllEstimate(histogram)
+		binary.LittleEndian.PutUint64(hll[8:hllHeaderSize], count)
+		return int64(count), nil
+	}
+	registers := make([]byte, hllRegisters)
+	for _, key := range keys {
+		_, hll, err := d.getHLL(key)
+		if err != nil {
+			return 0, err
+		}
+		if hll == nil {
+			continue
+		}
+		if err := hllMerge(registers, hll); err != nil {
+			return 0, err
+		}
+	}
+	var histogram [64]int
+	for _, r := range registers {
+		histogram[r]++
+	}
+	return int64(hllEstimate(histogram)), nil
+}
+
+// PFMerge stores at dest the union of the HyperLogLogs stored at dest and keys.
+// The result is dense if any of the merged values is.
+func (d *Datastore) PFMerge(dest string, keys ...string) error {
+	d.mu.Lock()
+	defer d.unlock()
+	registers := make([]byte, hllRegisters)
+	dense := false
+	for _, key := range append([]string{dest}, keys...) {
+		_, hll, err := d.getHLL(key)
+		if err != nil {
+			return err
+		}
+		if hll == nil {
+			continue
+		}
+		dense = dense || hll[4] == hllEncodingDense
+		if err := hllMerge(registers, hll); err != nil {
+			return err
+		}
+	}
+	e, hll, _ := d.getHLL(dest)
+	if e == nil {
+		hll = newHLL()
+		e = newEntry(hll, -1)
+		d.store(dest, e)
+	}
+	var err error
+	if dense && hll[4] == hllEncodingSparse {
+		if hll, err = hllSparseToDense(hll); err != nil {
+			return err
+		}
+	}
+	for i, r := range registers {
+		if r == 0 {
+			continue
+		}
+		if hll[4] == hllEncodingDense {
+			hllDenseSet(hll[hllHeaderSize:], i, r)
+		} else if hll, _, err = hllSparseSet(hll, i, r); err != nil {
+			return err
+		}
+	}
+	hllInvalidateCache(hll)
+	e.Value = hll
+	d.notify(EventString, "pfadd", dest)
+	return nil
+
+}
+
+// getHLL returns the entry stored at key and its HyperLogLog value, both nil if the key does not
+// exist. The entry is switched to a byte slice value so that the registers can be updated in place.
+// The caller must hold d.mu for writing.
+func (d *Datastore) getHLL(key string) (*Entry, []byte, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil, nil
+	}
+	var hll []byte
+	switch v := e.Value.(type) {
+	case []byte:
+		hll = v
+	case string:
+		hll = []byte(v)
+	case int64:
+		return nil, nil, ErrNotHLL
+	default:
+		return nil, nil, ErrWrongType
+	}
+	if len(hll) < hllHeaderSize || string(hll[:4]) != "HYLL" || hll[4] > hllEncodingSparse ||
+		(hll[4] == hllEncodingDense && len(hll) != hllDenseSize) {
+		return nil, nil, ErrNotHLL
+	}
+	e.Value = hll
+	return e, hll, nil
+}
+
+// newHLL returns an empty sparse HyperLogLog.
+func newHLL() []byte {
+	hll := make([]byte, hllHeaderSize, hllHeaderSize+2)
+	copy(hll, "HYLL")
+	hll[4] = hllEncodingSparse
+	return hllAppendXZero(hll, hllRegisters)
+}
+
+func hllInvalidateCache(hll []byte) {
+	hll[15] |= 1 << 7
+}
+
+// murmurHash64A is the hash function used by Redis to pick the register of an element.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPatLen returns the register of the element and the length of the run of zeroes,
+// plus one, found in the remaining bits of its hash.
+func hllPatLen(element string) (int, byte) {
+	hash := murmurHash64A([]byte(element), murmurSeed)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ
+	count := byte(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// hllAdd adds the element to the HyperLogLog, returning the possibly reallocated value
+// and whether a register was altered.
+func hllAdd(hll []byte, element string) ([]byte, bool, error) {
+	index, count := hllPatLen(element)
+	if hll[4] == hllEncodingDense {
+		registers := hll[hllHeaderSize:]
+		if hllDenseGet(registers, index) >= count {
+			return hll, false, nil
+		}
+		hllDenseSet(registers, index, count)
+		return hll, true, nil
+	}
+	return hllSparseSet(hll, index, count)
+}
+
+func hllDenseGet(registers []byte, index int) byte {
+	b := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	v := registers[b] >> fb
+	if b+1 < len(registers) {
+		v |= registers[b+1] << (8 - fb)
+	}
+	return v & hllRegisterMax
+}
+
+func hllDenseSet(registers []byte, index int, value byte) {
+	b := index * hllBits / 8
+	fb := uint(index * hllBits & 7)
+	registers[b] &^= hllRegisterMax << fb
+	registers[b] |= value << fb
+	if b+1 < len(registers) {
+		registers[b+1] &^= hllRegisterMax >> (8 - fb)
+		registers[b+1] |= value >> (8 - fb)
+	}
+}
+
+// Sparse opcodes, as in Redis:
+//
+//	ZERO:  00xxxxxx          a run of xxxxxx+1 registers set to zero
+//	XZERO: 01xxxxxx yyyyyyyy a run of xxxxxxyyyyyyyy+1 registers set to zero
+//	VAL:   1vvvvvxx          a run of xx+1 registers set to vvvvv+1
+func hllIsZero(op byte) bool  { return op&0xc0 == 0 }
+func hllIsXZero(op byte) bool { return op&0xc0 == 0x40 }
+func hllIsVal(op byte) bool   { return op&0x80 != 0 }
+
+func hllZeroLen(op byte) int               { return int(op&0x3f) + 1 }
+func hllXZeroLen(op, next byte) int        { return int(op&0x3f)<<8 | int(next) + 1 }
+func hllValValue(op byte) byte             { return (op>>2)&0x1f + 1 }
+func hllValLen(op byte) int                { return int(op&0x3) + 1 }
+func hllValOp(value byte, length int) byte { return (value-1)<<2 | byte(length-1) | 0x80 }
+func hllAppendXZero(b []byte, length int) []byte {
+	return append(b, byte((length-1)>>8)|0x40, byte((length-1)&0xff))
+}
+
+// hllAppendZeroes appends the shortest opcode encoding a run of zeroes.
+func hllAppendZeroes(b []byte, length int) []byte {
+	if length > hllSparseZeroMaxLen {
+		return hllAppendXZero(b, length)
+	}
+	return append(b, byte(length-1))
+}
+
+// hllSparseRuns calls fn with the value and length of every run of the sparse registers.
+func hllSparseRuns(sparse []byte, fn func(value byte, length int)) error {
+	covered := 0
+	for p := 0; p < len(sparse); {
+		switch op := sparse[p]; {
+		case hllIsZero(op):
+			fn(0, hllZeroLen(op))
+			covered += hllZeroLen(op)
+			p++
+		case hllIsXZero(op):
+			if p+1 >= len(sparse) {
+				return ErrInvalidHLL
+			}
+			fn(0, hllXZeroLen(op, sparse[p+1]))
+			covered += hllXZeroLen(op, sparse[p+1])
+			p += 2
+		default:
+			fn(hllValValue(op), hllValLen(op))
+			covered += hllValLen(op)
+			p++
+		}
+		if covered > hllRegisters {
+			return ErrInvalidHLL
+		}
+	}
+	if covered != hllRegisters {
+		return ErrInvalidHLL
+	}
+	return nil
+}
+
+// hllSparseToDense converts a sparse HyperLogLog to the dense encoding, keeping its header.
+func hllSparseToDense(hll []byte) ([]byte, error) {
+	dense := make([]byte, hllDenseSize)
+	copy(dense, hll[:hllHeaderSize])
+	dense[4] = hllEncodingDense
+	index := 0
+	err := hllSparseRuns(hll[hllHeaderSize:], func(value byte, length int) {
+		for i := 0; i < length && value > 0; i++ {
+			hllDenseSet(dense[hllHeaderSize:], index+i, value)
+		}
+		index += length
+	})
+	return dense, err
+}
+
+// hllSparseSet sets the register to count if it is greater than its current value, splitting
+// the opcode covering it. The value is converted to the dense encoding when count cannot be
+// represented or the value would grow past hllSparseMaxBytes.
+func hllSparseSet(hll []byte, index int, count byte) ([]byte, bool, error) {
+	if count > hllSparseValMaxValue {
+		return hllPromoteAndSet(hll, index, count)
+	}
+	// Locate the opcode covering the register, along with the one before it.
+	p, prev, first, span := hllHeaderSize, -1, 0, 0
+	for p < len(hll) {
+		oplen := 1
+		switch op := hll[p]; {
+		case hllIsZero(op):
+			span = hllZeroLen(op)
+		case hllIsVal(op):
+			span = hllValLen(op)
+		default:
+			if p+1 >= len(hll) {
+				return hll, false, ErrInvalidHLL
+			}
+			span = hllXZeroLen(op, hll[p+1])
+			oplen = 2
+		}
+		if index <= first+span-1 {
+			break
+		}
+		prev = p
+		p += oplen
+		first += span
+	}
+	if span == 0 || p >= len(hll) {
+		return hll, false, ErrInvalidHLL
+	}
+	op := hll[p]
+	isXZero, isVal := hllIsXZero(op), hllIsVal(op)
+	if isVal {
+		if hllValValue(op) >= count {
+			return hll, false, nil
+		}
+	}
+	if span == 1 && !isXZero {
+		hll[p] = hllValOp(count, 1)
+		return hllSparseMerge(hll, prev), true, nil
+	}
+	// Replace the opcode with up to three opcodes: the registers before the updated one,
+	// the updated register and the registers after it.
+	seq := make([]byte, 0, 5)
+	last := first + span - 1
+	if isVal {
+		value := hllValValue(op)
+		if index != first {
+			seq = append(seq, hllValOp(value, index-first))
+		}
+		seq = append(seq, hllValOp(count, 1))
+		if index != last {
+			seq = append(seq, hllValOp(value, last-index))
+		}
+	} else {
+		if index != first {
+			seq = hllAppendZeroes(seq, index-first)
+		}
+		seq = append(seq, hllValOp(count, 1))
+		if index != last {
+			seq = hllAppendZeroes(seq, last-index)
+		}
+	}
+	oldLen := 1
+	if isXZero {
+		oldLen = 2
+	}
+	if len(seq) > oldLen && len(hll)+len(seq)-oldLen > hllSparseMaxBytes {
+		return hllPromoteAndSet(hll, index, count)
+	}
+	updated := make([]byte, 0, len(hll)+len(seq)-oldLen)
+	updated = append(updated, hll[:p]...)
+	updated = append(updated, seq...)
+	updated = append(updated, hll[p+oldLen:]...)
+	return hllSparseMerge(updated, prev), true, nil
+}
+
+// hllSparseMerge merges adjacent VAL opcodes holding the same value, scanning up to
+// five opcodes starting from the one at from, or from the first one when it is negative.
+func hllSparseMerge(hll []byte, from int) []byte {
+	p := from
+	if p < 0 {
+		p = hllHeaderSize
+	}
+	for scan := 5; p < len(hll) && scan > 0; scan-- {
+		op := hll[p]
+		switch {
+		case hllIsXZero(op):
+			p += 2
+			continue
+		case hllIsZero(op):
+			p++
+			continue
+		}
+		if p+1 < len(hll) && hllIsVal(hll[p+1]) && hllValValue(op) == hllValValue(hll[p+1]) {
+			if length := hllValLen(op) + hllValLen(hll[p+1]); length <= hllSparseValMaxLen {
+				hll[p+1] = hllValOp(hllValValue(op), length)
+				hll = append(hll[:p], hll[p+1:]...)
+				// Try to merge the merged opcode with the one on its right.
+				continue
+			}
+		}
+		p++
+	}
+	hllInvalidateCache(hll)
+	return hll
+}
+
+func hllPromoteAndSet(hll []byte, index int, count byte) ([]byte, bool, error) {
+	dense, err := hllSparseToDense(hll)
+	if err != nil {
+		return hll, false, err
+	}
+	registers := dense[hllHeaderSize:]
+	if hllDenseGet(registers, index) >= count {
+		return dense, false, nil
+	}
+	hllDenseSet(registers, index, count)
+	return dense, true, nil
+}
+
+// hllMerge sets every register to the maximum between its value and the one in the HyperLogLog.
+func hllMerge(registers []byte, hll []byte) error {
+	if hll[4] == hllEncodingDense {
+		for i := range registers {
+			registers[i] = max(registers[i], hllDenseGet(hll[hllHeaderSize:], i))
+		}
+		return nil
+	}
+	index := 0
+	return hllSparseRuns(hll[hllHeaderSize:], func(value byte, length int) {
+		for i := index; i < index+length && i < hllRegisters; i++ {
+			registers[i] = max(registers[i], value)
+		}
+		index += length
+	})
+}
+
+// hllHistogram counts how many registers hold each value.
+func hllHistogram(hll []byte) ([64]int, error) {
+	var histogram [64]int
+	if hll[4] == hllEncodingDense {
+		for i := 0; i < hllRegisters; i++ {
+			histogram[hllDenseGet(hll[hllHeaderSize:], i)]++
+		}
+		return histogram, nil
+	}
+	err := hllSparseRuns(hll[hllHeaderSize:], func(value byte, length int) {
+		histogram[value] += length
+	})
+	return histogram, err
+}
+
+// hllEstimate computes the cardinality from the register histogram with the improved
+// estimator by Otmar Ertl, which is the one used by Redis.
+func hllEstimate(histogram [64]int) uint64 {
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package datastore
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestPFAddCount(t *testing.T) {
+	ds := NewDatastore()
+	if updated, _ := ds.PFAdd("hll"); !updated {
+		t.Errorf("Expected the creation of the key to be reported as an update")
+	}
+	empty, _ := ds.Get("hll")
+	if expected := "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff"; empty != expected {
+		t.Errorf("Expected an empty sparse HyperLogLog %q, got %q", expected, empty)
+	}
+	if updated, _ := ds.PFAdd("hll", "a", "b", "c"); !updated {
+		t.Errorf("Expected new elements to alter the registers")
+	}
+	if updated, _ := ds.PFAdd("hll", "a", "b"); updated {
+		t.Errorf("Expected existing elements not to alter the registers")
+	}
+	if n, _ := ds.PFCount("hll"); n != 3 {
+		t.Errorf("Expected a cardinality of 3, got %d", n)
+	}
+	if n, _ := ds.PFCount("missing"); n != 0 {
+		t.Errorf("Expected a cardinality of 0, got %d", n)
+	}
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	ds := NewDatastore()
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			key := "hll" + strconv.Itoa(n)
+			for i := 0; i < n; i++ {
+				ds.PFAdd(key, strconv.Itoa(i))
+			}
+			count, err := ds.PFCount(key)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if math.Abs(float64(count)-float64(n))/float64(n) > 0.05 {
+				t.Errorf("Expected a cardinality close to %d, got %d", n, count)
+			}
+		})
+	}
+}
+
+func TestHLLEncodings(t *testing.T) {
+	ds := NewDatastore()
+	var dense bool
+	for i := 0; i < 5000; i++ {
+		ds.PFAdd("hll", "element:"+strconv.Itoa(i))
+		_, hll, _ := ds.getHLL("hll")
+		if hll[4] == hllEncodingDense {
+			dense = true
+			break
+		}
+		if len(hll) > hllSparseMaxBytes {
+			t.Fatalf("Expected sparse values to stay within %d bytes, got %d", hllSparseMaxBytes, len(hll))
+		}
+	}
+	if !dense {
+		t.Fatalf("Expected the value to be promoted to the dense encoding")
+	}
+
+	// The sparse and dense encodings of the same elements must hold the same registers.
+	for i := 0; i < 1000; i++ {
+		ds.PFAdd("sparse", strconv.Itoa(i))
+	}
+	_, sparse, _ := ds.getHLL("sparse")
+	if sparse[4] != hllEncodingSparse {
+		t.Fatalf("Expected a sparse value")
+	}
+	converted, err := hllSparseToDense(sparse)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	ds.data["dense"] = newEntry(converted, -1)
+	for i := 0; i < 1000; i++ {
+		if updated, _ := ds.PFAdd("dense", strconv.Itoa(i)); updated {
+			t.Fatalf("Expected element %d to be already counted", i)
+		}
+	}
+	sparseCount, _ := ds.PFCount("sparse")
+	denseCount, _ := ds.PFCount("dense")
+	if sparseCount != denseCount {
+		t.Errorf("Expected the same cardinality, got %d and %d", sparseCount, denseCount)
+	}
+}
+
+func TestPFMerge(t *testing.T) {
+	ds := NewDatastore()
+	for i := 0; i < 1000; i++ {
+		ds.PFAdd("a", strconv.Itoa(i))
+		ds.PFAdd("b", strconv.Itoa(i+500))
+	}
+	union, _ := ds.PFCount("a", "b", "missing")
+	if err := ds.PFMerge("dest", "a", "b"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if merged, _ := ds.PFCount("dest"); merged != union {
+		t.Errorf("Expected the merged cardinality %d to match the union %d", merged, union)
+	}
+	if math.Abs(float64(union)-1500)/1500 > 0.05 {
+		t.Errorf("Expected a cardinality close to 1500, got %d", union)
+	}
+}
+
+func TestHLLInvalidValues(t *testing.T) {
+	ds := NewDatastore()
+	ds.Set("str", "foo")
+	ds.Set("int", "10")
+	ds.Set("corrupt", "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xfe")
+	ds.LPush("list", "a")
+	tests := map[string]struct {
+		key string
+		err error
+	}{
+		"Plain string": {key: "str", err: ErrNotHLL},
+		"Integer":      {key: "int", err: ErrNotHLL},
+		"Corrupted":    {key: "corrupt", err: ErrInvalidHLL},
+		"Wrong type":   {key: "list", err: ErrWrongType},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ds.PFCount(test.key); err != test.err {
+				t.Errorf("Expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package datastore
+
+import (
+	"errors"
+	"hash/maphash"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// defaultScanCount is the number of keys visited by a SCAN call without COUNT, like in Redis.
+	defaultScanCount = 10
+	// lazyFreeThreshold is the number of elements above which Unlink reclaims a value in the
+	// background, like the LAZYFREE_THRESHOLD of Redis.
+	lazyFreeThreshold = 64
+)
+
+// ErrSameObject is returned when copying or moving a key onto itself.
+var ErrSameObject = errors.New("ERR source and destination objects are the same")
+
+// keyTypes are the names of the types of values, as reported by TYPE.
+var keyTypes = []string{"string", "list", "set", "zset", "hash", "stream"}
+
+// ScanOptions filters the keys returned by Scan.
+type ScanOptions struct {
+	// Match is a glob-style pattern the keys must match, all keys matching when empty.
+	Match string
+	// Count is the number of keys to visit, defaulting to 10. Filtered keys count as visited,
+	// so fewer keys may be returned.
+	Count int64
+	// Type is the type the values must have, any type matching when empty.
+	Type string
+}
+
+// ValidType reports whether name is the name of a type of values, as reported by Type.
+func ValidType(name string) bool {
+	return slices.Contains(keyTypes, name)
+}
+
+// Keys returns the keys matching the glob-style pattern, in no particular order.
+func (d *Datastore) Keys(pattern string) []string {
+	d.mu.RLock()
+	defer d.runlock()
+	keys := make([]string, 0)
+	for k := range d.data {
+		if GlobMatch(pattern, k) && d.peek(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Scan returns a batch of keys starting from the cursor, and the cursor to pass to the next
+// call, 0 once the iteration is complete. An iteration starts with the cursor 0.
+//
+// Keys are visited by increasing hash, the cursor being the hash to resume from. As the hash
+// of a key does not depend on the size of the map, every key present during the whole
+// iteration is returned at least once however the map grows or shrinks, while keys added or
+// removed meanwhile may or may not be returned. The keys being indexed by hash, a call costs
+// O(log n) plus the number of keys visited.
+func (d *Datastore) Scan(cursor uint64, opts ScanOptions) (uint64, []string) {
+	d.mu.RLock()
+	defer d.runlock()
+	count := opts.Count
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	keys := make([]string, 0, min(count, maxPrealloc))
+	visited, last := int64(0), -1.0
+	for x := d.index.firstInRange(ScoreRange{Min: float64(cursor), Max: math.Inf(1)}); x != nil; x = x.level[0].forward {
+		// Keys sharing the hash of the last visited one are visited with it, so that the
+		// cursor can resume past their hash.
+		if visited >= count && x.score != last {
+			return uint64(x.score), keys
+		}
+		visited++
+		last = x.score
+		e := d.peek(x.member)
+		if e == nil || (opts.Match != "" && !GlobMatch(opts.Match, x.member)) ||
+			(opts.Type != "" && typeName(e) != opts.Type) {
+			continue
+		}
+		keys = append(keys, x.member)
+	}
+	return 0, keys
+}
+
+// Type returns the type of the value stored at key, or "none" if it does not exist.
+func (d *Datastore) Type(key string) string {
+	d.mu.RLock()
+	defer d.runlock()
+	e := d.peek(key)
+	if e == nil {
+		return "none"
+	}
+	return typeName(e)
+}
+
+// IdleTime returns for how long the key has not been read or written, according to the clock of
+// the datastore, and false if it does not exist. It does not count as an access.
+func (d *Datastore) IdleTime(key string) (time.Duration, bool) {
+	d.mu.RLock()
+	defer d.runlock()
+	e := d.peek(key)
+	if e == nil {
+		return 0, false
+	}
+	return time.Duration(d.nowMillis()-e.access.Load()) * time.Millisecond, true
+}
+
+// RandomKey returns a random key, and false if the datastore is empty.
+func (d *Datastore) RandomKey() (string, bool) {
+	d.mu.RLock()
+	defer d.runlock()
+	// Map iteration starts at a random position.
+	for k := range d.data {
+		if d.peek(k) != nil {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// DBSize returns the number of keys, not counting the expired ones.
+func (d *Datastore) DBSize() int64 {
+	d.mu.RLock()
+	defer d.runlock()
+	n := int64(0)
+	for k := range d.data {
+		if d.peek(k) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Rename renames the key to newKey, overwriting it, with its value and expiration.
+// Returns ErrNoSuchKey if the key does not exist.
+func (d *Datastore) Rename(key, newKey string) error {
+	d.mu.Lock()
+	defer d.unlock()
+	e := d.lookup(key)
+	if e == nil {
+		return ErrNoSuchKey
+	}
+	d.remove(key)
+	d.store(newKey, e)
+	d.notify(EventGeneric, "rename_from", key)
+	d.notify(EventGeneric, "rename_to", newKey)
+	return nil
+}
+
+// RenameNX renames the key to newKey only if newKey does not exist. Returns whether the key was
+// renamed, and ErrNoSuchKey if it does not exist.
+func (d *Datastore) RenameNX(key, newKey string) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	e := d.lookup(key)
+	if e == nil {
+		return false, ErrNoSuchKey
+	}
+	if d.lookup(newKey) != nil {
+		return false, nil
+	}
+	d.remove(key)
+	d.store(newKey, e)
+	d.notify(EventGeneric, "rename_from", key)
+	d.notify(EventGeneric, "rename_to", newKey)
+	return true, nil
+}
+
+// CopyTo copies the value and expiration of the key to dstKey in dst, which may be d. An existing
+// dstKey is only overwritten when replace is set. Returns whether the key was copied.
+func (d *Datastore) CopyTo(dst *Datastore, key, dstKey string, replace bool) (bool, error) {
+	if d == dst && key == dstKey {
+		return false, ErrSameObject
+	}
+	defer d.lockPair(dst)()
+	e := d.lookup(key)
+	if e == nil || (!replace && dst.lookup(dstKey) != nil) {
+		return false, nil
+	}
+	dst.store(dstKey, &Entry{Value: cloneValue(e.Value), Expiry: e.Expiry})
+	dst.notify(EventGeneric, "copy_to", dstKey)
+	return true, nil
+}
+
+// MoveTo moves the key with its expiration to dst, unless it already exists there.
+// Returns whether the key was moved.
+func (d *Datastore) MoveTo(dst *Datastore, key string) (bool, error) {
+	if d == dst {
+		return false, ErrSameObject
+	}
+	defer d.lockPair(dst)()
+	e := d.lookup(key)
+	if e == nil || dst.lookup(key) != nil {
+		return false, nil
+	}
+	d.remove(key)
+	dst.store(key, e)
+	d.notify(EventGeneric, "move_from", key)
+	dst.notify(EventGeneric, "move_to", key)
+	return true, nil
+}
+
+// Touch returns the number of existing keys among keys.
+func (d *Datastore) Touch(keys ...string) int64 {
+	d.mu.RLock()
+	defer d.runlock()
+	n := int64(0)
+	for _, k := range keys {
+		if d.lookup(k) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Unlink deletes the keys like DEL, but only unlinks their values under d.mu: large values are
+// reclaimed in the background. Returns the number of deleted keys.
+func (d *Datastore) Unlink(keys ...string) int64 {
+	d.mu.Lock()
+	n := int64(0)
+	var reclaim []interface{}
+	for _, k := range keys {
+		e := d.lookup(k)
+		if e == nil {
+			continue
+		}
+		d.remove(k)
+		d.notify(EventGeneric, "del", k)
+		n++
+
+		if valueLen(e.Value) > lazyFreeThreshold {
+			reclaim = append(reclaim, e.Value)
+		}
+	}
+	d.unlock()
+	if len(reclaim) > 0 {
+		go func() {
+			for _, v := range reclaim {
+				release(v)
+			}
+		}()
+	}
+	return n
+}
+
+// Flush deletes all the keys. When async is set, the values are reclaimed in the background.
+func (d *Datastore) Flush(async bool) {
+	d.mu.Lock()
+	data := d.data
+	d.data = make(map[string]*Entry)
+	d.index = newSkiplist()
+	d.signalWatched(data)
+	d.unlock()
+	if async {
+		go func() {
+			for _, e := range data {
+				release(e.Value)
+			}
+		}()
+	}
+}
+
+// SwapWith swaps the keys of the datastore with the ones of other, so that the clients of
+// each one see the keys of the other. The clients blocked on the lists they now see are ready
+// to be served.
+func (d *Datastore) SwapWith(other *Datastore) {
+	defer d.lockPair(other)()
+	d.signalWatched(d.data, other.data)
+	other.signalWatched(d.data, other.data)
+	d.data, other.data = other.data, d.data
+	d.index, other.index = other.index, d.index
+	d.signalAllReady()
+	other.signalAllReady()
+}
+
+// lockPair locks d and other for writing, in the order of their ids so that operations
+// locking the same pair cannot deadlock. Returns the function releasing them.
+func (d *Datastore) lockPair(other *Datastore) func() {
+	if d == other {
+		d.mu.Lock()
+		return d.unlock
+	}
+	first, second := d, other
+	if first.id > second.id {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	second.mu.Lock()
+	return func() {
+		second.unlock()
+		first.unlock()
+	}
+}
+
+// scanHash returns the hash of the key ordering SCAN, truncated to 53 bits for the scores of
+// the index to represent it exactly.
+func scanHash(key string) float64 {
+	return float64(maphash.String(scanSeed, key) >> 11)
+}
+
+// cloneValue returns a deep copy of a value, sharing only what is never modified in place.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return slices.Clone(v)
+	case *List:
+		return v.clone()
+	case Set:
+		return maps.Clone(v)
+	case *SortedSet:
+		return v.clone()
+	case Hash:
+		return maps.Clone(v)
+	case *Stream:
+		return v.clone()
+	}
+	// Integers and strings are immutable.
+	return v
+}
+
+// valueLen returns the number of elements of a value, 1 for strings.
+func valueLen(v interface{}) int {
+	if c, ok := v.(interface{ Len() int }); ok {
+		return c.Len()
+	}
+	return 1
+}
+
+// release drops the elements of a value that is not referenced anymore, which is the part of
+// freeing it that takes time proportional to its size.
+func release(v interface{}) {
+	switch v := v.(type) {
+	case *List:
+		v.reset(nil)
+	case Set:
+		clear(v)
+	case *SortedSet:
+		clear(v.dict)
+		v.zsl = newSkiplist()
+	case Hash:
+		clear(v)
+	case *Stream:
+		v.entries = nil
+		v.groups = nil
+	}
+}
+
+// typeName returns the name of the type of the value of the entry.
+func typeName(e *Entry) string {
+	switch e.Value.(type) {
+	case *List:
+		return "list"
+	case Set:
+		return "set"
+	case *SortedSet:
+		return "zset"
+	case Hash:
+		return "hash"
+	case *Stream:
+		return "stream"
+	}
+	return "string"
+}
@@ -0,0 +1,323 @@
+package datastore
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"[\\]]", "]", true},
+		{"*a*b*c*", "xxaxxbxxcxx", true},
+		{"*a*b*c*", "xxcxxbxxaxx", false},
+		{"a[bc", "ab", true},
+		{"a\\", "a\\", true},
+		{"**x", "yx", true},
+	}
+	for _, test := range tests {
+		if got := GlobMatch(test.pattern, test.s); got != test.expected {
+			t.Errorf("GlobMatch(%q, %q): expected %v, got %v", test.pattern, test.s, test.expected, got)
+		}
+	}
+}
+
+func TestKeysIgnoresExpiredKeys(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.Set("one", "1")
+	ds.Set("two", "2")
+	ds.SetWithExpiry("three", "3", 100)
+	ds.RPush("list", "a")
+	clock.Advance(100 * time.Millisecond)
+	keys := ds.Keys("*")
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"list", "one", "two"}) {
+		t.Errorf("Expected [list one two], got %v", keys)
+	}
+	if keys := ds.Keys("t*"); !slices.Equal(keys, []string{"two"}) {
+		t.Errorf("Expected [two], got %v", keys)
+	}
+	if n := ds.DBSize(); n != 3 {
+		t.Errorf("Expected 3 keys, got %d", n)
+	}
+	if typ := ds.Type("three"); typ != "none" {
+		t.Errorf("Expected none, got %s", typ)
+	}
+	if typ := ds.Type("list"); typ != "list" {
+		t.Errorf("Expected list, got %s", typ)
+	}
+}
+
+func TestRandomKey(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	if _, ok := ds.RandomKey(); ok {
+		t.Errorf("Expected no key in an empty datastore")
+	}
+	ds.SetWithExpiry("expired", "v", 100)
+	ds.Set("key", "v")
+	clock.Advance(100 * time.Millisecond)
+	for range 10 {
+		if key, ok := ds.RandomKey(); !ok || key != "key" {
+			t.Errorf("Expected key, got %q", key)
+		}
+	}
+}
+
+// scanAll runs a full SCAN iteration, calling between after each call.
+func scanAll(ds *Datastore, opts ScanOptions, between func()) []string {
+	var keys []string
+	cursor := uint64(0)
+	for {
+		var batch []string
+		cursor, batch = ds.Scan(cursor, opts)
+		keys = append(keys, batch...)
+		if cursor == 0 {
+			return keys
+		}
+		between()
+	}
+}
+
+func TestScan(t *testing.T) {
+	ds := NewDatastore()
+	for i := range 100 {
+		ds.Set(fmt.Sprintf("key%d", i), "v")
+	}
+	ds.SAdd("set", "a")
+	keys := scanAll(ds, ScanOptions{Count: 7}, func() {})
+	slices.Sort(keys)
+	if len(keys) != 101 || len(slices.Compact(keys)) != 101 {
+		t.Errorf("Expected the 101 keys to be returned once, got %d", len(keys))
+	}
+	if keys := scanAll(ds, ScanOptions{Type: "set"}, func() {}); !slices.Equal(keys, []string{"set"}) {
+		t.Errorf("Expected [set], got %v", keys)
+	}
+	keys = scanAll(ds, ScanOptions{Match: "key1?", Count: 1000}, func() {})
+	if len(keys) != 10 {
+		t.Errorf("Expected 10 keys matching key1?, got %v", keys)
+	}
+}
+
+func TestScanIndexFollowsKeys(t *testing.T) {
+	ds, other := NewDatastore(), NewDatastore()
+	for i := range 20 {
+		ds.Set(fmt.Sprintf("key%d", i), "v")
+	}
+	ds.Delete("key0")
+	ds.Rename("key1", "renamed")
+	ds.MoveTo(other, "key2")
+	ds.RPush("list", "a")
+	ds.LPop("list", 1)
+	if _, keys := ds.Scan(0, ScanOptions{Count: 7}); len(keys) != 7 {
+		t.Errorf("Expected a call to visit 7 keys, got %v", keys)
+	}
+	expected := ds.Keys("*")
+	slices.Sort(expected)
+	keys := scanAll(ds, ScanOptions{Count: 3}, func() {})
+	slices.Sort(keys)
+	if !slices.Equal(keys, expected) || ds.index.length != len(ds.data) {
+		t.Errorf("Expected the keys %v to be scanned, got %v", expected, keys)
+	}
+	ds.SwapWith(other)
+	if keys := scanAll(ds, ScanOptions{}, func() {}); !slices.Equal(keys, []string{"key2"}) {
+		t.Errorf("Expected the swapped keys to be scanned, got %v", keys)
+	}
+	ds.Flush(false)
+	if keys := scanAll(ds, ScanOptions{}, func() {}); len(keys) != 0 || ds.index.length != 0 {
+		t.Errorf("Expected no key to be scanned after a flush, got %v", keys)
+	}
+}
+
+func TestScanCoversKeysWhileGrowing(t *testing.T) {
+	ds := NewDatastore()
+	for i := range 100 {
+		ds.Set(fmt.Sprintf("key%d", i), "v")
+	}
+	added := 0
+	keys := scanAll(ds, ScanOptions{Count: 5}, func() {
+		if added >= 1000 {
+			return
+		}
+		// Grow the map well past its initial size during the iteration.
+		for range 50 {
+			ds.Set(fmt.Sprintf("new%d", added), "v")
+			added++
+		}
+	})
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		seen[k] = true
+	}
+	for i := range 100 {
+		if !seen[fmt.Sprintf("key%d", i)] {
+			t.Errorf("Expected key%d to be returned", i)
+		}
+	}
+}
+
+func TestRenameKeepsExpiry(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.RPush("list", "a", "b")
+	ds.Expire("list", Expiry{Mode: ExpiryAfter, Millis: 1000}, ExpireOptions{})
+	ds.Set("other", "v")
+	if err := ds.Rename("list", "other"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if typ := ds.Type("other"); typ != "list" {
+		t.Errorf("Expected the renamed key to hold a list, got %s", typ)
+	}
+	if ttl := ds.TTL("other"); ttl != 1000 {
+		t.Errorf("Expected the expiration to be kept, got %d", ttl)
+	}
+	if err := ds.Rename("list", "other"); err != ErrNoSuchKey {
+		t.Errorf("Expected %v, got %v", ErrNoSuchKey, err)
+	}
+	ds.Set("key", "v")
+	if ok, _ := ds.RenameNX("key", "other"); ok {
+		t.Errorf("Expected RenameNX not to overwrite an existing key")
+	}
+	if ok, _ := ds.RenameNX("key", "new"); !ok || ds.Exists("key") {
+		t.Errorf("Expected key to be renamed to new")
+	}
+}
+
+func TestCopyToIsDeep(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("list", "a")
+	ds.ZAdd("zset", ZAddOptions{}, []ScoredMember{{"a", 1}, {"b", 2}})
+	ds.XAdd("stream", XAddOptions{ID: StreamID{1, 1}}, []string{"f", "v"})
+	ds.XGroupCreate("stream", "group", StreamID{}, false, false, -1)
+	ds.XReadGroup("group", "consumer", []string{"stream"}, []*StreamID{nil}, 10, false)
+	for _, key := range []string{"list", "zset", "stream"} {
+		if ok, err := ds.CopyTo(ds, key, key+"-copy", false); !ok || err != nil {
+			t.Errorf("Expected %s to be copied, got %v", key, err)
+		}
+	}
+	ds.RPush("list-copy", "b")
+	ds.ZAdd("zset-copy", ZAddOptions{}, []ScoredMember{{"c", 3}})
+	ds.XAck("stream-copy", "group", StreamID{1, 1})
+	if n, _ := ds.LLen("list"); n != 1 {
+		t.Errorf("Expected the original list to be unchanged, got length %d", n)
+	}
+	if n, _ := ds.ZCard("zset"); n != 2 {
+		t.Errorf("Expected the original sorted set to be unchanged, got %d members", n)
+	}
+	if n, _ := ds.ZCard("zset-copy"); n != 3 {
+		t.Errorf("Expected the copy to have 3 members, got %d", n)
+	}
+	if summary, _ := ds.XPendingSummary("stream", "group"); summary.Count != 1 {
+		t.Errorf("Expected the original group to keep its pending entry, got %d", summary.Count)
+	}
+	if summary, _ := ds.XPendingSummary("stream-copy", "group"); summary.Count != 0 {
+		t.Errorf("Expected the copied group to have no pending entry, got %d", summary.Count)
+	}
+	if _, err := ds.CopyTo(ds, "list", "list", true); err != ErrSameObject {
+		t.Errorf("Expected %v, got %v", ErrSameObject, err)
+	}
+	if ok, _ := ds.CopyTo(ds, "list", "zset", false); ok {
+		t.Errorf("Expected an existing key not to be replaced")
+	}
+	if ok, _ := ds.CopyTo(ds, "list", "zset", true); !ok || ds.Type("zset") != "list" {
+		t.Errorf("Expected an existing key to be replaced")
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	src, dst := NewDatastore(WithClock(clock)), NewDatastore(WithClock(clock))
+	src.SetWithExpiry("key", "v", 1000)
+	dst.Set("taken", "v")
+	src.Set("taken", "v")
+	if ok, _ := src.MoveTo(dst, "key"); !ok || src.Exists("key") {
+		t.Errorf("Expected key to be moved")
+	}
+	if ttl := dst.TTL("key"); ttl != 1000 {
+		t.Errorf("Expected the expiration to be kept, got %d", ttl)
+	}
+	if ok, _ := src.MoveTo(dst, "taken"); ok || !src.Exists("taken") {
+		t.Errorf("Expected a key existing in the destination not to be moved")
+	}
+	if _, err := src.MoveTo(src, "taken"); err != ErrSameObject {
+		t.Errorf("Expected %v, got %v", ErrSameObject, err)
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	ds := NewDatastore()
+	for i := range 100 {
+		ds.SAdd("big", fmt.Sprintf("member%d", i))
+	}
+	ds.Set("small", "v")
+	if n := ds.Unlink("big", "small", "missing"); n != 2 {
+		t.Errorf("Expected 2 unlinked keys, got %d", n)
+	}
+	if ds.Exists("big") || ds.Exists("small") {
+		t.Errorf("Expected the unlinked keys to be gone")
+	}
+	if n := ds.Touch("big", "small"); n != 0 {
+		t.Errorf("Expected no key to be touched, got %d", n)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	l := NewList()
+	l.PushBack("a")
+	z := NewSortedSet()
+	z.Add("a", 1)
+	s := Set{"a": {}}
+	for _, v := range []interface{ Len() int }{l, z, s} {
+		release(v)
+		if v.Len() != 0 {
+			t.Errorf("Expected %T to be emptied, got %d elements", v, v.Len())
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	ds := NewDatastore()
+	for i := range 100 {
+		ds.SAdd("set", fmt.Sprintf("member%d", i))
+	}
+	ds.Set("key", "v")
+	ds.Flush(true)
+	if n := ds.DBSize(); n != 0 {
+		t.Errorf("Expected an empty datastore, got %d keys", n)
+	}
+	ds.Set("key", "v")
+	ds.Flush(false)
+	if n := ds.DBSize(); n != 0 {
+		t.Errorf("Expected an empty datastore, got %d keys", n)
+	}
+}
+
+func TestSwapWith(t *testing.T) {
+	a, b := NewDatastore(), NewDatastore()
+	a.Set("a", "1")
+	b.Set("b", "2")
+	b.SwapWith(a)
+	if !a.Exists("b") || a.Exists("a") || !b.Exists("a") || b.Exists("b") {
+		t.Errorf("Expected the keys to be swapped, got %v and %v", a.Keys("*"), b.Keys("*"))
+	}
+}
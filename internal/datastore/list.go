@@ -0,0 +1,426 @@
+package datastore
+
+import "errors"
+
+var (
+	// ErrNoSuchKey is returned by list operations that require the key to exist.
+	ErrNoSuchKey = errors.New("ERR no such key")
+	// ErrIndexOutOfRange is returned when a list index is outside of the list bounds.
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+// List is a double-ended queue of strings backed by a ring buffer.
+// Pushing and popping at both ends is O(1) amortized and access by index is O(1).
+type List struct {
+	items []string
+	head  int
+	size  int
+}
+
+// NewList creates an empty list.
+func NewList() *List {
+	return &List{items: make([]string, 4)}
+}
+
+// Len returns the number of elements in the list.
+func (l *List) Len() int {
+	return l.size
+}
+
+// PushFront inserts the value at the head of the list.
+func (l *List) PushFront(value string) {
+	l.grow()
+	l.head = (l.head - 1 + len(l.items)) % len(l.items)
+	l.items[l.head] = value
+	l.size++
+}
+
+// PushBack inserts the value at the tail of the list.
+func (l *List) PushBack(value string) {
+	l.grow()
+	l.items[(l.head+l.size)%len(l.items)] = value
+	l.size++
+}
+
+// PopFront removes and returns the element at the head of the list.
+func (l *List) PopFront() (string, bool) {
+	if l.size == 0 {
+		return "", false
+	}
+	v := l.items[l.head]
+	l.items[l.head] = ""
+	l.head = (l.head + 1) % len(l.items)
+	l.size--
+	return v, true
+}
+
+// PopBack removes and returns the element at the tail of the list.
+func (l *List) PopBack() (string, bool) {
+	if l.size == 0 {
+		return "", false
+	}
+	idx := (l.head + l.size - 1) % len(l.items)
+	v := l.items[idx]
+	l.items[idx] = ""
+	l.size--
+	return v, true
+}
+
+// At returns the element at the zero-based index, counted from the head.
+func (l *List) At(i int) string {
+	return l.items[(l.head+i)%len(l.items)]
+}
+
+// Put replaces the element at the zero-based index, counted from the head.
+func (l *List) Put(i int, value string) {
+	l.items[(l.head+i)%len(l.items)] = value
+}
+
+// Values returns a copy of the elements from the head to the tail.
+func (l *List) Values() []string {
+	return l.Slice(0, l.size)
+}
+
+// Slice returns a copy of the elements in the half-open range [from, to).
+func (l *List) Slice(from, to int) []string {
+	ret := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		ret = append(ret, l.At(i))
+	}
+	return ret
+}
+
+// clone returns a copy of the list.
+func (l *List) clone() *List {
+	c := &List{}
+	c.reset(l.Values())
+	return c
+}
+
+// reset replaces the contents of the list with the provided values.
+func (l *List) reset(values []string) {
+	l.items = make([]string, max(4, len(values)))
+	copy(l.items, values)
+	l.head = 0
+	l.size = len(values)
+}
+
+func (l *List) grow() {
+	if l.size < len(l.items) {
+		return
+	}
+	items := make([]string, len(l.items)*2)
+	for i := 0; i < l.size; i++ {
+		items[i] = l.At(i)
+	}
+	l.items = items
+	l.head = 0
+}
+
+// LPush inserts the values at the head of the list stored at key, creating it if needed.
+// Returns the length of the list after the push.
+func (d *Datastore) LPush(key string, values ...string) (int64, error) {
+	return d.push(key, true, values)
+}
+
+// RPush inserts the values at the tail of the list stored at key, creating it if needed.
+// Returns the length of the list after the push.
+func (d *Datastore) RPush(key string, values ...string) (int64, error) {
+	return d.push(key, false, values)
+}
+
+func (d *Datastore) push(key string, head bool, values []string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	l, err := d.getList(key)
+	if err != nil {
+		return 0, err
+	}
+	l = d.pushElements(key, l, head, values)
+	return int64(l.Len()), nil
+}
+
+// LPop removes and returns up to count elements from the head of the list stored at key.
+// A nil slice is returned when the key does not exist.
+func (d *Datastore) LPop(key string, count int) ([]string, error) {
+	return d.pop(key, true, count)
+}
+
+// RPop removes and returns up to count elements from the tail of the list stored at key.
+// A nil slice is returned when the key does not exist.
+func (d *Datastore) RPop(key string, count int) ([]string, error) {
+	return d.pop(key, false, count)
+}
+
+func (d *Datastore) pop(key string, head bool, count int) ([]string, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	l, err := d.getList(key)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	ret := d.popElements(key, l, head, count)
+	d.deleteIfEmpty(key, l)
+	return ret, nil
+}
+
+// popElements removes and returns up to count elements from the head or the tail of the list
+// stored at key, leaving it to the caller to delete the key once the list is empty.
+// The caller must hold d.mu.
+func (d *Datastore) popElements(key string, l *List, head bool, count int) []string {
+	ret := make([]string, 0, min(count, l.Len()))
+	for range count {
+		var v string
+		var ok bool
+		if head {
+			v, ok = l.PopFront()
+		} else {
+			v, ok = l.PopBack()
+		}
+		if !ok {
+			break
+		}
+		ret = append(ret, v)
+	}
+	if len(ret) > 0 {
+		if head {
+			d.notify(EventList, "lpop", key)
+		} else {
+			d.notify(EventList, "rpop", key)
+		}
+	}
+	return ret
+}
+
+// pushElements inserts the values at the head or the tail of the list stored at key, creating
+// it if needed. The caller must hold d.mu and have checked the type of the key.
+func (d *Datastore) pushElements(key string, l *List, head bool, values []string) *List {
+	if l == nil {
+		l = NewList()
+		d.store(key, newEntry(l, -1))
+	}
+	for _, v := range values {
+		if head {
+			l.PushFront(v)
+		} else {
+			l.PushBack(v)
+		}
+	}
+	if head {
+		d.notify(EventList, "lpush", key)
+	} else {
+		d.notify(EventList, "rpush", key)
+	}
+	return l
+}
+
+// LLen returns the length of the list stored at key, or 0 if the key does not exist.
+func (d *Datastore) LLen(key string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	l, err := d.getList(key)
+	if err != nil || l == nil {
+		return 0, err
+	}
+	return int64(l.Len()), nil
+}
+
+// LRange returns the elements between start and stop (inclusive) of the list stored at key.
+// Negative indexes are counted from the tail of the list.
+func (d *Datastore) LRange(key string, start, stop int64) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	l, err := d.getList(key)
+	if err != nil || l == nil {
+		return []string{}, err
+	}
+	from, to := normalizeRange(start, stop, l.Len())
+	return l.Slice(from, to), nil
+}
+
+// LIndex returns the element at index in the list stored at key.
+// Negative indexes are counted from the tail of the list.
+func (d *Datastore) LIndex(key string, index int64) (string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	l, err := d.getList(key)
+	if err != nil {
+		return "", err
+	}
+	if l == nil {
+		return "", ErrNotFound
+	}
+	i, ok := normalizeIndex(index, l.Len())
+	if !ok {
+		return "", ErrNotFound
+	}
+	return l.At(i), nil
+}
+
+// LSet replaces the element at index in the list stored at key.
+func (d *Datastore) LSet(key string, index int64, value string) error {
+	d.mu.Lock()
+	defer d.unlock()
+	l, err := d.getList(key)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return ErrNoSuchKey
+	}
+	i, ok := normalizeIndex(index, l.Len())
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	l.Put(i, value)
+	d.notify(EventList, "lset", key)
+	return nil
+}
+
+// LRem removes elements equal to value from the list stored at key.
+// A positive count removes up to count elements from head to tail, a negative count
+// removes up to -count elements from tail to head and zero removes all of them.
+// Returns the number of removed elements.
+func (d *Datastore) LRem(key string, count int64, value string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	l, err := d.getList(key)
+	if err != nil || l == nil {
+		return 0, err
+	}
+	values := l.Values()
+	remove := make([]bool, len(values))
+	var removed int64
+	if count >= 0 {
+		for i := 0; i < len(values) && (count == 0 || removed < count); i++ {
+			if values[i] == value {
+				remove[i] = true
+				removed++
+			}
+		}
+	} else {
+		for i := len(values) - 1; i >= 0 && removed < -count; i-- {
+			if values[i] == value {
+				remove[i] = true
+				removed++
+			}
+		}
+	}
+	if removed > 0 {
+		kept := make([]string, 0, len(values)-int(removed))
+		for i, v := range values {
+			if !remove[i] {
+				kept = append(kept, v)
+			}
+		}
+		l.reset(kept)
+		d.notify(EventList, "lrem", key)
+		d.deleteIfEmpty(key, l)
+	}
+	return removed, nil
+}
+
+// LTrim trims the list stored at key so that it only contains the elements between start and stop (inclusive).
+func (d *Datastore) LTrim(key string, start, stop int64) error {
+	d.mu.Lock()
+	defer d.unlock()
+	l, err := d.getList(key)
+	if err != nil || l == nil {
+		return err
+	}
+	from, to := normalizeRange(start, stop, l.Len())
+	l.reset(l.Slice(from, to))
+	d.notify(EventList, "ltrim", key)
+	d.deleteIfEmpty(key, l)
+	return nil
+}
+
+// LInsert inserts value before or after the first occurrence of pivot in the list stored at key.
+// Returns the length of the list after the insert, -1 when the pivot was not found
+// and 0 when the key does not exist.
+func (d *Datastore) LInsert(key string, before bool, pivot, value string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	l, err := d.getList(key)
+	if err != nil || l == nil {
+		return 0, err
+	}
+	values := l.Values()
+	for i, v := range values {
+		if v != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		values = append(values[:i], append([]string{value}, values[i:]...)...)
+		l.reset(values)
+		d.notify(EventList, "linsert", key)
+		return int64(l.Len()), nil
+	}
+	return -1, nil
+}
+
+// getList returns the list stored at key, nil if the key does not exist or
+// ErrWrongType if the key holds another kind of value. The caller must hold d.mu.
+func (d *Datastore) getList(key string) (*List, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	l, ok := e.Value.(*List)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return l, nil
+}
+
+// deleteIfEmpty removes the key once the collection stored at it has no elements left,
+// raising a del event. The caller must hold d.mu.
+func (d *Datastore) deleteIfEmpty(key string, c interface{ Len() int }) {
+	if c.Len() == 0 {
+		d.remove(key)
+		d.notify(EventGeneric, "del", key)
+	}
+}
+
+// discardIfEmpty removes the key when the collection stored at it has no elements, like
+// a collection created by a write that failed. The caller must hold d.mu.
+func (d *Datastore) discardIfEmpty(key string, c interface{ Len() int }) {
+	if c.Len() == 0 {
+		d.remove(key)
+	}
+}
+
+// normalizeIndex converts a possibly negative index into an offset from the head.
+func normalizeIndex(index int64, length int) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// normalizeRange converts an inclusive, possibly negative, start/stop pair into a
+// half-open [from, to) range clamped to the length of the collection.
+func normalizeRange(start, stop int64, length int) (int, int) {
+	n := int64(length)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= n {
+		return 0, 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	return int(start), int(stop + 1)
+}
@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestListPushPop(t *testing.T) {
+	l := NewList()
+	for i := range 10 {
+		l.PushBack(fmt.Sprintf("b%d", i))
+		l.PushFront(fmt.Sprintf("f%d", i))
+	}
+	if l.Len() != 20 {
+		t.Errorf("Expected 20 items, got %d", l.Len())
+	}
+	if v, _ := l.PopFront(); v != "f9" {
+		t.Errorf("Expected 'f9', got '%s'", v)
+	}
+	if v, _ := l.PopBack(); v != "b9" {
+		t.Errorf("Expected 'b9', got '%s'", v)
+	}
+	if l.At(0) != "f8" || l.At(l.Len()-1) != "b8" {
+		t.Errorf("Unexpected list ends %v", l.Values())
+	}
+	for l.Len() > 0 {
+		l.PopBack()
+	}
+	if _, ok := l.PopFront(); ok {
+		t.Errorf("Expected pop from an empty list to fail")
+	}
+}
+
+func TestLRange(t *testing.T) {
+	tests := map[string]struct {
+		start, stop int64
+		expected    []string
+	}{
+		"Whole list":        {start: 0, stop: -1, expected: []string{"a", "b", "c", "d"}},
+		"Negative indexes":  {start: -3, stop: -2, expected: []string{"b", "c"}},
+		"Stop out of range": {start: 2, stop: 100, expected: []string{"c", "d"}},
+		"Start after stop":  {start: 3, stop: 1, expected: []string{}},
+		"Start past end":    {start: 10, stop: 20, expected: []string{}},
+	}
+	ds := NewDatastore()
+	ds.RPush("key", "a", "b", "c", "d")
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ds.LRange("key", test.start, test.stop)
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Expected: %v got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestListWrongType(t *testing.T) {
+	ds := NewDatastore()
+	ds.Set("key", "value")
+	if _, err := ds.LPush("key", "a"); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	ds.RPush("list", "a")
+	if _, err := ds.Get("list"); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestListDeletedWhenEmpty(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("key", "a", "b")
+	ds.LPop("key", 5)
+	if ds.Exists("key") {
+		t.Errorf("Expected empty list to be removed")
+	}
+}
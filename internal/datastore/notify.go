@@ -0,0 +1,47 @@
+package datastore
+
+// EventClass is the class of a keyspace event, the classes being selected by the
+// notify-keyspace-events setting of Redis.
+type EventClass int
+
+const (
+	// EventGeneric is the class of the events of commands not specific to a type, like DEL.
+	EventGeneric EventClass = 1 << iota
+	EventString
+	EventList
+	EventSet
+	EventHash
+	EventZSet
+	EventStream
+	// EventExpired is the class of the events of keys deleted because they expired.
+	EventExpired
+	// EventEvicted is the class of the events of keys evicted for maxmemory, which is not
+	// implemented.
+	EventEvicted
+)
+
+// Notifier receives the keyspace events of a datastore, while its lock is held.
+type Notifier func(class EventClass, event, key string)
+
+// WithNotifier makes the datastore raise its keyspace events to n.
+func WithNotifier(n Notifier) Option {
+	return func(d *Datastore) {
+		d.notifier = n
+	}
+}
+
+// notify raises the event on the key, which flags its watches, counts as an access when it has
+// been written and makes the clients blocked on it ready to be served. Expired keys flag their
+// watches as soon as they are found expired. The caller must hold d.mu for writing.
+func (d *Datastore) notify(class EventClass, event, key string) {
+	if class != EventExpired {
+		d.SignalModified(key)
+	}
+	if e, ok := d.data[key]; ok {
+		e.access.Store(d.nowMillis())
+		d.signalReady(key)
+	}
+	if d.notifier != nil {
+		d.notifier(class, event, key)
+	}
+}
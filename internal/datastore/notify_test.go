@@ -0,0 +1,84 @@
+package datastore
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type event struct {
+	class      EventClass
+	event, key string
+}
+
+func recordEvents(events *[]event) Option {
+	return WithNotifier(func(class EventClass, e, key string) {
+		*events = append(*events, event{class, e, key})
+	})
+}
+
+func TestNotify(t *testing.T) {
+	var events []event
+	ds := NewDatastore(recordEvents(&events))
+	ds.Set("s", "value")
+	ds.RPush("l", "a", "b")
+	ds.LPop("l", 2)
+	ds.Rename("s", "t")
+	ds.SAdd("set", "a")
+	ds.SAdd("set", "a")
+	ds.Delete("t")
+	ds.Delete("missing")
+	expected := []event{
+		{EventString, "set", "s"},
+		{EventList, "rpush", "l"},
+		{EventList, "lpop", "l"},
+		{EventGeneric, "del", "l"},
+		{EventGeneric, "rename_from", "s"},
+		{EventGeneric, "rename_to", "t"},
+		{EventSet, "sadd", "set"},
+		{EventGeneric, "del", "t"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
+
+func TestNotifyFailedWrite(t *testing.T) {
+	var events []event
+	ds := NewDatastore(recordEvents(&events))
+	ds.HSet("h", "f", "x")
+	events = nil
+	if _, err := ds.HIncrBy("h", "f", 1); err == nil {
+		t.Fatalf("Expected incrementing a non-integer field to fail")
+	}
+	if _, _, err := ds.ZIncrBy("z", ZAddOptions{}, math.NaN(), "m"); err == nil {
+		t.Fatalf("Expected a NaN score to fail")
+	}
+	ds.ZAdd("z", ZAddOptions{GT: true}, nil)
+	if len(events) != 0 {
+		t.Errorf("Expected failed writes not to raise events, got %v", events)
+	}
+	if ds.Exists("z") {
+		t.Errorf("Expected the sorted set created by a failed write to be discarded")
+	}
+}
+
+func TestNotifyExpired(t *testing.T) {
+	var events []event
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock), recordEvents(&events))
+	ds.SetWithExpiry("lazy", "value", 100)
+	ds.SetWithExpiry("active", "value", 100)
+	clock.Advance(100 * time.Millisecond)
+	events = nil
+	ds.Exists("lazy")
+	ds.ExpiryCheck()
+	expected := []event{
+		{EventExpired, "expired", "lazy"},
+		{EventExpired, "expired", "active"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
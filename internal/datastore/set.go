@@ -0,0 +1,406 @@
+package datastore
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+)
+
+// maxPrealloc bounds the capacity allocated upfront for a reply of a length chosen by the client,
+// which grows as needed beyond it.
+const maxPrealloc = 1024
+
+// Set is an unordered collection of unique strings stored under a single key.
+type Set map[string]struct{}
+
+// Len returns the number of members in the set.
+func (s Set) Len() int {
+	return len(s)
+}
+
+// Members returns the members of the set in no particular order.
+func (s Set) Members() []string {
+	return slices.Collect(maps.Keys(s))
+}
+
+// sample returns up to count distinct members of the set chosen uniformly at random.
+// Map iteration order is not uniformly random, so the members are picked by reservoir
+// sampling over the whole set rather than taken from the start of an iteration.
+func (s Set) sample(count int) []string {
+	ret := make([]string, 0, min(count, len(s)))
+	i := 0
+	for m := range s {
+		if len(ret) < count {
+			ret = append(ret, m)
+		} else if j := rand.IntN(i + 1); j < count {
+			ret[j] = m
+		}
+		i++
+	}
+	rand.Shuffle(len(ret), func(i, j int) { ret[i], ret[j] = ret[j], ret[i] })
+	return ret
+}
+
+// draw returns count members of the non-empty set chosen uniformly at random, possibly
+// repeating. The positions of up to maxPrealloc members are drawn at a time and picked in
+// a single pass over the set.
+func (s Set) draw(count int) []string {
+	ret := make([]string, 0, min(count, maxPrealloc))
+	for len(ret) < count {
+		positions := make([]int, min(count-len(ret), maxPrealloc))
+		for i := range positions {
+			positions[i] = rand.IntN(len(s))
+		}
+		slices.Sort(positions)
+		batch := make([]string, 0, len(positions))
+		i := 0
+		for m := range s {
+			for len(batch) < len(positions) && positions[len(batch)] == i {
+				batch = append(batch, m)
+			}
+			if len(batch) == len(positions) {
+				break
+			}
+			i++
+		}
+		// The members come out in iteration order, so shuffle them.
+		rand.Shuffle(len(batch), func(i, j int) { batch[i], batch[j] = batch[j], batch[i] })
+		ret = append(ret, batch...)
+	}
+	return ret
+}
+
+type setOperation int
+
+const (
+	setInter setOperation = iota
+	setUnion
+	setDiff
+)
+
+// SAdd adds the members to the set stored at key, creating it if needed.
+// Returns the number of members that were added.
+func (d *Datastore) SAdd(key string, members ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if s == nil {
+		s = make(Set)
+		d.store(key, newEntry(s, -1))
+	}
+	var added int64
+	for _, m := range members {
+		if _, ok := s[m]; !ok {
+			s[m] = struct{}{}
+			added++
+		}
+	}
+	if added > 0 {
+		d.notify(EventSet, "sadd", key)
+	}
+	return added, nil
+}
+
+// SRem removes the members from the set stored at key.
+// Returns the number of members that were removed.
+func (d *Datastore) SRem(key string, members ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getSet(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, m := range members {
+		if _, ok := s[m]; ok {
+			delete(s, m)
+			removed++
+		}
+	}
+	if removed > 0 {
+		d.notify(EventSet, "srem", key)
+	}
+	d.deleteIfEmpty(key, s)
+	return removed, nil
+}
+
+// SMembers returns all members of the set stored at key.
+func (d *Datastore) SMembers(key string) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Members(), nil
+}
+
+// SIsMember reports whether member belongs to the set stored at key.
+func (d *Datastore) SIsMember(key, member string) (bool, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getSet(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := s[member]
+	return ok, nil
+}
+
+// SMIsMember reports for each of the members whether it belongs to the set stored at key.
+func (d *Datastore) SMIsMember(key string, members ...string) ([]bool, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]bool, len(members))
+	for i, m := range members {
+		_, ret[i] = s[m]
+	}
+	return ret, nil
+}
+
+// SCard returns the number of members in the set stored at key.
+func (d *Datastore) SCard(key string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getSet(key)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(s)), nil
+}
+
+// SPop removes and returns up to count random members from the set stored at key.
+// A nil slice is returned when the key does not exist.
+func (d *Datastore) SPop(key string, count int) ([]string, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getSet(key)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	members := s.sample(count)
+	for _, m := range members {
+		delete(s, m)
+	}
+	if len(members) > 0 {
+		d.notify(EventSet, "spop", key)
+	}
+	d.deleteIfEmpty(key, s)
+	return members, nil
+}
+
+// SRandMember returns random members from the set stored at key without removing them.
+// A positive count returns up to count distinct members, while a negative count
+// returns exactly -count members that may repeat.
+func (d *Datastore) SRandMember(key string, count int64) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getSet(key)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if count >= 0 {
+		return s.sample(int(min(count, int64(len(s))))), nil
+	}
+	return s.draw(int(-count)), nil
+}
+
+// SInter returns the members of the intersection of the sets stored at keys.
+func (d *Datastore) SInter(keys ...string) ([]string, error) {
+	return d.readSetOperation(setInter, keys)
+}
+
+// SUnion returns the members of the union of the sets stored at keys.
+func (d *Datastore) SUnion(keys ...string) ([]string, error) {
+	return d.readSetOperation(setUnion, keys)
+}
+
+// SDiff returns the members of the first set that are not present in any of the following sets.
+func (d *Datastore) SDiff(keys ...string) ([]string, error) {
+	return d.readSetOperation(setDiff, keys)
+}
+
+// SInterStore stores the intersection of the sets stored at keys in destination.
+// Returns the number of members in the resulting set.
+func (d *Datastore) SInterStore(destination string, keys ...string) (int64, error) {
+	return d.storeSetOperation(setInter, destination, keys)
+}
+
+// SUnionStore stores the union of the sets stored at keys in destination.
+// Returns the number of members in the resulting set.
+func (d *Datastore) SUnionStore(destination string, keys ...string) (int64, error) {
+	return d.storeSetOperation(setUnion, destination, keys)
+}
+
+// SDiffStore stores the difference of the sets stored at keys in destination.
+// Returns the number of members in the resulting set.
+func (d *Datastore) SDiffStore(destination string, keys ...string) (int64, error) {
+	return d.storeSetOperation(setDiff, destination, keys)
+}
+
+// SInterCard returns the cardinality of the intersection of the sets stored at keys.
+// A positive limit stops the computation once the cardinality reaches it.
+func (d *Datastore) SInterCard(limit int64, keys ...string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	sets, err := d.getSets(keys)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for m := range sets[0] {
+		if !inAll(m, sets[1:]) {
+			continue
+		}
+		n++
+		if limit > 0 && n >= limit {
+			break
+		}
+	}
+	return n, nil
+}
+
+// SMove moves member from the set stored at source to the set stored at destination.
+// Returns true if the member was moved.
+func (d *Datastore) SMove(source, destination, member string) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	src, err := d.getSet(source)
+	if err != nil {
+		return false, err
+	}
+	dst, err := d.getSet(destination)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := src[member]; !ok {
+		return false, nil
+	}
+	if source == destination {
+		return true, nil
+	}
+	delete(src, member)
+	d.notify(EventSet, "srem", source)
+	d.deleteIfEmpty(source, src)
+	if dst == nil {
+		dst = make(Set)
+		d.store(destination, newEntry(dst, -1))
+	}
+	dst[member] = struct{}{}
+	d.notify(EventSet, "sadd", destination)
+	return true, nil
+}
+
+func (d *Datastore) readSetOperation(op setOperation, keys []string) ([]string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.setOperation(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return s.Members(), nil
+}
+
+func (d *Datastore) storeSetOperation(op setOperation, destination string, keys []string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.setOperation(op, keys)
+	if err != nil {
+		return 0, err
+	}
+	if len(s) == 0 {
+		if d.lookup(destination) != nil {
+			d.remove(destination)
+			d.notify(EventGeneric, "del", destination)
+		}
+	} else {
+		d.store(destination, newEntry(s, -1))
+		d.notify(EventSet, op.storeEvent(), destination)
+	}
+	return int64(len(s)), nil
+}
+
+// storeEvent returns the name of the event raised when the result of op is stored.
+func (op setOperation) storeEvent() string {
+	switch op {
+	case setInter:
+		return "sinterstore"
+	case setUnion:
+		return "sunionstore"
+	}
+	return "sdiffstore"
+}
+
+// setOperation computes the result of op over the sets stored at keys into a new set.
+// The caller must hold d.mu.
+func (d *Datastore) setOperation(op setOperation, keys []string) (Set, error) {
+	sets, err := d.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(Set)
+	switch op {
+	case setInter:
+		for m := range sets[0] {
+			if inAll(m, sets[1:]) {
+				ret[m] = struct{}{}
+			}
+		}
+	case setUnion:
+		for _, s := range sets {
+			maps.Copy(ret, s)
+		}
+	case setDiff:
+		maps.Copy(ret, sets[0])
+		for _, s := range sets[1:] {
+			for m := range s {
+				delete(ret, m)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// getSets returns the sets stored at keys, treating missing keys as empty sets.
+// The caller must hold d.mu.
+func (d *Datastore) getSets(keys []string) ([]Set, error) {
+	sets := make([]Set, len(keys))
+	for i, k := range keys {
+		s, err := d.getSet(k)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = s
+	}
+	return sets, nil
+}
+
+// getSet returns the set stored at key, nil if the key does not exist or
+// ErrWrongType if the key holds another kind of value. The caller must hold d.mu.
+func (d *Datastore) getSet(key string) (Set, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	s, ok := e.Value.(Set)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return s, nil
+}
+
+func inAll(member string, sets []Set) bool {
+	for _, s := range sets {
+		if _, ok := s[member]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package datastore
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	ds := NewDatastore()
+	ds.SAdd("s1", "a", "b", "c")
+	ds.SAdd("s2", "b", "c", "d")
+	tests := map[string]struct {
+		op       func(...string) ([]string, error)
+		expected []string
+	}{
+		"Inter": {op: ds.SInter, expected: []string{"b", "c"}},
+		"Union": {op: ds.SUnion, expected: []string{"a", "b", "c", "d"}},
+		"Diff":  {op: ds.SDiff, expected: []string{"a"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := test.op("s1", "s2")
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, test.expected) {
+				t.Errorf("Expected: %v got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestSPop(t *testing.T) {
+	ds := NewDatastore()
+	ds.SAdd("key", "a", "b", "c")
+	got, err := ds.SPop("key", 2)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected 2 members, got %v", got)
+	}
+	if n, _ := ds.SCard("key"); n != 1 {
+		t.Errorf("Expected 1 member left, got %d", n)
+	}
+	last, _ := ds.SPop("key", 1)
+	if len(last) != 1 || last[0] == got[0] || last[0] == got[1] || ds.Exists("key") {
+		t.Errorf("Expected the last member to be popped, got %v after %v", last, got)
+	}
+}
+
+func TestSRandMember(t *testing.T) {
+	ds := NewDatastore()
+	ds.SAdd("key", "a", "b", "c")
+	got, _ := ds.SRandMember("key", 10)
+	if len(got) != 3 {
+		t.Errorf("Expected 3 distinct members, got %v", got)
+	}
+	got, _ = ds.SRandMember("key", 2)
+	if len(got) != 2 || got[0] == got[1] {
+		t.Errorf("Expected 2 distinct members, got %v", got)
+	}
+	got, _ = ds.SRandMember("key", -10)
+	if len(got) != 10 {
+		t.Errorf("Expected 10 members, got %v", got)
+	}
+}
+
+func TestSetSamplingIsUniform(t *testing.T) {
+	ds := NewDatastore()
+	members := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	ds.SAdd("key", members...)
+	const runs = 20000
+	counts := func(draw func() []string) map[string]int {
+		ret := map[string]int{}
+		for range runs {
+			for _, m := range draw() {
+				ret[m]++
+			}
+		}
+		return ret
+	}
+	check := func(name string, got map[string]int, expected int) {
+		for _, m := range members {
+			if got[m] < expected*9/10 || got[m] > expected*11/10 {
+				t.Errorf("%s: expected %s about %d times, got %v", name, m, expected, got)
+				return
+			}
+		}
+	}
+	check("SRANDMEMBER 3", counts(func() []string {
+		got, _ := ds.SRandMember("key", 3)
+		return got
+	}), runs*3/10)
+	check("SRANDMEMBER -3", counts(func() []string {
+		got, _ := ds.SRandMember("key", -3)
+		return got
+	}), runs*3/10)
+	check("SPOP 3", counts(func() []string {
+		got, _ := ds.SPop("key", 3)
+		ds.SAdd("key", got...)
+		return got
+	}), runs*3/10)
+	first := map[string]int{}
+	for range runs {
+		got, _ := ds.SRandMember("key", -2)
+		first[got[0]]++
+	}
+	check("SRANDMEMBER -2 order", first, runs/10)
+}
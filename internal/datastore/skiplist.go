@@ -0,0 +1,324 @@
+package datastore
+
+import (
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	// span is the number of nodes between this node and the forward one at this level,
+	// which lets rank queries be answered in O(log n).
+	span int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+// skiplist keeps members ordered by score and then lexicographically by member.
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+// ScoreRange is an interval of scores, each end being inclusive unless marked as exclusive.
+type ScoreRange struct {
+	Min, Max     float64
+	MinEx, MaxEx bool
+}
+
+// LexBound is one end of a lexicographical range. Inf is -1 for the "-" bound,
+// 1 for the "+" bound and 0 when the bound is Value.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// LexRange is an interval of members compared lexicographically.
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)}, level: 1}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before reports whether the node sorts before the score/member pair.
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+func (zsl *skiplist) insert(score float64, member string) *skiplistNode {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+func (zsl *skiplist) deleteNode(x *skiplistNode, update []*skiplistNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x != nil && x.score == score && x.member == member {
+		zsl.deleteNode(x, update[:])
+		return true
+	}
+	return false
+}
+
+// rank returns the 1-based rank of the member with the given score, or 0 if it is not present.
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.before(score, member) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node with the 1-based rank, or nil if it is out of range.
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+func (r ScoreRange) gteMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) lteMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+func (zsl *skiplist) isInRange(r ScoreRange) bool {
+	if r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx)) {
+		return false
+	}
+	if zsl.tail == nil || !r.gteMin(zsl.tail.score) {
+		return false
+	}
+	first := zsl.header.level[0].forward
+	return first != nil && r.lteMax(first.score)
+}
+
+// firstInRange returns the first node with a score inside the range, or nil.
+func (zsl *skiplist) firstInRange(r ScoreRange) *skiplistNode {
+	if !zsl.isInRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !r.lteMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+// lastInRange returns the last node with a score inside the range, or nil.
+func (zsl *skiplist) lastInRange(r ScoreRange) *skiplistNode {
+	if !zsl.isInRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	if !r.gteMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (r LexRange) gteMin(member string) bool {
+	switch r.Min.Inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	c := strings.Compare(member, r.Min.Value)
+	return c > 0 || (c == 0 && !r.Min.Exclusive)
+}
+
+func (r LexRange) lteMax(member string) bool {
+	switch r.Max.Inf {
+	case -1:
+		return false
+	case 1:
+		return true
+	}
+	c := strings.Compare(member, r.Max.Value)
+	return c < 0 || (c == 0 && !r.Max.Exclusive)
+}
+
+func (r LexRange) empty() bool {
+	if r.Min.Inf == 1 || r.Max.Inf == -1 {
+		return true
+	}
+	if r.Min.Inf != 0 || r.Max.Inf != 0 {
+		return false
+	}
+	c := strings.Compare(r.Min.Value, r.Max.Value)
+	return c > 0 || (c == 0 && (r.Min.Exclusive || r.Max.Exclusive))
+}
+
+func (zsl *skiplist) isInLexRange(r LexRange) bool {
+	if r.empty() {
+		return false
+	}
+	if zsl.tail == nil || !r.gteMin(zsl.tail.member) {
+		return false
+	}
+	first := zsl.header.level[0].forward
+	return first != nil && r.lteMax(first.member)
+}
+
+// firstInLexRange returns the first node with a member inside the range, or nil.
+func (zsl *skiplist) firstInLexRange(r LexRange) *skiplistNode {
+	if !zsl.isInLexRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !r.lteMax(x.member) {
+		return nil
+	}
+	return x
+}
+
+// lastInLexRange returns the last node with a member inside the range, or nil.
+func (zsl *skiplist) lastInLexRange(r LexRange) *skiplistNode {
+	if !zsl.isInLexRange(r) {
+		return nil
+	}
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	if !r.gteMin(x.member) {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,228 @@
+package datastore
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrSortScore is returned when sorting numerically values that are not numbers.
+var ErrSortScore = errors.New("ERR One or more scores can't be converted into double")
+
+// SortOptions describe how SORT orders the elements of a list, set or sorted set.
+type SortOptions struct {
+	// By is the pattern of the keys holding the weights of the elements, the first * being
+	// replaced by an element and a ->field suffix selecting a hash field. Elements are not
+	// sorted when the pattern has no *. Elements are their own weights when By is empty.
+	By string
+	// Get are the patterns of the values returned for each element instead of the element,
+	// # standing for the element itself.
+	Get []string
+	// Limit restricts the result to Count elements starting at Offset, all of them when
+	// Count is negative.
+	Limit         bool
+	Offset, Count int64
+	Desc          bool
+	// Alpha compares the weights as strings instead of numbers.
+	Alpha bool
+}
+
+// sortElement is an element being sorted, with its weight.
+type sortElement struct {
+	value  string
+	weight *string
+	score  float64
+}
+
+// Sort returns the elements of the list, set or sorted set stored at key, ordered and
+// projected according to the options. Missing values are nil.
+func (d *Datastore) Sort(key string, opts SortOptions) ([]*string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	return d.sort(key, opts, false)
+}
+
+// SortStore stores the result of Sort as a list at dst, replacing it, missing values being
+// stored as empty strings. The key is deleted when the result is empty.
+// Returns the length of the list.
+func (d *Datastore) SortStore(key, dst string, opts SortOptions) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	result, err := d.sort(key, opts, true)
+	if err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		if d.lookup(dst) != nil {
+			d.remove(dst)
+			d.notify(EventGeneric, "del", dst)
+		}
+		return 0, nil
+	}
+	l := NewList()
+	for _, v := range result {
+		l.PushBack(valueOrEmpty(v))
+	}
+	d.store(dst, newEntry(l, -1))
+	d.notify(EventList, "sortstore", dst)
+	return int64(len(result)), nil
+
+}
+
+// sort implements Sort. The caller must hold d.mu.
+func (d *Datastore) sort(key string, opts SortOptions, store bool) ([]*string, error) {
+	dontSort := opts.By != "" && !strings.Contains(opts.By, "*")
+	var elements []sortElement
+	e := d.lookup(key)
+	if e != nil {
+		switch v := e.Value.(type) {
+		case *List:
+			for _, value := range v.Values() {
+				elements = append(elements, sortElement{value: value})
+			}
+		case Set:
+			for _, value := range v.Members() {
+				elements = append(elements, sortElement{value: value})
+			}
+			if dontSort && store {
+				// The order of a set is random, which would make the stored list random too.
+				dontSort, opts.By, opts.Alpha = false, "", true
+			}
+		case *SortedSet:
+			members := v.Range(ZRangeSpec{By: ZRangeByRank, Start: 0, Stop: -1, Rev: dontSort && opts.Desc})
+			for _, m := range members {
+				elements = append(elements, sortElement{value: m.Member})
+			}
+		default:
+			return nil, ErrWrongType
+		}
+	}
+	if !dontSort {
+		for i := range elements {
+			el := &elements[i]
+			weight := &el.value
+			if opts.By != "" {
+				if weight = d.lookupByPattern(opts.By, el.value); weight == nil {
+					continue
+				}
+			}
+			if opts.Alpha {
+				if opts.By != "" {
+					el.weight = weight
+				}
+				continue
+			}
+			score, ok := parseSortScore(*weight)
+			if !ok {
+				return nil, ErrSortScore
+			}
+			el.score = score
+		}
+		slices.SortStableFunc(elements, func(a, b sortElement) int {
+			cmp := compareSortElements(a, b, opts.Alpha, opts.By != "")
+			if opts.Desc {
+				return -cmp
+			}
+			return cmp
+		})
+	}
+	start, end := int64(0), int64(len(elements))
+	if opts.Limit {
+		start = min(max(opts.Offset, 0), end)
+		if opts.Count >= 0 {
+			end = min(start+opts.Count, end)
+		}
+	}
+	result := make([]*string, 0)
+	for _, el := range elements[start:end] {
+		if len(opts.Get) == 0 {
+			result = append(result, &el.value)
+			continue
+		}
+		for _, pattern := range opts.Get {
+			result = append(result, d.lookupByPattern(pattern, el.value))
+		}
+	}
+	return result, nil
+}
+
+// compareSortElements compares two elements by score, or as strings when alpha is set, their
+// weights when byPattern is set or themselves otherwise. Equal scores compare the elements so
+// that the order is deterministic.
+func compareSortElements(a, b sortElement, alpha, byPattern bool) int {
+	if !alpha {
+		if a.score != b.score {
+			if a.score < b.score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.value, b.value)
+	}
+	if !byPattern {
+		return strings.Compare(a.value, b.value)
+	}
+	switch {
+	case a.weight == nil && b.weight == nil:
+		return 0
+	case a.weight == nil:
+		return -1
+	case b.weight == nil:
+		return 1
+	}
+	return strings.Compare(*a.weight, *b.weight)
+}
+
+// parseSortScore parses a weight like strtod, reporting whether it is a number.
+func parseSortScore(s string) (float64, bool) {
+	s = strings.TrimLeft(s, " \t\n\v\f\r")
+	if s == "" {
+		return 0, true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && !math.IsNaN(v)
+}
+
+// lookupByPattern returns the value of the key obtained by replacing the first * of the
+// pattern with subst, or of the hash field when the pattern ends with ->field, # standing
+// for subst itself. Returns nil if there is no such value. The caller must hold d.mu.
+func (d *Datastore) lookupByPattern(pattern, subst string) *string {
+	if pattern == "#" {
+		return &subst
+	}
+	star := strings.IndexByte(pattern, '*')
+	if star == -1 {
+		return nil
+	}
+	key, field := pattern, ""
+	if arrow := strings.Index(pattern[star+1:], "->"); arrow != -1 && star+1+arrow+2 < len(pattern) {
+		key, field = pattern[:star+1+arrow], pattern[star+1+arrow+2:]
+	}
+	key = key[:star] + subst + key[star+1:]
+	if field == "" {
+		v, err := d.readString(key)
+		if err != nil {
+			return nil
+		}
+		return v
+	}
+	h, err := d.getHash(key)
+	if err != nil || h == nil {
+		return nil
+	}
+	v, ok := h[field]
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// valueOrEmpty returns the string pointed to by v, or an empty string if v is nil.
+func valueOrEmpty(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
@@ -0,0 +1,90 @@
+package datastore
+
+import (
+	"slices"
+	"testing"
+)
+
+func sortValues(values []*string) []string {
+	ret := make([]string, len(values))
+	for i, v := range values {
+		if v == nil {
+			ret[i] = "<nil>"
+		} else {
+			ret[i] = *v
+		}
+	}
+	return ret
+}
+
+func TestSort(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("ids", "3", "1", "2", "10")
+	ds.SAdd("names", "bob", "alice", "carol")
+	ds.ZAdd("zset", ZAddOptions{}, []ScoredMember{{"c", 1}, {"a", 2}, {"b", 3}})
+	ds.MSet("weight_1", "30", "weight_2", "10", "weight_3", "20")
+	ds.HSet("obj_1", "name", "one")
+	ds.HSet("obj_2", "name", "two")
+	ds.Set("obj_3", "three")
+	tests := map[string]struct {
+		key      string
+		opts     SortOptions
+		expected []string
+	}{
+		"Numeric":        {key: "ids", expected: []string{"1", "2", "3", "10"}},
+		"Desc":           {key: "ids", opts: SortOptions{Desc: true}, expected: []string{"10", "3", "2", "1"}},
+		"Alpha":          {key: "ids", opts: SortOptions{Alpha: true}, expected: []string{"1", "10", "2", "3"}},
+		"Limit":          {key: "ids", opts: SortOptions{Limit: true, Offset: 1, Count: 2}, expected: []string{"2", "3"}},
+		"Negative count": {key: "ids", opts: SortOptions{Limit: true, Offset: 2, Count: -1}, expected: []string{"3", "10"}},
+		"Offset past":    {key: "ids", opts: SortOptions{Limit: true, Offset: 10, Count: 2}, expected: []string{}},
+		"Set":            {key: "names", opts: SortOptions{Alpha: true}, expected: []string{"alice", "bob", "carol"}},
+		"By":             {key: "ids", opts: SortOptions{By: "weight_*"}, expected: []string{"10", "2", "3", "1"}},
+		"By alpha":       {key: "ids", opts: SortOptions{By: "weight_*", Alpha: true, Desc: true}, expected: []string{"1", "3", "2", "10"}},
+		"Get":            {key: "ids", opts: SortOptions{Get: []string{"#", "obj_*->name"}}, expected: []string{"1", "one", "2", "two", "3", "<nil>", "10", "<nil>"}},
+		"Get string":     {key: "ids", opts: SortOptions{Get: []string{"obj_*"}, Limit: true, Count: 3}, expected: []string{"<nil>", "<nil>", "three"}},
+		"No sort":        {key: "ids", opts: SortOptions{By: "nosort", Desc: true}, expected: []string{"3", "1", "2", "10"}},
+		"Sorted set":     {key: "zset", opts: SortOptions{By: "nosort", Desc: true}, expected: []string{"b", "a", "c"}},
+		"Missing":        {key: "missing", expected: []string{}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ds.Sort(test.key, test.opts)
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if !slices.Equal(sortValues(got), test.expected) {
+				t.Errorf("Expected: %v got %v", test.expected, sortValues(got))
+			}
+		})
+	}
+}
+
+func TestSortErrors(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("list", "1", "a")
+	ds.Set("string", "v")
+	if _, err := ds.Sort("list", SortOptions{}); err != ErrSortScore {
+		t.Errorf("Expected %v, got %v", ErrSortScore, err)
+	}
+	if _, err := ds.Sort("string", SortOptions{}); err != ErrWrongType {
+		t.Errorf("Expected %v, got %v", ErrWrongType, err)
+	}
+}
+
+func TestSortStore(t *testing.T) {
+	ds := NewDatastore()
+	ds.SAdd("set", "b", "c", "a")
+	ds.Set("dst", "v")
+	n, err := ds.SortStore("set", "dst", SortOptions{By: "nosort", Get: []string{"#", "missing_*"}})
+	if err != nil || n != 6 {
+		t.Errorf("Expected 6 stored elements, got %d %v", n, err)
+	}
+	// Sets are sorted when storing even without sorting, so that the result is deterministic.
+	got, _ := ds.LRange("dst", 0, -1)
+	if expected := []string{"a", "", "b", "", "c", ""}; !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if n, _ := ds.SortStore("missing", "dst", SortOptions{}); n != 0 || ds.Exists("dst") {
+		t.Errorf("Expected an empty result to delete the destination")
+	}
+}
@@ -0,0 +1,448 @@
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// streamNodeMaxEntries mirrors the stream-node-max-entries setting of Redis. Approximate
+// trimming only ever removes whole nodes of this many entries.
+const streamNodeMaxEntries = 100
+
+var (
+	// ErrInvalidStreamID is returned when a stream ID cannot be parsed.
+	ErrInvalidStreamID = errors.New("ERR Invalid stream ID specified as stream command argument")
+	// ErrStreamIDTooSmall is returned when XADD is given an ID that is not greater than the last one.
+	ErrStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	// ErrStreamIDZero is returned when XADD is given the 0-0 ID.
+	ErrStreamIDZero = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	// ErrStreamExhausted is returned when no greater ID can be generated.
+	ErrStreamExhausted = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// StreamID identifies a stream entry by its millisecond time and a sequence number.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID is the greatest possible stream ID.
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// StreamEntry is a single entry of a stream, with its fields stored as a flat list of pairs.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// Stream is an append-only log of entries ordered by ID.
+type Stream struct {
+	// entries is resliced rather than copied when entries are removed from either end, so
+	// trimming costs O(1) while append reclaims the space left at the front when it grows it.
+	entries      []StreamEntry
+	lastID       StreamID
+	maxDeletedID StreamID
+	entriesAdded uint64
+	groups       map[string]*ConsumerGroup
+}
+
+// StreamTrimStrategy selects how a stream is trimmed.
+type StreamTrimStrategy int
+
+const (
+	StreamTrimMaxLen StreamTrimStrategy = iota
+	StreamTrimMinID
+)
+
+// StreamTrim describes the MAXLEN or MINID trimming of XADD and XTRIM.
+type StreamTrim struct {
+	Strategy StreamTrimStrategy
+	// Approx only removes whole nodes, possibly leaving more entries than requested.
+	Approx bool
+	MaxLen int64
+	MinID  StreamID
+	// Limit caps the number of entries removed by an approximate trim. Zero means the default.
+	Limit int64
+}
+
+// XAddOptions describe the ID and behavior of XADD.
+type XAddOptions struct {
+	// ID is the explicit ID of the new entry.
+	ID StreamID
+	// AutoID generates the whole ID from the current time, as with "*".
+	AutoID bool
+	// AutoSeq generates only the sequence number for ID.Ms, as with "<ms>-*".
+	AutoSeq bool
+	// NoMkStream does not create the stream when the key does not exist.
+	NoMkStream bool
+	Trim       *StreamTrim
+}
+
+// StreamReadResult holds the entries read from one stream by XREAD.
+type StreamReadResult struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// ParseStreamID parses an ID in the <ms>-<seq> form. When the sequence is omitted it is set to missingSeq.
+func ParseStreamID(s string, missingSeq uint64) (StreamID, error) {
+	ms, seq, found := strings.Cut(s, "-")
+	var id StreamID
+	var err error
+	if id.Ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, ErrInvalidStreamID
+	}
+	if !found {
+		id.Seq = missingSeq
+		return id, nil
+	}
+	if id.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return id, ErrInvalidStreamID
+	}
+	return id, nil
+}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+// Compare returns -1, 0 or 1 depending on whether id is less than, equal to or greater than other.
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq):
+		return -1
+	case id == other:
+		return 0
+	}
+	return 1
+}
+
+// Next returns the smallest ID greater than id and false if id is already the greatest.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Prev returns the greatest ID smaller than id and false if id is already the smallest.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// NewStream creates an empty stream.
+func NewStream() *Stream {
+	return &Stream{}
+}
+
+// clone returns a copy of the stream and of its consumer groups. Entries are shared with the
+// copy as their fields are never modified.
+func (s *Stream) clone() *Stream {
+	c := *s
+	c.entries = slices.Clone(s.entries)
+	if s.groups != nil {
+		c.groups = make(map[string]*ConsumerGroup, len(s.groups))
+		for name, g := range s.groups {
+			c.groups[name] = g.clone()
+		}
+	}
+	return &c
+}
+
+// Len returns the number of entries in the stream.
+func (s *Stream) Len() int {
+	return len(s.entries)
+}
+
+// LastID returns the ID of the last entry ever added to the stream.
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// search returns the index of the first entry with an ID greater than or equal to id.
+func (s *Stream) search(id StreamID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return s.entries[i].ID.Compare(id) >= 0
+	})
+}
+
+// Range returns up to count entries with IDs between start and end (inclusive),
+// in descending order when rev is set. A count of zero or less returns all of them.
+func (s *Stream) Range(start, end StreamID, count int64, rev bool) []StreamEntry {
+	ret := make([]StreamEntry, 0)
+	if start.Compare(end) > 0 {
+		return ret
+	}
+	from := s.search(start)
+	to := from + sort.Search(len(s.entries)-from, func(i int) bool {
+		return s.entries[from+i].ID.Compare(end) > 0
+	})
+	if count > 0 && int64(to-from) > count {
+		if rev {
+			from = to - int(count)
+		} else {
+			to = from + int(count)
+		}
+	}
+	ret = slices.Grow(ret, to-from)
+	if rev {
+		for i := to - 1; i >= from; i-- {
+			ret = append(ret, s.entries[i])
+		}
+	} else {
+		ret = append(ret, s.entries[from:to]...)
+	}
+	return ret
+}
+
+// trim removes entries according to the trim options and returns how many were removed.
+func (s *Stream) trim(t StreamTrim) int64 {
+	var remove int
+	switch t.Strategy {
+	case StreamTrimMaxLen:
+		remove = max(0, len(s.entries)-int(max(t.MaxLen, 0)))
+	case StreamTrimMinID:
+		remove = s.search(t.MinID)
+	}
+	if t.Approx {
+		limit := t.Limit
+		if limit <= 0 {
+			limit = 100 * streamNodeMaxEntries
+		}
+		remove = min(remove, int(limit))
+		remove -= remove % streamNodeMaxEntries
+	}
+	if remove == 0 {
+		return 0
+	}
+	if last := s.entries[remove-1].ID; last.Compare(s.maxDeletedID) > 0 {
+		s.maxDeletedID = last
+	}
+	clear(s.entries[:remove])
+	s.entries = s.entries[remove:]
+	return int64(remove)
+}
+
+// delete removes the entries at the indices, sorted in ascending order, in a single pass
+// that moves the entries on the shorter side of the removed ones.
+func (s *Stream) delete(indices []int) {
+	first, last := indices[0], indices[len(indices)-1]
+	if last < len(s.entries)-first {
+		w, j := last+1, len(indices)-1
+		for r := last; r >= 0; r-- {
+			if j >= 0 && indices[j] == r {
+				j--
+				continue
+			}
+			w--
+			s.entries[w] = s.entries[r]
+		}
+		clear(s.entries[:w])
+		s.entries = s.entries[w:]
+		return
+	}
+	w, j := first, 0
+	for r := first; r < len(s.entries); r++ {
+		if j < len(indices) && indices[j] == r {
+			j++
+			continue
+		}
+		s.entries[w] = s.entries[r]
+		w++
+	}
+	clear(s.entries[w:])
+	s.entries = s.entries[:w]
+}
+
+// XAdd appends an entry with the given field/value pairs to the stream stored at key.
+// Returns the ID of the new entry, or false when NoMkStream is set and the key does not exist.
+func (d *Datastore) XAdd(key string, opts XAddOptions, fields []string) (StreamID, bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if s == nil && opts.NoMkStream {
+		return StreamID{}, false, nil
+	}
+	last := StreamID{}
+	if s != nil {
+		last = s.lastID
+	}
+	id := opts.ID
+	switch {
+	case opts.AutoID:
+		id = StreamID{Ms: uint64(d.nowMillis())}
+		if id.Compare(last) <= 0 {
+			var ok bool
+			if id, ok = last.Next(); !ok {
+				return StreamID{}, false, ErrStreamExhausted
+			}
+		}
+	case opts.AutoSeq:
+		if id.Ms < last.Ms {
+			return StreamID{}, false, ErrStreamIDTooSmall
+		}
+		if id.Ms == last.Ms {
+			if last.Seq == math.MaxUint64 {
+				return StreamID{}, false, ErrStreamIDTooSmall
+			}
+			id.Seq = last.Seq + 1
+		}
+	default:
+		if id == (StreamID{}) {
+			return StreamID{}, false, ErrStreamIDZero
+		}
+		if id.Compare(last) <= 0 {
+			return StreamID{}, false, ErrStreamIDTooSmall
+		}
+	}
+	if s == nil {
+		s = NewStream()
+		d.store(key, newEntry(s, -1))
+	}
+	s.entries = append(s.entries, StreamEntry{ID: id, Fields: fields})
+	s.lastID = id
+	s.entriesAdded++
+	d.notify(EventStream, "xadd", key)
+	if opts.Trim != nil && s.trim(*opts.Trim) > 0 {
+		d.notify(EventStream, "xtrim", key)
+	}
+	return id, true, nil
+}
+
+// XTrim trims the stream stored at key and returns the number of removed entries.
+func (d *Datastore) XTrim(key string, trim StreamTrim) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getStream(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+	removed := s.trim(trim)
+	if removed > 0 {
+		d.notify(EventStream, "xtrim", key)
+	}
+	return removed, nil
+}
+
+// XDel removes the entries with the given IDs from the stream stored at key.
+// Returns the number of removed entries.
+func (d *Datastore) XDel(key string, ids ...StreamID) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getStream(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+	indices := make([]int, 0, min(len(ids), len(s.entries)))
+	for _, id := range ids {
+		i := s.search(id)
+		if i < len(s.entries) && s.entries[i].ID == id {
+			indices = append(indices, i)
+			if id.Compare(s.maxDeletedID) > 0 {
+				s.maxDeletedID = id
+			}
+		}
+	}
+	if len(indices) == 0 {
+		return 0, nil
+	}
+	slices.Sort(indices)
+	indices = slices.Compact(indices)
+	s.delete(indices)
+	d.notify(EventStream, "xdel", key)
+	return int64(len(indices)), nil
+
+}
+
+// XLen returns the number of entries in the stream stored at key.
+func (d *Datastore) XLen(key string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getStream(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+	return int64(s.Len()), nil
+}
+
+// XRange returns up to count entries of the stream stored at key with IDs between start and end
+// (inclusive), in descending order when rev is set. A count of zero or less returns all of them.
+func (d *Datastore) XRange(key string, start, end StreamID, count int64, rev bool) ([]StreamEntry, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return []StreamEntry{}, nil
+	}
+	return s.Range(start, end, count, rev), nil
+}
+
+// XLastID returns the ID of the last entry added to the stream stored at key,
+// or the 0-0 ID if the key does not exist.
+func (d *Datastore) XLastID(key string) (StreamID, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getStream(key)
+	if err != nil || s == nil {
+		return StreamID{}, err
+	}
+	return s.lastID, nil
+}
+
+// XRead returns up to count entries with IDs greater than the corresponding ID from each of
+// the streams stored at keys. Streams without new entries are left out of the result.
+func (d *Datastore) XRead(keys []string, ids []StreamID, count int64) ([]StreamReadResult, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	ret := make([]StreamReadResult, 0)
+	for i, key := range keys {
+		s, err := d.getStream(key)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			continue
+		}
+		start, ok := ids[i].Next()
+		if !ok {
+			continue
+		}
+		if entries := s.Range(start, MaxStreamID, count, false); len(entries) > 0 {
+			ret = append(ret, StreamReadResult{Key: key, Entries: entries})
+		}
+	}
+	return ret, nil
+}
+
+// getStream returns the stream stored at key, nil if the key does not exist or
+// ErrWrongType if the key holds another kind of value. The caller must hold d.mu.
+func (d *Datastore) getStream(key string) (*Stream, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	s, ok := e.Value.(*Stream)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return s, nil
+}
@@ -0,0 +1,803 @@
+package datastore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// invalidEntriesRead marks a consumer group whose entries-read counter is unknown.
+const invalidEntriesRead = -1
+
+var (
+	// ErrBusyGroup is returned when creating a consumer group that already exists.
+	ErrBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+	// ErrGroupNoStream is returned by XGROUP subcommands when the stream does not exist.
+	ErrGroupNoStream = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
+// NoGroupError is returned when a consumer group does not exist.
+type NoGroupError struct {
+	Key, Group string
+}
+
+// ConsumerGroup tracks the entries delivered to the consumers of a stream.
+type ConsumerGroup struct {
+	lastID      StreamID
+	entriesRead int64
+	// pel is the pending entries list: delivered entries not acknowledged yet.
+	pel       *pendingList
+	consumers map[string]*Consumer
+}
+
+// Consumer is a named member of a consumer group.
+type Consumer struct {
+	name       string
+	seenTime   int64
+	activeTime int64
+	// pel holds the entries of the group's pending entries list delivered to the consumer.
+	pel *pendingList
+}
+
+// PendingEntry is an entry delivered to a consumer that has not been acknowledged yet.
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  int64
+	DeliveryCount int64
+}
+
+// pendingList is a pending entries list, indexed by ID and kept ordered by ID so that
+// it can be read from any ID without sorting it.
+type pendingList struct {
+	entries map[StreamID]*PendingEntry
+	// order holds the IDs of the entries encoded by pendingKey, all with a score of 0.
+	order *skiplist
+}
+
+// PendingSummary is the summary form of XPENDING.
+type PendingSummary struct {
+	Count    int64
+	Min, Max StreamID
+	// Consumers maps consumer names with pending entries to their number of pending entries.
+	Consumers map[string]int64
+}
+
+// XClaimOptions are the optional arguments of XCLAIM.
+type XClaimOptions struct {
+	// DeliveryTime is the unix time in millis to set as the last delivery time. Negative
+	// values and times in the future are replaced by the current time.
+	DeliveryTime int64
+	// RetryCount sets the delivery counter, or -1 to increment it.
+	RetryCount int64
+	// Force creates the pending entry if it is not pending in the group but exists in the stream.
+	Force bool
+	// JustID only claims the entries without incrementing the delivery counter.
+	JustID bool
+	// LastID updates the last delivered ID of the group if it is greater.
+	LastID *StreamID
+}
+
+// StreamInfo is the reply of XINFO STREAM.
+type StreamInfo struct {
+	Length         int64
+	LastID         StreamID
+	MaxDeletedID   StreamID
+	EntriesAdded   int64
+	FirstID        StreamID
+	Groups         int64
+	First, Last    *StreamEntry
+	RadixTreeKeys  int64
+	RadixTreeNodes int64
+}
+
+// GroupInfo is an element of the XINFO GROUPS reply.
+type GroupInfo struct {
+	Name            string
+	Consumers       int64
+	Pending         int64
+	LastDeliveredID StreamID
+	// EntriesRead and Lag are -1 when unknown.
+	EntriesRead int64
+	Lag         int64
+}
+
+// ConsumerInfo is an element of the XINFO CONSUMERS reply.
+type ConsumerInfo struct {
+	Name     string
+	Pending  int64
+	Idle     int64
+	Inactive int64
+}
+
+func (e NoGroupError) Error() string {
+	return fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s'", e.Key, e.Group)
+}
+
+func newConsumerGroup(id StreamID, entriesRead int64) *ConsumerGroup {
+	return &ConsumerGroup{
+		lastID:      id,
+		entriesRead: entriesRead,
+		pel:         newPendingList(),
+		consumers:   make(map[string]*Consumer),
+	}
+}
+
+func newPendingList() *pendingList {
+	return &pendingList{entries: make(map[StreamID]*PendingEntry), order: newSkiplist()}
+}
+
+// pendingKey encodes the ID as a member of the order of a pending entries list, so that
+// members compare lexicographically like the IDs they encode.
+func pendingKey(id StreamID) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], id.Ms)
+	binary.BigEndian.PutUint64(b[8:], id.Seq)
+	return string(b[:])
+}
+
+func (l *pendingList) len() int {
+	return len(l.entries)
+}
+
+func (l *pendingList) get(id StreamID) (*PendingEntry, bool) {
+	p, ok := l.entries[id]
+	return p, ok
+}
+
+// add adds the entry, which must not be in the list already.
+func (l *pendingList) add(p *PendingEntry) {
+	l.entries[p.ID] = p
+	l.order.insert(0, pendingKey(p.ID))
+}
+
+// remove removes the entry with the ID. Returns true if it was in the list.
+func (l *pendingList) remove(id StreamID) bool {
+	if _, ok := l.entries[id]; !ok {
+		return false
+	}
+	delete(l.entries, id)
+	l.order.delete(0, pendingKey(id))
+	return true
+}
+
+// from returns the entries with an ID greater than or equal to start, in ID order. The entry
+// being visited may be removed from the list meanwhile.
+func (l *pendingList) from(start StreamID) iter.Seq[*PendingEntry] {
+	return func(yield func(*PendingEntry) bool) {
+		x := l.order.firstInLexRange(LexRange{Min: LexBound{Value: pendingKey(start)}, Max: LexBound{Inf: 1}})
+		for x != nil {
+			next := x.level[0].forward
+			if !yield(l.entries[pendingID(x.member)]) {
+				return
+			}
+			x = next
+		}
+	}
+}
+
+// bounds returns the smallest and greatest IDs of the non-empty list.
+func (l *pendingList) bounds() (StreamID, StreamID) {
+	return pendingID(l.order.header.level[0].forward.member), pendingID(l.order.tail.member)
+}
+
+// pendingID decodes an ID encoded by pendingKey.
+func pendingID(key string) StreamID {
+	return StreamID{Ms: binary.BigEndian.Uint64([]byte(key[:8])), Seq: binary.BigEndian.Uint64([]byte(key[8:]))}
+}
+
+// clone returns a copy of the group, whose consumers share the pending entries of the copy.
+func (g *ConsumerGroup) clone() *ConsumerGroup {
+	c := newConsumerGroup(g.lastID, g.entriesRead)
+	for p := range g.pel.from(StreamID{}) {
+		pending := *p
+		c.pel.add(&pending)
+	}
+	for name, consumer := range g.consumers {
+		cc := *consumer
+		cc.pel = newPendingList()
+		for p := range consumer.pel.from(StreamID{}) {
+			pending, _ := c.pel.get(p.ID)
+			cc.pel.add(pending)
+		}
+		c.consumers[name] = &cc
+	}
+	return c
+}
+
+// consumer returns the named consumer, creating it if needed.
+func (g *ConsumerGroup) consumer(name string, now int64) *Consumer {
+	c, ok := g.consumers[name]
+	if !ok {
+		c = &Consumer{name: name, seenTime: now, activeTime: -1, pel: newPendingList()}
+		g.consumers[name] = c
+	}
+	return c
+}
+
+// seen returns the named consumer, creating it if needed, and updates its seen time.
+func (g *ConsumerGroup) seen(name string, now int64) *Consumer {
+	c := g.consumer(name, now)
+	c.seenTime = now
+	return c
+}
+
+// assign moves the pending entry to the consumer.
+func (g *ConsumerGroup) assign(p *PendingEntry, c *Consumer) {
+	if prev, ok := g.consumers[p.Consumer]; ok {
+		prev.pel.remove(p.ID)
+	}
+	p.Consumer = c.name
+	c.pel.add(p)
+}
+
+// ack removes the entry from the pending entries list. Returns true if it was pending.
+func (g *ConsumerGroup) ack(id StreamID) bool {
+	p, ok := g.pel.get(id)
+	if !ok {
+		return false
+	}
+	if c, ok := g.consumers[p.Consumer]; ok {
+		c.pel.remove(id)
+	}
+	g.pel.remove(id)
+	return true
+}
+
+// entry returns the stream entry with the given ID.
+func (s *Stream) entry(id StreamID) (StreamEntry, bool) {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+func (s *Stream) firstID() StreamID {
+	if len(s.entries) == 0 {
+		return StreamID{}
+	}
+	return s.entries[0].ID
+}
+
+// hasTombstonesAfter reports whether entries with an ID greater than or equal to id have been deleted.
+func (s *Stream) hasTombstonesAfter(id StreamID) bool {
+	if len(s.entries) == 0 || s.maxDeletedID == (StreamID{}) {
+		return false
+	}
+	return s.maxDeletedID.Compare(id) >= 0 && s.maxDeletedID.Compare(s.lastID) <= 0
+}
+
+// estimateEntriesRead estimates the number of entries added to the stream up to and including id.
+// Returns invalidEntriesRead when it cannot be known because of deletions.
+func (s *Stream) estimateEntriesRead(id StreamID) int64 {
+	added := int64(s.entriesAdded)
+	if added == 0 {
+		return 0
+	}
+	if len(s.entries) == 0 && id.Compare(s.lastID) <= 0 {
+		return added
+	}
+	switch c := id.Compare(s.lastID); {
+	case c == 0:
+		return added
+	case c > 0:
+		return invalidEntriesRead
+	}
+	first := s.firstID()
+	if s.maxDeletedID == (StreamID{}) || s.maxDeletedID.Compare(first) < 0 {
+		switch id.Compare(first) {
+		case -1:
+			return added - int64(len(s.entries))
+		case 0:
+			return added - int64(len(s.entries)) + 1
+		}
+	}
+	return invalidEntriesRead
+}
+
+// lag returns the number of entries not yet delivered to the group, or -1 if it is unknown.
+func (s *Stream) lag(g *ConsumerGroup) int64 {
+	added := int64(s.entriesAdded)
+	if added == 0 {
+		return 0
+	}
+	if g.entriesRead != invalidEntriesRead && !s.hasTombstonesAfter(g.lastID) {
+		return added - g.entriesRead
+	}
+	read := s.estimateEntriesRead(g.lastID)
+	if read == invalidEntriesRead {
+		return -1
+	}
+	return added - read
+}
+
+// XGroupCreate creates a consumer group for the stream stored at key, starting after id,
+// or after the last entry of the stream when useLast is set. A negative entriesRead
+// leaves the entries-read counter of the group to be estimated.
+func (d *Datastore) XGroupCreate(key, group string, id StreamID, useLast, mkStream bool, entriesRead int64) error {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		if !mkStream {
+			return ErrGroupNoStream
+		}
+		s = NewStream()
+		d.store(key, newEntry(s, -1))
+	}
+	if s.groups == nil {
+		s.groups = make(map[string]*ConsumerGroup)
+	}
+	if _, ok := s.groups[group]; ok {
+		return ErrBusyGroup
+	}
+	if useLast {
+		id = s.lastID
+	}
+	if entriesRead < 0 {
+		entriesRead = invalidEntriesRead
+	}
+	s.groups[group] = newConsumerGroup(id, entriesRead)
+	d.notify(EventStream, "xgroup-create", key)
+	return nil
+}
+
+// XGroupSetID sets the last delivered ID of the consumer group.
+func (d *Datastore) XGroupSetID(key, group string, id StreamID, useLast bool, entriesRead int64) error {
+	d.mu.Lock()
+	defer d.unlock()
+	s, g, err := d.getXGroup(key, group)
+	if err != nil {
+		return err
+	}
+	if useLast {
+		id = s.lastID
+	}
+	if entriesRead < 0 {
+		entriesRead = invalidEntriesRead
+	}
+	g.lastID = id
+	g.entriesRead = entriesRead
+	d.notify(EventStream, "xgroup-setid", key)
+	return nil
+}
+
+// XGroupDestroy removes the consumer group. Returns true if it existed.
+func (d *Datastore) XGroupDestroy(key, group string) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return false, err
+	}
+	if s == nil {
+		return false, ErrGroupNoStream
+	}
+	if _, ok := s.groups[group]; !ok {
+		return false, nil
+	}
+	delete(s.groups, group)
+	d.notify(EventStream, "xgroup-destroy", key)
+	return true, nil
+}
+
+// XGroupCreateConsumer adds the consumer to the group. Returns true if it was created.
+func (d *Datastore) XGroupCreateConsumer(key, group, consumer string) (bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	_, g, err := d.getXGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := g.consumers[consumer]; ok {
+		return false, nil
+	}
+	g.consumer(consumer, d.nowMillis())
+	d.notify(EventStream, "xgroup-createconsumer", key)
+	return true, nil
+}
+
+// XGroupDelConsumer removes the consumer and its pending entries from the group.
+// Returns the number of pending entries the consumer had.
+func (d *Datastore) XGroupDelConsumer(key, group, consumer string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	_, g, err := d.getXGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := g.consumers[consumer]
+	if !ok {
+		return 0, nil
+	}
+	pending := int64(c.pel.len())
+	for p := range c.pel.from(StreamID{}) {
+		g.pel.remove(p.ID)
+	}
+	delete(g.consumers, consumer)
+	d.notify(EventStream, "xgroup-delconsumer", key)
+	return pending, nil
+
+}
+
+// XReadGroup reads entries from the streams stored at keys on behalf of the consumer of the group.
+// A nil ID reads entries never delivered to the group and adds them to the pending entries list,
+// unless noAck is set. Any other ID reads the pending entries of the consumer with a greater ID,
+// counting them as delivered again; entries that have been deleted from the stream are returned
+// with nil fields.
+func (d *Datastore) XReadGroup(group, consumer string, keys []string, ids []*StreamID, count int64, noAck bool) ([]StreamReadResult, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	groups := make([]*ConsumerGroup, len(keys))
+	for i, key := range keys {
+		_, g, err := d.getGroup(key, group)
+		if err != nil {
+			return nil, err
+		}
+		groups[i] = g
+	}
+	now := d.nowMillis()
+	ret := make([]StreamReadResult, 0)
+	for i, key := range keys {
+		s, _ := d.getStream(key)
+		g := groups[i]
+		c := g.seen(consumer, now)
+		if ids[i] != nil {
+			entries := make([]StreamEntry, 0)
+			for p := range c.pel.from(*ids[i]) {
+				if p.ID == *ids[i] {
+					continue
+				}
+				if count > 0 && int64(len(entries)) >= count {
+					break
+				}
+				e, ok := s.entry(p.ID)
+				if ok {
+					p.DeliveryTime = now
+					p.DeliveryCount++
+				} else {
+					e = StreamEntry{ID: p.ID}
+				}
+				entries = append(entries, e)
+			}
+			ret = append(ret, StreamReadResult{Key: key, Entries: entries})
+			continue
+		}
+		start, ok := g.lastID.Next()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, MaxStreamID, count, false)
+		if len(entries) == 0 {
+			continue
+		}
+		c.activeTime = now
+		for _, e := range entries {
+			if g.entriesRead != invalidEntriesRead && !s.hasTombstonesAfter(e.ID) {
+				g.entriesRead++
+			} else {
+				g.entriesRead = s.estimateEntriesRead(e.ID)
+			}
+			g.lastID = e.ID
+			if noAck {
+				continue
+			}
+			p, ok := g.pel.get(e.ID)
+			if !ok {
+				p = &PendingEntry{ID: e.ID}
+				g.pel.add(p)
+			}
+			g.assign(p, c)
+			p.DeliveryTime = now
+			p.DeliveryCount = 1
+		}
+		ret = append(ret, StreamReadResult{Key: key, Entries: entries})
+	}
+	return ret, nil
+}
+
+// XAck acknowledges the entries, removing them from the pending entries list of the group.
+// Returns the number of acknowledged entries.
+func (d *Datastore) XAck(key, group string, ids ...StreamID) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	_, g, err := d.getGroup(key, group)
+	if err != nil {
+		var noGroup NoGroupError
+		if errors.As(err, &noGroup) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var acked int64
+	for _, id := range ids {
+		if g.ack(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		// Acknowledging raises no event, but modifies the stream.
+		d.SignalModified(key)
+	}
+	return acked, nil
+}
+
+// XPendingSummary returns the summary of the pending entries of the group.
+func (d *Datastore) XPendingSummary(key, group string) (PendingSummary, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	_, g, err := d.getGroup(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+	summary := PendingSummary{Count: int64(g.pel.len()), Consumers: make(map[string]int64)}
+	if g.pel.len() > 0 {
+		summary.Min, summary.Max = g.pel.bounds()
+	}
+	for _, c := range g.consumers {
+		if c.pel.len() > 0 {
+			summary.Consumers[c.name] = int64(c.pel.len())
+		}
+	}
+	return summary, nil
+}
+
+// XPending returns up to count pending entries of the group with IDs between start and end
+// (inclusive) that have been idle for at least minIdle millis. When consumer is not empty,
+// only the entries pending for that consumer are returned.
+func (d *Datastore) XPending(key, group string, minIdle int64, start, end StreamID, count int64, consumer string) ([]PendingEntry, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	_, g, err := d.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	pel := g.pel
+	if consumer != "" {
+		c, ok := g.consumers[consumer]
+		if !ok {
+			return []PendingEntry{}, nil
+		}
+		pel = c.pel
+	}
+	now := d.nowMillis()
+	ret := make([]PendingEntry, 0)
+	for p := range pel.from(start) {
+		if int64(len(ret)) >= count || p.ID.Compare(end) > 0 {
+			break
+		}
+		if now-p.DeliveryTime < minIdle {
+			continue
+		}
+		ret = append(ret, *p)
+	}
+	return ret, nil
+}
+
+// XClaim transfers the ownership of the pending entries that have been idle for at least
+// minIdle millis to the consumer. Entries no longer present in the stream are removed
+// from the pending entries list instead. Returns the claimed entries.
+func (d *Datastore) XClaim(key, group, consumer string, minIdle int64, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, g, err := d.getGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	now := d.nowMillis()
+	if opts.LastID != nil && opts.LastID.Compare(g.lastID) > 0 {
+		g.lastID = *opts.LastID
+	}
+	deliveryTime := opts.DeliveryTime
+	if deliveryTime < 0 || deliveryTime > now {
+		deliveryTime = now
+	}
+	var c *Consumer
+	modified := false
+	ret := make([]StreamEntry, 0)
+	for _, id := range ids {
+		p, ok := g.pel.get(id)
+		e, exists := s.entry(id)
+		if !ok {
+			if !opts.Force || !exists {
+				continue
+			}
+			p = &PendingEntry{ID: id, DeliveryTime: now}
+			g.pel.add(p)
+			modified = true
+		}
+		if !exists {
+			modified = g.ack(id) || modified
+			continue
+		}
+		if minIdle > 0 && now-p.DeliveryTime < minIdle {
+			continue
+		}
+		if c == nil {
+			c = g.seen(consumer, now)
+		}
+		g.assign(p, c)
+		p.DeliveryTime = deliveryTime
+		if opts.RetryCount >= 0 {
+			p.DeliveryCount = opts.RetryCount
+		} else if !opts.JustID {
+			p.DeliveryCount++
+		}
+		c.activeTime = now
+		ret = append(ret, e)
+	}
+	if modified || len(ret) > 0 {
+		d.SignalModified(key)
+	}
+	return ret, nil
+}
+
+// XAutoClaim scans the pending entries of the group starting at start and claims up to count
+// of those that have been idle for at least minIdle millis for the consumer. Returns the ID to
+// resume the scan from (0-0 when it is complete), the claimed entries and the IDs of the pending
+// entries that were removed because they no longer exist in the stream.
+func (d *Datastore) XAutoClaim(key, group, consumer string, minIdle int64, start StreamID, count int64, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	s, g, err := d.getGroup(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	now := d.nowMillis()
+	var c *Consumer
+	claimed := make([]StreamEntry, 0)
+	deleted := make([]StreamID, 0)
+	attempts := count * 10
+	next := StreamID{}
+	for p := range g.pel.from(start) {
+		if attempts == 0 || int64(len(claimed)) >= count {
+			next = p.ID
+			break
+		}
+		attempts--
+		e, exists := s.entry(p.ID)
+		if !exists {
+			g.ack(p.ID)
+			deleted = append(deleted, p.ID)
+			continue
+		}
+		if now-p.DeliveryTime < minIdle {
+			continue
+		}
+		if c == nil {
+			c = g.seen(consumer, now)
+		}
+		g.assign(p, c)
+		p.DeliveryTime = now
+		if !justID {
+			p.DeliveryCount++
+		}
+		c.activeTime = now
+		claimed = append(claimed, e)
+	}
+	if len(claimed) > 0 || len(deleted) > 0 {
+		d.SignalModified(key)
+	}
+	return next, claimed, deleted, nil
+}
+
+// XInfoStream returns general information about the stream stored at key.
+func (d *Datastore) XInfoStream(key string) (StreamInfo, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	if s == nil {
+		return StreamInfo{}, ErrNoSuchKey
+	}
+	nodes := int64((len(s.entries) + streamNodeMaxEntries - 1) / streamNodeMaxEntries)
+	info := StreamInfo{
+		Length:         int64(len(s.entries)),
+		LastID:         s.lastID,
+		MaxDeletedID:   s.maxDeletedID,
+		EntriesAdded:   int64(s.entriesAdded),
+		FirstID:        s.firstID(),
+		Groups:         int64(len(s.groups)),
+		RadixTreeKeys:  nodes,
+		RadixTreeNodes: nodes + 1,
+	}
+	if len(s.entries) > 0 {
+		// Copy the entries, whose slots are cleared when they are removed from the stream.
+		first, last := s.entries[0], s.entries[len(s.entries)-1]
+		info.First, info.Last = &first, &last
+	}
+	return info, nil
+}
+
+// XInfoGroups returns information about the consumer groups of the stream stored at key, sorted by name.
+func (d *Datastore) XInfoGroups(key string) ([]GroupInfo, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrNoSuchKey
+	}
+	ret := make([]GroupInfo, 0, len(s.groups))
+	for _, name := range slices.Sorted(maps.Keys(s.groups)) {
+		g := s.groups[name]
+		ret = append(ret, GroupInfo{
+			Name:            name,
+			Consumers:       int64(len(g.consumers)),
+			Pending:         int64(g.pel.len()),
+			LastDeliveredID: g.lastID,
+			EntriesRead:     g.entriesRead,
+			Lag:             s.lag(g),
+		})
+	}
+	return ret, nil
+}
+
+// XInfoConsumers returns information about the consumers of the group, sorted by name.
+func (d *Datastore) XInfoConsumers(key, group string) ([]ConsumerInfo, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	s, err := d.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrNoSuchKey
+	}
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, NoGroupError{Key: key, Group: group}
+	}
+	now := d.nowMillis()
+	ret := make([]ConsumerInfo, 0, len(g.consumers))
+	for _, name := range slices.Sorted(maps.Keys(g.consumers)) {
+		c := g.consumers[name]
+		inactive := int64(-1)
+		if c.activeTime >= 0 {
+			inactive = now - c.activeTime
+		}
+		ret = append(ret, ConsumerInfo{Name: name, Pending: int64(c.pel.len()), Idle: now - c.seenTime, Inactive: inactive})
+	}
+	return ret, nil
+}
+
+// getGroup returns the stream stored at key and its consumer group, or a NoGroupError
+// if either does not exist. The caller must hold d.mu.
+func (d *Datastore) getGroup(key, group string) (*Stream, *ConsumerGroup, error) {
+	s, err := d.getStream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil {
+		return nil, nil, NoGroupError{Key: key, Group: group}
+	}
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, nil, NoGroupError{Key: key, Group: group}
+	}
+	return s, g, nil
+}
+
+// getXGroup is like getGroup but returns ErrGroupNoStream when the stream does not exist,
+// as the XGROUP subcommands do. The caller must hold d.mu.
+func (d *Datastore) getXGroup(key, group string) (*Stream, *ConsumerGroup, error) {
+	s, err := d.getStream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil {
+		return nil, nil, ErrGroupNoStream
+	}
+	return d.getGroup(key, group)
+}
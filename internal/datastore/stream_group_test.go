@@ -0,0 +1,140 @@
+package datastore
+
+import (
+	"testing"
+)
+
+func addStreamEntries(t *testing.T, ds *Datastore, key string, ids ...StreamID) {
+	t.Helper()
+	for _, id := range ids {
+		if _, _, err := ds.XAdd(key, XAddOptions{ID: id}, []string{"f", id.String()}); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+}
+
+func TestXReadGroupPending(t *testing.T) {
+	ds := NewDatastore()
+	addStreamEntries(t, ds, "s", StreamID{Ms: 1}, StreamID{Ms: 2}, StreamID{Ms: 3})
+	if err := ds.XGroupCreate("s", "g", StreamID{}, false, false, -1); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if err := ds.XGroupCreate("s", "g", StreamID{}, false, false, -1); err != ErrBusyGroup {
+		t.Errorf("Expected %v, got %v", ErrBusyGroup, err)
+	}
+	res, _ := ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{nil}, 2, false)
+	if len(res) != 1 || len(res[0].Entries) != 2 {
+		t.Fatalf("Expected 2 new entries, got %v", res)
+	}
+	res, _ = ds.XReadGroup("g", "bob", []string{"s"}, []*StreamID{nil}, 0, false)
+	if len(res[0].Entries) != 1 || res[0].Entries[0].ID != (StreamID{Ms: 3}) {
+		t.Fatalf("Expected the last entry to be delivered to bob, got %v", res)
+	}
+	summary, _ := ds.XPendingSummary("s", "g")
+	if summary.Count != 3 || summary.Consumers["alice"] != 2 || summary.Consumers["bob"] != 1 {
+		t.Errorf("Unexpected pending summary %+v", summary)
+	}
+
+	ds.XDel("s", StreamID{Ms: 1})
+	res, _ = ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{{}}, 0, false)
+	entries := res[0].Entries
+	if len(entries) != 2 || entries[0].Fields != nil || entries[1].Fields == nil {
+		t.Errorf("Expected the deleted entry to have nil fields, got %v", entries)
+	}
+	pending, _ := ds.XPending("s", "g", 0, StreamID{}, MaxStreamID, 10, "alice")
+	if len(pending) != 2 || pending[0].DeliveryCount != 1 || pending[1].DeliveryCount != 2 {
+		t.Errorf("Expected only the existing entry to be delivered again, got %+v", pending)
+	}
+
+	if n, _ := ds.XAck("s", "g", StreamID{Ms: 2}, StreamID{Ms: 2}, StreamID{Ms: 9}); n != 1 {
+		t.Errorf("Expected 1 acknowledged entry, got %d", n)
+	}
+	if n, _ := ds.XGroupDelConsumer("s", "g", "bob"); n != 1 {
+		t.Errorf("Expected bob to have 1 pending entry, got %d", n)
+	}
+	if summary, _ := ds.XPendingSummary("s", "g"); summary.Count != 1 {
+		t.Errorf("Expected 1 pending entry, got %d", summary.Count)
+	}
+	if _, err := ds.XReadGroup("nope", "alice", []string{"s"}, []*StreamID{nil}, 0, false); err != (NoGroupError{Key: "s", Group: "nope"}) {
+		t.Errorf("Expected a missing group error, got %v", err)
+	}
+}
+
+func TestXClaim(t *testing.T) {
+	ds := NewDatastore()
+	addStreamEntries(t, ds, "s", StreamID{Ms: 1}, StreamID{Ms: 2}, StreamID{Ms: 3})
+	ds.XGroupCreate("s", "g", StreamID{}, false, false, -1)
+	ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{nil}, 0, false)
+
+	ids := []StreamID{{Ms: 1}, {Ms: 2}}
+	if claimed, _ := ds.XClaim("s", "g", "bob", 60000, ids, XClaimOptions{DeliveryTime: -1, RetryCount: -1}); len(claimed) != 0 {
+		t.Errorf("Expected no entries idle long enough, got %v", claimed)
+	}
+	claimed, _ := ds.XClaim("s", "g", "bob", 0, ids, XClaimOptions{DeliveryTime: -1, RetryCount: 5})
+	if len(claimed) != 2 {
+		t.Fatalf("Expected 2 claimed entries, got %v", claimed)
+	}
+	pending, _ := ds.XPending("s", "g", 0, StreamID{}, MaxStreamID, 10, "bob")
+	if len(pending) != 2 || pending[0].DeliveryCount != 5 {
+		t.Errorf("Unexpected pending entries %+v", pending)
+	}
+
+	ds.XDel("s", StreamID{Ms: 3})
+	next, claimed, deleted, _ := ds.XAutoClaim("s", "g", "carol", 0, StreamID{}, 1, false)
+	if next != (StreamID{Ms: 2}) || len(claimed) != 1 || len(deleted) != 0 {
+		t.Errorf("Unexpected XAUTOCLAIM result %v %v %v", next, claimed, deleted)
+	}
+	next, claimed, deleted, _ = ds.XAutoClaim("s", "g", "carol", 0, next, 10, true)
+	if next != (StreamID{}) || len(claimed) != 1 || len(deleted) != 1 || deleted[0] != (StreamID{Ms: 3}) {
+		t.Errorf("Unexpected XAUTOCLAIM result %v %v %v", next, claimed, deleted)
+	}
+	if summary, _ := ds.XPendingSummary("s", "g"); summary.Count != 2 || summary.Consumers["carol"] != 2 {
+		t.Errorf("Unexpected pending summary %+v", summary)
+	}
+}
+
+func TestPendingEntriesOrder(t *testing.T) {
+	ds := NewDatastore()
+	addStreamEntries(t, ds, "s", StreamID{Ms: 1}, StreamID{Ms: 2}, StreamID{Ms: 256}, StreamID{Ms: 257, Seq: 1})
+	ds.XGroupCreate("s", "g", StreamID{Ms: 2}, false, false, -1)
+	ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{nil}, 0, false)
+	ds.XClaim("s", "g", "bob", 0, []StreamID{{Ms: 1}}, XClaimOptions{DeliveryTime: -1, RetryCount: -1, Force: true})
+	ds.XClaim("s", "g", "alice", 0, []StreamID{{Ms: 2}}, XClaimOptions{DeliveryTime: -1, RetryCount: -1, Force: true})
+
+	summary, _ := ds.XPendingSummary("s", "g")
+	if summary.Count != 4 || summary.Min != (StreamID{Ms: 1}) || summary.Max != (StreamID{Ms: 257, Seq: 1}) {
+		t.Errorf("Unexpected pending summary %+v", summary)
+	}
+	pending, _ := ds.XPending("s", "g", 0, StreamID{Ms: 2}, MaxStreamID, 10, "alice")
+	if len(pending) != 3 || pending[0].ID != (StreamID{Ms: 2}) || pending[1].ID != (StreamID{Ms: 256}) || pending[2].ID != (StreamID{Ms: 257, Seq: 1}) {
+		t.Errorf("Expected alice's pending entries in ID order, got %+v", pending)
+	}
+	ds.XAck("s", "g", StreamID{Ms: 256})
+	read, _ := ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{{Ms: 2}}, 0, false)
+	if len(read) != 1 || len(read[0].Entries) != 1 || read[0].Entries[0].ID != (StreamID{Ms: 257, Seq: 1}) {
+		t.Errorf("Expected the pending entries of alice after 2-0, got %v", read)
+	}
+}
+
+func TestXInfoGroupsLag(t *testing.T) {
+	ds := NewDatastore()
+	addStreamEntries(t, ds, "s", StreamID{Ms: 1}, StreamID{Ms: 2}, StreamID{Ms: 3}, StreamID{Ms: 4})
+	ds.XGroupCreate("s", "g", StreamID{}, false, false, -1)
+	ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{nil}, 1, false)
+
+	groups, _ := ds.XInfoGroups("s")
+	if groups[0].EntriesRead != 1 || groups[0].Lag != 3 {
+		t.Errorf("Expected 1 entry read and a lag of 3, got %+v", groups[0])
+	}
+	ds.XDel("s", StreamID{Ms: 3})
+	if groups, _ = ds.XInfoGroups("s"); groups[0].Lag != -1 {
+		t.Errorf("Expected an unknown lag after a deletion, got %d", groups[0].Lag)
+	}
+	ds.XReadGroup("g", "alice", []string{"s"}, []*StreamID{nil}, 0, false)
+	if groups, _ = ds.XInfoGroups("s"); groups[0].EntriesRead != 4 || groups[0].Lag != 0 {
+		t.Errorf("Expected the group to have caught up, got %+v", groups[0])
+	}
+	if _, err := ds.XInfoGroups("missing"); err != ErrNoSuchKey {
+		t.Errorf("Expected %v, got %v", ErrNoSuchKey, err)
+	}
+}
@@ -0,0 +1,111 @@
+package datastore
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestParseStreamID(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		expected StreamID
+		err      error
+	}{
+		"Full ID":          {in: "12-3", expected: StreamID{Ms: 12, Seq: 3}},
+		"Missing sequence": {in: "12", expected: StreamID{Ms: 12, Seq: 7}},
+		"Invalid ms":       {in: "a-1", err: ErrInvalidStreamID},
+		"Invalid sequence": {in: "1-a", err: ErrInvalidStreamID},
+		"Negative":         {in: "-1", err: ErrInvalidStreamID},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseStreamID(test.in, 7)
+			if err != test.err {
+				t.Errorf("Expected error %v, got %v", test.err, err)
+			}
+			if err == nil && got != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestXAddAutoID(t *testing.T) {
+	ds := NewDatastore()
+	first, _, err := ds.XAdd("key", XAddOptions{AutoID: true}, []string{"f", "v"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	second, _, _ := ds.XAdd("key", XAddOptions{AutoID: true}, []string{"f", "v"})
+	if second.Compare(first) <= 0 {
+		t.Errorf("Expected %v to be greater than %v", second, first)
+	}
+	future := StreamID{Ms: first.Ms + 100000}
+	ds.XAdd("key", XAddOptions{ID: future}, []string{"f", "v"})
+	third, _, _ := ds.XAdd("key", XAddOptions{AutoID: true}, []string{"f", "v"})
+	if third != (StreamID{Ms: future.Ms, Seq: 1}) {
+		t.Errorf("Expected the sequence to be incremented after a future ID, got %v", third)
+	}
+}
+
+func TestXTrimApproximate(t *testing.T) {
+	ds := NewDatastore()
+	for i := range 350 {
+		ds.XAdd("key", XAddOptions{ID: StreamID{Ms: uint64(i + 1)}}, []string{"f", fmt.Sprint(i)})
+	}
+	n, _ := ds.XTrim("key", StreamTrim{Strategy: StreamTrimMaxLen, Approx: true, MaxLen: 100})
+	if n != 200 {
+		t.Errorf("Expected 2 whole nodes to be removed, got %d", n)
+	}
+	n, _ = ds.XTrim("key", StreamTrim{Strategy: StreamTrimMinID, Approx: true, MinID: StreamID{Ms: 340}, Limit: 50})
+	if n != 0 {
+		t.Errorf("Expected a limit smaller than a node to prevent trimming, got %d", n)
+	}
+	n, _ = ds.XTrim("key", StreamTrim{Strategy: StreamTrimMinID, MinID: StreamID{Ms: 340}})
+	if n != 139 {
+		t.Errorf("Expected 139 entries to be removed, got %d", n)
+	}
+	if l, _ := ds.XLen("key"); l != 11 {
+		t.Errorf("Expected 11 entries, got %d", l)
+	}
+}
+
+func TestXDel(t *testing.T) {
+	ds := NewDatastore()
+	for i := range 10 {
+		ds.XAdd("key", XAddOptions{ID: StreamID{Ms: uint64(i + 1)}}, []string{"f", fmt.Sprint(i)})
+	}
+	ids := func(ms ...uint64) []StreamID {
+		ret := make([]StreamID, len(ms))
+		for i, m := range ms {
+			ret[i] = StreamID{Ms: m}
+		}
+		return ret
+	}
+	tests := []struct {
+		del      []StreamID
+		removed  int64
+		expected []StreamID
+	}{
+		{ids(2, 1, 2, 11), 2, ids(3, 4, 5, 6, 7, 8, 9, 10)},
+		{ids(4, 6), 2, ids(3, 5, 7, 8, 9, 10)},
+		{ids(9, 5, 10), 3, ids(3, 7, 8)},
+	}
+	for _, test := range tests {
+		if n, _ := ds.XDel("key", test.del...); n != test.removed {
+			t.Errorf("Expected %d entries to be removed by %v, got %d", test.removed, test.del, n)
+		}
+		entries, _ := ds.XRange("key", StreamID{}, MaxStreamID, 0, false)
+		got := make([]StreamID, len(entries))
+		for i, e := range entries {
+			got[i] = e.ID
+			if e.Fields[1] != fmt.Sprint(e.ID.Ms-1) {
+				t.Errorf("Unexpected fields %v for %v", e.Fields, e.ID)
+			}
+		}
+		if !slices.Equal(got, test.expected) {
+			t.Errorf("Expected %v after deleting %v, got %v", test.expected, test.del, got)
+		}
+	}
+}
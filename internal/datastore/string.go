@@ -0,0 +1,390 @@
+package datastore
+
+import (
+	"errors"
+	"math"
+)
+
+// maxStringLength mirrors the 512MB proto-max-bulk-len limit of Redis strings.
+const maxStringLength = 512 * 1024 * 1024
+
+var (
+	// ErrStringTooLong is returned when a write would grow a string past the maximum size.
+	ErrStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	// ErrLCSTooLarge is returned when LCS would need more memory than the maximum string size.
+	ErrLCSTooLarge = errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+	// ErrInvalidExpireTime is returned when an expiration time overflows.
+	ErrInvalidExpireTime = errors.New("invalid expire time")
+)
+
+// ExpiryMode selects how a write changes the expiration of a key.
+type ExpiryMode int
+
+const (
+	// ExpiryKeep leaves the expiration unchanged.
+	ExpiryKeep ExpiryMode = iota
+	// ExpiryPersist removes the expiration.
+	ExpiryPersist
+	// ExpiryAfter expires the key after Millis milliseconds.
+	ExpiryAfter
+	// ExpiryAt expires the key at the unix time Millis, in milliseconds.
+	ExpiryAt
+)
+
+// Expiry describes how a write changes the expiration of a key.
+type Expiry struct {
+	Mode   ExpiryMode
+	Millis int64
+}
+
+// at returns the expiration in unix millis for an entry currently expiring at current,
+// given the current time now.
+func (e Expiry) at(current, now int64) (int64, error) {
+	switch e.Mode {
+	case ExpiryPersist:
+		return -1, nil
+	case ExpiryAfter:
+		if e.Millis > math.MaxInt64-now {
+			return 0, ErrInvalidExpireTime
+		}
+		return now + e.Millis, nil
+	case ExpiryAt:
+		return e.Millis, nil
+	}
+	return current, nil
+}
+
+// SetCondition restricts SetWithOptions to missing or existing keys.
+type SetCondition int
+
+const (
+	// SetAlways sets the key whether it exists or not.
+	SetAlways SetCondition = iota
+	// SetIfMissing only sets keys that do not exist, like NX.
+	SetIfMissing
+	// SetIfExists only sets keys that already exist, like XX.
+	SetIfExists
+)
+
+// SetOptions controls how SetWithOptions writes a key.
+type SetOptions struct {
+	Condition SetCondition
+	// Expiry is applied to the key. ExpiryKeep keeps the expiration of an existing key, like KEEPTTL.
+	Expiry Expiry
+	// Get makes the write fail with ErrWrongType when the key holds something else than a string,
+	// as its previous value has to be returned.
+	Get bool
+}
+
+// LCSMatch is a range of a common subsequence, with inclusive bounds in both strings.
+type LCSMatch struct {
+	AStart, AEnd int64
+	BStart, BEnd int64
+}
+
+// Len returns the length of the match.
+func (m LCSMatch) Len() int64 {
+	return m.AEnd - m.AStart + 1
+}
+
+// SetWithOptions sets key to value when the condition of the options holds, updating its
+// expiration. The key is deleted when the expiration is in the past. Returns the previous
+// value, nil if the key did not exist or did not hold a string, and whether the key was set.
+func (d *Datastore) SetWithOptions(key, value string, opts SetOptions) (*string, bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	e := d.lookup(key)
+	old, err := d.readString(key)
+	if err != nil && opts.Get {
+		return nil, false, err
+	}
+	if (opts.Condition == SetIfMissing && e != nil) || (opts.Condition == SetIfExists && e == nil) {
+		return old, false, nil
+	}
+	current := int64(-1)
+	if e != nil {
+		current = e.Expiry
+	}
+	now := d.nowMillis()
+	at, err := opts.Expiry.at(current, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if at != -1 && at <= now {
+		d.remove(key)
+		d.notify(EventGeneric, "del", key)
+	} else {
+		d.store(key, newEntry(value, at))
+		d.notify(EventString, "set", key)
+		if opts.Expiry.Mode == ExpiryAfter || opts.Expiry.Mode == ExpiryAt {
+			d.notify(EventGeneric, "expire", key)
+		}
+	}
+	return old, true, nil
+}
+
+// Append appends value to the string stored at key, creating it if needed.
+// Returns the length of the string after the append.
+func (d *Datastore) Append(key, value string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	e, b, err := d.getBytes(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(b)+len(value) > maxStringLength {
+		return 0, ErrStringTooLong
+	}
+	if e == nil {
+		d.store(key, newEntry(value, -1))
+	} else {
+		e.Value = append(b, value...)
+	}
+	d.notify(EventString, "append", key)
+	return int64(len(b) + len(value)), nil
+}
+
+// StrLen returns the length of the string stored at key, 0 if it does not exist.
+func (d *Datastore) StrLen(key string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	b, _, err := d.readBytes(key)
+	return int64(len(b)), err
+}
+
+// GetRange returns the substring of the string stored at key between the inclusive offsets,
+// negative offsets counting from the end of the string.
+func (d *Datastore) GetRange(key string, start, end int64) (string, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	b, _, err := d.readBytes(key)
+	if err != nil {
+		return "", err
+	}
+	if start < 0 && end < 0 && start > end {
+		return "", nil
+	}
+	length := int64(len(b))
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, length-1)
+	if start > end || length == 0 {
+		return "", nil
+	}
+	return string(b[start : end+1]), nil
+}
+
+// SetRange overwrites the string stored at key from offset with value, padding it with zero
+// bytes as needed. Returns the length of the string after the write.
+func (d *Datastore) SetRange(key string, offset int64, value string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	e, b, err := d.getBytes(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 {
+		// Nothing is written, not even the padding.
+		return int64(len(b)), nil
+	}
+	if offset > maxStringLength-int64(len(value)) {
+		return 0, ErrStringTooLong
+	}
+	if e == nil {
+		e = newEntry([]byte{}, -1)
+		d.store(key, e)
+	}
+	if need := int(offset) + len(value); len(b) < need {
+		b = append(b, make([]byte, need-len(b))...)
+	}
+	copy(b[offset:], value)
+	e.Value = b
+	d.notify(EventString, "setrange", key)
+	return int64(len(b)), nil
+}
+
+// MGet returns the values of the keys, nil for missing keys and keys that do not hold a string.
+func (d *Datastore) MGet(keys ...string) []*string {
+	d.mu.RLock()
+	defer d.runlock()
+	ret := make([]*string, len(keys))
+	for i, key := range keys {
+		if b, ok, err := d.readBytes(key); ok && err == nil {
+			s := string(b)
+			ret[i] = &s
+		}
+	}
+	return ret
+}
+
+// MSet sets the key/value pairs at once, removing their expiration.
+func (d *Datastore) MSet(pairs ...string) {
+	d.mu.Lock()
+	defer d.unlock()
+	for i := 0; i+1 < len(pairs); i += 2 {
+		d.store(pairs[i], newEntry(pairs[i+1], -1))
+		d.notify(EventString, "set", pairs[i])
+	}
+}
+
+// MSetNX sets the key/value pairs at once only if none of the keys exists.
+// Returns true if the pairs were set.
+func (d *Datastore) MSetNX(pairs ...string) bool {
+	d.mu.Lock()
+	defer d.unlock()
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if d.lookup(pairs[i]) != nil {
+			return false
+		}
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		d.store(pairs[i], newEntry(pairs[i+1], -1))
+		d.notify(EventString, "set", pairs[i])
+	}
+	return true
+}
+
+// GetSet sets the key to value, removing its expiration, and returns the previous value
+// or nil if the key did not exist.
+func (d *Datastore) GetSet(key, value string) (*string, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	old, err := d.readString(key)
+	if err != nil {
+		return nil, err
+	}
+	d.store(key, newEntry(value, -1))
+	d.notify(EventString, "set", key)
+	return old, nil
+}
+
+// GetDel deletes the key and returns its value, or nil if it did not exist.
+func (d *Datastore) GetDel(key string) (*string, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	value, err := d.readString(key)
+	if value != nil {
+		d.remove(key)
+		d.notify(EventGeneric, "del", key)
+	}
+	return value, err
+}
+
+// GetEx returns the value of the key, or nil if it does not exist, changing its expiration.
+// The key is deleted when the new expiration is in the past.
+func (d *Datastore) GetEx(key string, expiry Expiry) (*string, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	value, err := d.readString(key)
+	if value == nil {
+		return nil, err
+	}
+	e := d.data[key]
+	now := d.nowMillis()
+	at, err := expiry.at(e.Expiry, now)
+	if err != nil {
+		return nil, err
+	}
+	if at != -1 && at <= now {
+		d.remove(key)
+		d.notify(EventGeneric, "del", key)
+	} else {
+		e.Expiry = at
+		switch expiry.Mode {
+		case ExpiryPersist:
+			d.notify(EventGeneric, "persist", key)
+		case ExpiryAfter, ExpiryAt:
+			d.notify(EventGeneric, "expire", key)
+		}
+	}
+	return value, nil
+
+}
+
+// LCS returns the longest common subsequence of the strings stored at key1 and key2, missing
+// keys counting as empty strings, and the ranges it is made of from the end of the strings.
+func (d *Datastore) LCS(key1, key2 string) (string, []LCSMatch, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	a, _, err := d.readBytes(key1)
+	if err != nil {
+		return "", nil, err
+	}
+	b, _, err := d.readBytes(key2)
+	if err != nil {
+		return "", nil, err
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[:i] and b[:j]. Its size in
+	// bytes is bounded like strings, dividing rather than multiplying so that it cannot overflow.
+	width := len(b) + 1
+	if len(a)+1 > maxStringLength/4/width {
+		return "", nil, ErrLCSTooLarge
+	}
+	lcs := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				lcs[i*width+j] = lcs[(i-1)*width+j-1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i-1)*width+j], lcs[i*width+j-1])
+			}
+		}
+	}
+	idx := lcs[len(a)*width+len(b)]
+	result := make([]byte, idx)
+	matches := make([]LCSMatch, 0)
+	alen := int64(len(a))
+	current := LCSMatch{AStart: alen}
+	// Walk the table back from the end, emitting the ranges of contiguous matches.
+	for i, j := int64(len(a)), int64(len(b)); i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			switch {
+			case current.AStart == alen:
+				current = LCSMatch{AStart: i - 1, AEnd: i - 1, BStart: j - 1, BEnd: j - 1}
+			case current.AStart == i && current.BStart == j:
+				current.AStart--
+				current.BStart--
+			default:
+				emit = true
+			}
+			if current.AStart == 0 || current.BStart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if lcs[(i-1)*int64(width)+j] > lcs[i*int64(width)+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if current.AStart != alen {
+				emit = true
+			}
+		}
+		if emit {
+			matches = append(matches, current)
+			current = LCSMatch{AStart: alen}
+		}
+	}
+	return string(result), matches, nil
+}
+
+// readString returns the string stored at key, or nil if it does not exist.
+// The caller must hold d.mu.
+func (d *Datastore) readString(key string) (*string, error) {
+	b, ok, err := d.readBytes(key)
+	if !ok || err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
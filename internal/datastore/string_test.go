@@ -0,0 +1,103 @@
+package datastore
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAppendKeepsExpiry(t *testing.T) {
+	ds := NewDatastore()
+	ds.SetWithExpiry("k", "a", 10000)
+	if n, _ := ds.Append("k", "b"); n != 2 {
+		t.Errorf("Expected length 2, got %d", n)
+	}
+	if ds.data["k"].Expiry == -1 {
+		t.Errorf("Expected APPEND to keep the expiration")
+	}
+	if v, _ := ds.Get("k"); v != "ab" {
+		t.Errorf("Expected ab, got %s", v)
+	}
+}
+
+func TestMSetNXIsAllOrNothing(t *testing.T) {
+	ds := NewDatastore()
+	ds.RPush("list", "a")
+	if ds.MSetNX("a", "1", "list", "2") {
+		t.Errorf("Expected MSETNX to fail when a key exists, whatever its type")
+	}
+	if ds.Exists("a") {
+		t.Errorf("Expected no key to be set")
+	}
+	if !ds.MSetNX("a", "1", "b", "2") {
+		t.Errorf("Expected MSETNX to set new keys")
+	}
+}
+
+func TestGetEx(t *testing.T) {
+	ds := NewDatastore()
+	ds.Set("k", "v")
+	v, err := ds.GetEx("k", Expiry{Mode: ExpiryAfter, Millis: 10000})
+	if err != nil || *v != "v" {
+		t.Fatalf("Expected v, got %v %v", v, err)
+	}
+	if exp := ds.data["k"].Expiry; exp < time.Now().UnixMilli() {
+		t.Errorf("Expected an expiration in the future, got %d", exp)
+	}
+	ds.GetEx("k", Expiry{})
+	if ds.data["k"].Expiry == -1 {
+		t.Errorf("Expected GETEX without options to keep the expiration")
+	}
+	ds.GetEx("k", Expiry{Mode: ExpiryPersist})
+	if ds.data["k"].Expiry != -1 {
+		t.Errorf("Expected PERSIST to remove the expiration")
+	}
+	if _, err := ds.GetEx("k", Expiry{Mode: ExpiryAfter, Millis: math.MaxInt64 - 1}); err != ErrInvalidExpireTime {
+		t.Errorf("Expected an overflowing expiration to fail, got %v", err)
+	}
+	if v, _ := ds.GetEx("missing", Expiry{Mode: ExpiryPersist}); v != nil {
+		t.Errorf("Expected nil for a missing key, got %s", *v)
+	}
+}
+
+func TestLCS(t *testing.T) {
+	ds := NewDatastore()
+	ds.MSet("a", "ohmytext", "b", "mynewtext")
+	lcs, matches, err := ds.LCS("a", "b")
+	if err != nil || lcs != "mytext" {
+		t.Fatalf("Expected mytext, got %q %v", lcs, err)
+	}
+	expected := []LCSMatch{{AStart: 4, AEnd: 7, BStart: 5, BEnd: 8}, {AStart: 2, AEnd: 3, BStart: 0, BEnd: 1}}
+	if len(matches) != len(expected) || matches[0] != expected[0] || matches[1] != expected[1] {
+		t.Errorf("Expected matches %v, got %v", expected, matches)
+	}
+	if lcs, matches, _ := ds.LCS("a", "missing"); lcs != "" || len(matches) != 0 {
+		t.Errorf("Expected no common subsequence with a missing key, got %q %v", lcs, matches)
+	}
+}
+
+func TestSetWithOptions(t *testing.T) {
+	ds := NewDatastore()
+	ds.SetWithExpiry("k", "v", 10000)
+	old, ok, _ := ds.SetWithOptions("k", "w", SetOptions{Condition: SetIfExists, Expiry: Expiry{Mode: ExpiryKeep}})
+	if !ok || *old != "v" {
+		t.Errorf("Expected XX to overwrite the key")
+	}
+	if ds.data["k"].Expiry == -1 {
+		t.Errorf("Expected KEEPTTL to keep the expiration")
+	}
+	if _, ok, _ := ds.SetWithOptions("k", "x", SetOptions{Condition: SetIfMissing}); ok {
+		t.Errorf("Expected NX not to overwrite the key")
+	}
+	ds.SetWithOptions("k", "x", SetOptions{Expiry: Expiry{Mode: ExpiryPersist}})
+	if ds.data["k"].Expiry != -1 {
+		t.Errorf("Expected the expiration to be removed")
+	}
+	ds.HSet("h", "f", "v")
+	if _, _, err := ds.SetWithOptions("h", "v", SetOptions{Get: true}); err != ErrWrongType {
+		t.Errorf("Expected %v, got %v", ErrWrongType, err)
+	}
+	if old, ok, err := ds.SetWithOptions("h", "v", SetOptions{}); !ok || old != nil || err != nil {
+		t.Errorf("Expected a plain set to overwrite any type, got %v %v %v", old, ok, err)
+	}
+}
@@ -0,0 +1,76 @@
+package datastore
+
+import "sync/atomic"
+
+// Watch is a set of keys watched by a client with WATCH, flagged as dirty as soon as one of
+// them is modified or expires.
+type Watch struct {
+	dirty atomic.Bool
+}
+
+// NewWatch returns a Watch of no key.
+func NewWatch() *Watch {
+	return &Watch{}
+}
+
+// Dirty reports whether one of the watched keys has been modified.
+func (w *Watch) Dirty() bool {
+	return w.dirty.Load()
+}
+
+// Watch adds the key to the keys watched by w. A key that has already expired is looked up
+// first, so that its deletion does not count as a modification.
+func (d *Datastore) Watch(w *Watch, key string) {
+	d.mu.Lock()
+	defer d.unlock()
+	d.peek(key)
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	if d.watches == nil {
+		d.watches = make(map[string]map[*Watch]struct{})
+	}
+	if d.watches[key] == nil {
+		d.watches[key] = make(map[*Watch]struct{})
+	}
+	d.watches[key][w] = struct{}{}
+}
+
+// Unwatch removes the keys from the keys watched by w.
+func (d *Datastore) Unwatch(w *Watch, keys ...string) {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	for _, key := range keys {
+		delete(d.watches[key], w)
+		if len(d.watches[key]) == 0 {
+			delete(d.watches, key)
+		}
+	}
+}
+
+// SignalModified flags the watches of the keys as dirty.
+func (d *Datastore) SignalModified(keys ...string) {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	for _, key := range keys {
+		for w := range d.watches[key] {
+			w.dirty.Store(true)
+		}
+	}
+}
+
+// signalWatched flags the watches of the watched keys present in any of the maps as dirty.
+func (d *Datastore) signalWatched(data ...map[string]*Entry) {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	now := d.nowMillis()
+	for key, watches := range d.watches {
+		for _, m := range data {
+			if e, ok := m[key]; ok && !e.expired(now) {
+				for w := range watches {
+					w.dirty.Store(true)
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	ds := NewDatastore()
+	w, other := NewWatch(), NewWatch()
+	ds.Watch(w, "a")
+	ds.Watch(w, "b")
+	ds.Watch(other, "b")
+	ds.SignalModified("c")
+	if w.Dirty() || other.Dirty() {
+		t.Errorf("Expected the watches not to be flagged by an unwatched key")
+	}
+	ds.SignalModified("a")
+	if !w.Dirty() || other.Dirty() {
+		t.Errorf("Expected only the watch of the key to be flagged")
+	}
+	ds.Unwatch(other, "b")
+	ds.SignalModified("b")
+	if other.Dirty() {
+		t.Errorf("Expected an unwatched key not to flag the watch")
+	}
+	if len(ds.watches) != 2 {
+		t.Errorf("Expected 2 watched keys, got %d", len(ds.watches))
+	}
+	ds.Unwatch(w, "a", "b")
+	if len(ds.watches) != 0 {
+		t.Errorf("Expected no watched key, got %d", len(ds.watches))
+	}
+}
+
+func TestWatchExpiry(t *testing.T) {
+	clock := NewManualClock(time.UnixMilli(1000000))
+	ds := NewDatastore(WithClock(clock))
+	ds.SetWithExpiry("lazy", "value", 100)
+	ds.SetWithExpiry("active", "value", 100)
+	ds.SetWithExpiry("expired", "value", 50)
+	clock.Advance(50 * time.Millisecond)
+	w := NewWatch()
+	ds.Watch(w, "expired")
+	if w.Dirty() {
+		t.Errorf("Expected watching an expired key not to flag the watch")
+	}
+	lazy, active := NewWatch(), NewWatch()
+	ds.Watch(lazy, "lazy")
+	ds.Watch(active, "active")
+	clock.Advance(50 * time.Millisecond)
+	ds.Exists("lazy")
+	if !lazy.Dirty() {
+		t.Errorf("Expected a key expired on access to flag its watch")
+	}
+	ds.ExpiryCheck()
+	if !active.Dirty() {
+		t.Errorf("Expected a key expired by the expiry cycle to flag its watch")
+	}
+}
+
+func TestWatchFlushAndSwap(t *testing.T) {
+	ds, other := NewDatastore(), NewDatastore()
+	ds.Set("a", "value")
+	other.Set("b", "value")
+	a, b, missing := NewWatch(), NewWatch(), NewWatch()
+	ds.Watch(a, "a")
+	ds.Watch(b, "b")
+	ds.Watch(missing, "c")
+	ds.SwapWith(other)
+	if !a.Dirty() || !b.Dirty() || missing.Dirty() {
+		t.Errorf("Expected swapping to flag the watches of the existing keys only")
+	}
+	flushed := NewWatch()
+	ds.Watch(flushed, "b")
+	ds.Flush(false)
+	if !flushed.Dirty() {
+		t.Errorf("Expected flushing to flag the watches of the existing keys")
+	}
+}
@@ -0,0 +1,665 @@
+package datastore
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+)
+
+// ErrScoreNaN is returned when a sorted set increment would produce a NaN score.
+var ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// SortedSet is a collection of unique members ordered by score. Members are indexed
+// both by a skiplist, for ordered and ranked access, and by a map for O(1) score lookups.
+type SortedSet struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+// ScoredMember is a sorted set member together with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAddOptions are the conditions under which ZADD updates or adds members.
+type ZAddOptions struct {
+	// NX only adds new members.
+	NX bool
+	// XX only updates existing members.
+	XX bool
+	// GT only updates members when the new score is greater than the current one.
+	GT bool
+	// LT only updates members when the new score is less than the current one.
+	LT bool
+}
+
+// ZRangeBy selects how the bounds of a ZRangeSpec are interpreted.
+type ZRangeBy int
+
+const (
+	ZRangeByRank ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ZRangeSpec describes a range query over a sorted set.
+type ZRangeSpec struct {
+	By  ZRangeBy
+	Rev bool
+	// Start and Stop are the inclusive ranks used by ZRangeByRank.
+	Start, Stop int64
+	// Score is the interval used by ZRangeByScore.
+	Score ScoreRange
+	// Lex is the interval used by ZRangeByLex.
+	Lex LexRange
+	// Offset and Count limit the result of score and lex ranges. A negative Count returns all elements.
+	Offset, Count int64
+}
+
+// NewSortedSet creates an empty sorted set.
+func NewSortedSet() *SortedSet {
+	return &SortedSet{dict: make(map[string]float64), zsl: newSkiplist()}
+}
+
+// clone returns a copy of the sorted set.
+func (z *SortedSet) clone() *SortedSet {
+	c := NewSortedSet()
+	for x := z.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		c.Add(x.member, x.score)
+	}
+	return c
+}
+
+// Len returns the number of members in the sorted set.
+func (z *SortedSet) Len() int {
+	return len(z.dict)
+}
+
+// Score returns the score of the member.
+func (z *SortedSet) Score(member string) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Add sets the score of the member, inserting it if needed.
+// Returns true if the member was added.
+func (z *SortedSet) Add(member string, score float64) bool {
+	if cur, ok := z.dict[member]; ok {
+		if cur != score {
+			z.zsl.delete(cur, member)
+			z.zsl.insert(score, member)
+			z.dict[member] = score
+		}
+		return false
+	}
+	z.zsl.insert(score, member)
+	z.dict[member] = score
+	return true
+}
+
+// Remove deletes the member from the sorted set. Returns true if it was present.
+func (z *SortedSet) Remove(member string) bool {
+	score, ok := z.dict[member]
+	if !ok {
+		return false
+	}
+	z.zsl.delete(score, member)
+	delete(z.dict, member)
+	return true
+}
+
+// Rank returns the 0-based rank of the member, counted from the lowest score,
+// or from the highest one when rev is set.
+func (z *SortedSet) Rank(member string, rev bool) (int, bool) {
+	score, ok := z.dict[member]
+	if !ok {
+		return 0, false
+	}
+	rank := z.zsl.rank(score, member)
+	if rev {
+		return z.zsl.length - rank, true
+	}
+	return rank - 1, true
+}
+
+// Count returns the number of members with a score inside the range.
+func (z *SortedSet) Count(r ScoreRange) int {
+	first := z.zsl.firstInRange(r)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInRange(r)
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+// Range returns the members selected by the spec, in ascending order or descending when spec.Rev is set.
+func (z *SortedSet) Range(spec ZRangeSpec) []ScoredMember {
+	ret := make([]ScoredMember, 0)
+	switch spec.By {
+	case ZRangeByRank:
+		from, to := normalizeRange(spec.Start, spec.Stop, z.Len())
+		if from == to {
+			return ret
+		}
+		var x *skiplistNode
+		if spec.Rev {
+			x = z.zsl.byRank(z.zsl.length - from)
+		} else {
+			x = z.zsl.byRank(from + 1)
+		}
+		for i := from; i < to && x != nil; i++ {
+			ret = append(ret, ScoredMember{Member: x.member, Score: x.score})
+			x = z.next(x, spec.Rev)
+		}
+	case ZRangeByScore, ZRangeByLex:
+		if spec.Offset < 0 {
+			return ret
+		}
+		var x *skiplistNode
+		var inRange func(*skiplistNode) bool
+		if spec.By == ZRangeByScore {
+			if spec.Rev {
+				x = z.zsl.lastInRange(spec.Score)
+				inRange = func(n *skiplistNode) bool { return spec.Score.gteMin(n.score) }
+			} else {
+				x = z.zsl.firstInRange(spec.Score)
+				inRange = func(n *skiplistNode) bool { return spec.Score.lteMax(n.score) }
+			}
+		} else {
+			if spec.Rev {
+				x = z.zsl.lastInLexRange(spec.Lex)
+				inRange = func(n *skiplistNode) bool { return spec.Lex.gteMin(n.member) }
+			} else {
+				x = z.zsl.firstInLexRange(spec.Lex)
+				inRange = func(n *skiplistNode) bool { return spec.Lex.lteMax(n.member) }
+			}
+		}
+		for offset := spec.Offset; x != nil && offset > 0; offset-- {
+			x = z.next(x, spec.Rev)
+		}
+		for count := spec.Count; x != nil && count != 0 && inRange(x); count-- {
+			ret = append(ret, ScoredMember{Member: x.member, Score: x.score})
+			x = z.next(x, spec.Rev)
+		}
+	}
+	return ret
+}
+
+func (z *SortedSet) next(x *skiplistNode, rev bool) *skiplistNode {
+	if rev {
+		return x.backward
+	}
+	return x.level[0].forward
+}
+
+// ZAdd adds the members to the sorted set stored at key, or updates their scores,
+// subject to the options. Returns the number of added and of updated members.
+func (d *Datastore) ZAdd(key string, opts ZAddOptions, members []ScoredMember) (int64, int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	if z == nil {
+		if opts.XX {
+			return 0, 0, nil
+		}
+		z = NewSortedSet()
+		d.store(key, newEntry(z, -1))
+	}
+	var added, updated int64
+	for _, m := range members {
+		cur, exists := z.dict[m.Member]
+		if exists {
+			if opts.NX || (opts.GT && m.Score <= cur) || (opts.LT && m.Score >= cur) || m.Score == cur {
+				continue
+			}
+			z.Add(m.Member, m.Score)
+			updated++
+		} else if !opts.XX {
+			z.Add(m.Member, m.Score)
+			added++
+		}
+	}
+	if added+updated > 0 {
+		d.notify(EventZSet, "zadd", key)
+	}
+	d.discardIfEmpty(key, z)
+	return added, updated, nil
+}
+
+// ZIncrBy increments the score of the member in the sorted set stored at key, subject to the options.
+// Returns the new score, or false when the options prevented the update.
+func (d *Datastore) ZIncrBy(key string, opts ZAddOptions, increment float64, member string) (float64, bool, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if z == nil {
+		if opts.XX {
+			return 0, false, nil
+		}
+		z = NewSortedSet()
+		d.store(key, newEntry(z, -1))
+	}
+	cur, exists := z.dict[member]
+	if (exists && opts.NX) || (!exists && opts.XX) {
+		return 0, false, nil
+	}
+	score := cur + increment
+	if math.IsNaN(score) {
+		d.discardIfEmpty(key, z)
+		return 0, false, ErrScoreNaN
+	}
+	if exists && ((opts.GT && score <= cur) || (opts.LT && score >= cur)) {
+		return 0, false, nil
+	}
+	z.Add(member, score)
+	d.notify(EventZSet, "zincr", key)
+	return score, true, nil
+}
+
+// ZRem removes the members from the sorted set stored at key.
+// Returns the number of removed members.
+func (d *Datastore) ZRem(key string, members ...string) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, m := range members {
+		if z.Remove(m) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		d.notify(EventZSet, "zrem", key)
+	}
+	d.deleteIfEmpty(key, z)
+	return removed, nil
+}
+
+// ZCard returns the number of members in the sorted set stored at key.
+func (d *Datastore) ZCard(key string) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+	return int64(z.Len()), nil
+}
+
+// ZScore returns the score of the member in the sorted set stored at key.
+func (d *Datastore) ZScore(key, member string) (float64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if z == nil {
+		return 0, ErrNotFound
+	}
+	score, ok := z.Score(member)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return score, nil
+}
+
+// ZRank returns the 0-based rank and the score of the member in the sorted set stored at key.
+// Ranks are counted from the highest score when rev is set.
+func (d *Datastore) ZRank(key, member string, rev bool) (int64, float64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	if z == nil {
+		return 0, 0, ErrNotFound
+	}
+	rank, ok := z.Rank(member, rev)
+	if !ok {
+		return 0, 0, ErrNotFound
+	}
+	return int64(rank), z.dict[member], nil
+}
+
+// ZCount returns the number of members in the sorted set stored at key with a score inside the range.
+func (d *Datastore) ZCount(key string, r ScoreRange) (int64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+	return int64(z.Count(r)), nil
+}
+
+// ZRange returns the members of the sorted set stored at key selected by the spec.
+func (d *Datastore) ZRange(key string, spec ZRangeSpec) ([]ScoredMember, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if z == nil {
+		return []ScoredMember{}, nil
+	}
+	return z.Range(spec), nil
+}
+
+// getSortedSet returns the sorted set stored at key, nil if the key does not exist or
+// ErrWrongType if the key holds another kind of value. The caller must hold d.mu.
+func (d *Datastore) getSortedSet(key string) (*SortedSet, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	z, ok := e.Value.(*SortedSet)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return z, nil
+}
+
+// ZAggregate selects how scores of the same member are combined by ZUNIONSTORE and ZINTERSTORE.
+type ZAggregate int
+
+const (
+	ZAggregateSum ZAggregate = iota
+	ZAggregateMin
+	ZAggregateMax
+)
+
+// ZSetOperation selects the operation performed by ZStore.
+type ZSetOperation int
+
+const (
+	ZUnion ZSetOperation = iota
+	ZInter
+	ZDiff
+)
+
+// ZRemRange removes the members of the sorted set stored at key selected by the spec.
+// Returns the number of removed members.
+func (d *Datastore) ZRemRange(key string, spec ZRangeSpec) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+	members := z.Range(spec)
+	for _, m := range members {
+		z.Remove(m.Member)
+	}
+	if len(members) > 0 {
+		d.notify(EventZSet, spec.By.remRangeEvent(), key)
+	}
+	d.deleteIfEmpty(key, z)
+	return int64(len(members)), nil
+}
+
+// ZPop removes and returns up to count members with the lowest scores from the sorted set
+// stored at key, or with the highest scores when max is set.
+func (d *Datastore) ZPop(key string, count int, max bool) ([]ScoredMember, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil || count <= 0 {
+		return []ScoredMember{}, err
+	}
+	members := z.Range(ZRangeSpec{Start: 0, Stop: int64(count) - 1, Rev: max})
+	for _, m := range members {
+		z.Remove(m.Member)
+	}
+	if len(members) > 0 {
+		if max {
+			d.notify(EventZSet, "zpopmax", key)
+		} else {
+			d.notify(EventZSet, "zpopmin", key)
+		}
+	}
+	d.deleteIfEmpty(key, z)
+	return members, nil
+}
+
+// ZRandMember returns random members from the sorted set stored at key without removing them.
+// A positive count returns up to count distinct members, while a negative count
+// returns exactly -count members that may repeat.
+func (d *Datastore) ZRandMember(key string, count int64) ([]ScoredMember, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil || z == nil {
+		return []ScoredMember{}, err
+	}
+	n := z.zsl.length
+	if count >= int64(n) {
+		return z.Range(ZRangeSpec{Start: 0, Stop: -1}), nil
+	}
+	ret := make([]ScoredMember, 0, min(max(count, -count), maxPrealloc))
+	if count < 0 {
+		for range -count {
+			ret = append(ret, z.at(rand.IntN(n)))
+		}
+		return ret, nil
+	}
+	// Floyd's algorithm samples count distinct ranks in time proportional to count.
+	ranks := make(map[int]struct{}, count)
+	for j := n - int(count); j < n; j++ {
+		r := rand.IntN(j + 1)
+		if _, ok := ranks[r]; ok {
+			r = j
+		}
+		ranks[r] = struct{}{}
+		ret = append(ret, z.at(r))
+	}
+	return ret, nil
+}
+
+// at returns the member at the 0-based rank of the sorted set.
+func (z *SortedSet) at(rank int) ScoredMember {
+	x := z.zsl.byRank(rank + 1)
+	return ScoredMember{Member: x.member, Score: x.score}
+}
+
+// ZMScore returns the scores of the members in the sorted set stored at key.
+// Missing members are returned as nil.
+func (d *Datastore) ZMScore(key string, members ...string) ([]*float64, error) {
+	d.mu.RLock()
+	defer d.runlock()
+	z, err := d.getSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*float64, len(members))
+	if z == nil {
+		return ret, nil
+	}
+	for i, m := range members {
+		if score, ok := z.Score(m); ok {
+			ret[i] = &score
+		}
+	}
+	return ret, nil
+}
+
+// ZStore computes the union, intersection or difference of the sorted sets stored at keys
+// and stores it in destination. Plain sets are accepted as input with every member scored 1.
+// Weights, when provided, multiply the scores of the corresponding input; the aggregate
+// combines the scores of members present in several inputs and is ignored by ZDiff.
+// Returns the number of members in the resulting sorted set.
+func (d *Datastore) ZStore(destination string, op ZSetOperation, keys []string, weights []float64, aggregate ZAggregate) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	inputs := make([]map[string]float64, len(keys))
+	for i, k := range keys {
+		scores, err := d.getScores(k)
+		if err != nil {
+			return 0, err
+		}
+		inputs[i] = scores
+	}
+	weight := func(i int) float64 {
+		if weights == nil {
+			return 1
+		}
+		return weights[i]
+	}
+	result := NewSortedSet()
+	switch op {
+	case ZUnion:
+		acc := make(map[string]float64)
+		for i, in := range inputs {
+			for m, s := range in {
+				s = weightedScore(s, weight(i))
+				if cur, ok := acc[m]; ok {
+					acc[m] = aggregateScores(cur, s, aggregate)
+				} else {
+					acc[m] = s
+				}
+			}
+		}
+		for m, s := range acc {
+			result.Add(m, s)
+		}
+	case ZInter:
+		for m, s := range inputs[0] {
+			acc := weightedScore(s, weight(0))
+			found := true
+			for i, in := range inputs[1:] {
+				other, ok := in[m]
+				if !ok {
+					found = false
+					break
+				}
+				acc = aggregateScores(acc, weightedScore(other, weight(i+1)), aggregate)
+			}
+			if found {
+				result.Add(m, acc)
+			}
+		}
+	case ZDiff:
+	members:
+		for m, s := range inputs[0] {
+			for _, in := range inputs[1:] {
+				if _, ok := in[m]; ok {
+					continue members
+				}
+			}
+			result.Add(m, s)
+		}
+	}
+	d.storeSortedSet(destination, result, op.storeEvent())
+	return int64(result.Len()), nil
+}
+
+// ZRangeStore stores the members of the sorted set stored at source selected by the spec in destination.
+// Returns the number of members in the resulting sorted set.
+func (d *Datastore) ZRangeStore(destination, source string, spec ZRangeSpec) (int64, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	z, err := d.getSortedSet(source)
+	if err != nil {
+		return 0, err
+	}
+	result := NewSortedSet()
+	if z != nil {
+		for _, m := range z.Range(spec) {
+			result.Add(m.Member, m.Score)
+		}
+	}
+	d.storeSortedSet(destination, result, "zrangestore")
+	return int64(result.Len()), nil
+}
+
+// storeSortedSet replaces the value at key with the sorted set, deleting the key if it is empty,
+// and raises the event. The caller must hold d.mu.
+func (d *Datastore) storeSortedSet(key string, z *SortedSet, event string) {
+	if z.Len() == 0 {
+		if d.lookup(key) != nil {
+			d.remove(key)
+			d.notify(EventGeneric, "del", key)
+		}
+	} else {
+		d.store(key, newEntry(z, -1))
+		d.notify(EventZSet, event, key)
+	}
+}
+
+// remRangeEvent returns the name of the event raised when removing a range of members.
+func (by ZRangeBy) remRangeEvent() string {
+	switch by {
+	case ZRangeByScore:
+		return "zremrangebyscore"
+	case ZRangeByLex:
+		return "zremrangebylex"
+	}
+	return "zremrangebyrank"
+}
+
+// storeEvent returns the name of the event raised when the result of op is stored.
+func (op ZSetOperation) storeEvent() string {
+	switch op {
+	case ZInter:
+		return "zinterstore"
+	case ZDiff:
+		return "zdiffstore"
+	}
+	return "zunionstore"
+}
+
+// getScores returns the member scores of the sorted set or set stored at key.
+// The caller must hold d.mu.
+func (d *Datastore) getScores(key string) (map[string]float64, error) {
+	e := d.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	switch v := e.Value.(type) {
+	case *SortedSet:
+		return v.dict, nil
+	case Set:
+		scores := make(map[string]float64, len(v))
+		for m := range v {
+			scores[m] = 1
+		}
+		return scores, nil
+	}
+	return nil, ErrWrongType
+}
+
+func weightedScore(score, weight float64) float64 {
+	v := score * weight
+	if math.IsNaN(v) {
+		// 0 * inf is treated as 0, as Redis does
+		return 0
+	}
+	return v
+}
+
+func aggregateScores(a, b float64, aggregate ZAggregate) float64 {
+	switch aggregate {
+	case ZAggregateMin:
+		return math.Min(a, b)
+	case ZAggregateMax:
+		return math.Max(a, b)
+	}
+	v := a + b
+	if math.IsNaN(v) {
+		// inf + -inf is treated as 0, as Redis does
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,161 @@
+package datastore
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSortedSetRanks(t *testing.T) {
+	z := NewSortedSet()
+	scores := make(map[string]float64)
+	for i := range 500 {
+		member := fmt.Sprintf("m%d", rand.IntN(200))
+		score := float64(rand.IntN(50))
+		if i%3 == 0 {
+			z.Remove(member)
+			delete(scores, member)
+		} else {
+			z.Add(member, score)
+			scores[member] = score
+		}
+	}
+	expected := make([]ScoredMember, 0, len(scores))
+	for m, s := range scores {
+		expected = append(expected, ScoredMember{Member: m, Score: s})
+	}
+	slices.SortFunc(expected, func(a, b ScoredMember) int {
+		if a.Score != b.Score {
+			return int(a.Score - b.Score)
+		}
+		if a.Member < b.Member {
+			return -1
+		}
+		return 1
+	})
+	if z.Len() != len(expected) {
+		t.Fatalf("Expected %d members, got %d", len(expected), z.Len())
+	}
+	got := z.Range(ZRangeSpec{Start: 0, Stop: -1})
+	if !slices.Equal(got, expected) {
+		t.Fatalf("Unexpected order %v", got)
+	}
+	for i, m := range expected {
+		if rank, _ := z.Rank(m.Member, false); rank != i {
+			t.Errorf("Expected rank %d for %s, got %d", i, m.Member, rank)
+		}
+		if rank, _ := z.Rank(m.Member, true); rank != len(expected)-1-i {
+			t.Errorf("Expected reverse rank %d for %s, got %d", len(expected)-1-i, m.Member, rank)
+		}
+	}
+}
+
+func TestSortedSetRange(t *testing.T) {
+	z := NewSortedSet()
+	for i, m := range []string{"a", "b", "c", "d", "e"} {
+		z.Add(m, float64(i+1))
+	}
+	members := func(ms []ScoredMember) []string {
+		ret := make([]string, len(ms))
+		for i, m := range ms {
+			ret[i] = m.Member
+		}
+		return ret
+	}
+	tests := map[string]struct {
+		spec     ZRangeSpec
+		expected []string
+	}{
+		"By rank":             {spec: ZRangeSpec{Start: 1, Stop: -2}, expected: []string{"b", "c", "d"}},
+		"By rank reversed":    {spec: ZRangeSpec{Start: 0, Stop: 1, Rev: true}, expected: []string{"e", "d"}},
+		"By score":            {spec: ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 2, Max: 4, MinEx: true}, Count: -1}, expected: []string{"c", "d"}},
+		"By score limit":      {spec: ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 1, Max: 5}, Offset: 1, Count: 2}, expected: []string{"b", "c"}},
+		"By score reversed":   {spec: ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 1, Max: 3}, Rev: true, Count: -1}, expected: []string{"c", "b", "a"}},
+		"By score empty":      {spec: ZRangeSpec{By: ZRangeByScore, Score: ScoreRange{Min: 6, Max: 10}, Count: -1}, expected: []string{}},
+		"By lex":              {spec: ZRangeSpec{By: ZRangeByLex, Lex: LexRange{Min: LexBound{Value: "b"}, Max: LexBound{Value: "d", Exclusive: true}}, Count: -1}, expected: []string{"b", "c"}},
+		"By lex reversed inf": {spec: ZRangeSpec{By: ZRangeByLex, Lex: LexRange{Min: LexBound{Inf: -1}, Max: LexBound{Inf: 1}}, Rev: true, Count: 2}, expected: []string{"e", "d"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := members(z.Range(test.spec))
+			if !slices.Equal(got, test.expected) {
+				t.Errorf("Expected: %v got %v", test.expected, got)
+			}
+		})
+	}
+	if n := z.Count(ScoreRange{Min: 2, Max: 4}); n != 3 {
+		t.Errorf("Expected count 3, got %d", n)
+	}
+}
+
+func TestZAddOptions(t *testing.T) {
+	tests := map[string]struct {
+		opts           ZAddOptions
+		member         ScoredMember
+		added, updated int64
+		expectedScore  float64
+	}{
+		"NX existing": {opts: ZAddOptions{NX: true}, member: ScoredMember{"a", 5}, expectedScore: 1},
+		"XX new":      {opts: ZAddOptions{XX: true}, member: ScoredMember{"c", 5}},
+		"GT lower":    {opts: ZAddOptions{GT: true}, member: ScoredMember{"b", 1}, expectedScore: 2},
+		"LT lower":    {opts: ZAddOptions{LT: true}, member: ScoredMember{"b", 1}, updated: 1, expectedScore: 1},
+		"GT new":      {opts: ZAddOptions{GT: true}, member: ScoredMember{"d", 1}, added: 1, expectedScore: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ds := NewDatastore()
+			ds.ZAdd("key", ZAddOptions{}, []ScoredMember{{"a", 1}, {"b", 2}})
+			added, updated, err := ds.ZAdd("key", test.opts, []ScoredMember{test.member})
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if added != test.added || updated != test.updated {
+				t.Errorf("Expected %d added and %d updated, got %d and %d", test.added, test.updated, added, updated)
+			}
+			if score, _ := ds.ZScore("key", test.member.Member); score != test.expectedScore {
+				t.Errorf("Expected score %v, got %v", test.expectedScore, score)
+			}
+		})
+	}
+}
+
+func TestZStoreInfinity(t *testing.T) {
+	ds := NewDatastore()
+	ds.ZAdd("z1", ZAddOptions{}, []ScoredMember{{"a", math.Inf(1)}})
+	ds.ZAdd("z2", ZAddOptions{}, []ScoredMember{{"a", math.Inf(-1)}})
+	n, err := ds.ZStore("out", ZUnion, []string{"z1", "z2"}, nil, ZAggregateSum)
+	if err != nil || n != 1 {
+		t.Fatalf("Unexpected result %d, %v", n, err)
+	}
+	if score, _ := ds.ZScore("out", "a"); score != 0 {
+		t.Errorf("Expected inf + -inf to be stored as 0, got %v", score)
+	}
+	ds.ZStore("out", ZUnion, []string{"z1"}, []float64{0}, ZAggregateSum)
+	if score, _ := ds.ZScore("out", "a"); score != 0 {
+		t.Errorf("Expected inf * 0 to be stored as 0, got %v", score)
+	}
+}
+
+func TestZRandMember(t *testing.T) {
+	ds := NewDatastore()
+	ds.ZAdd("z", ZAddOptions{}, []ScoredMember{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}})
+	got, _ := ds.ZRandMember("z", 3)
+	seen := make(map[string]bool)
+	for _, m := range got {
+		if seen[m.Member] || m.Score != float64(m.Member[0]-'a'+1) {
+			t.Errorf("Expected distinct members with their scores, got %v", got)
+		}
+		seen[m.Member] = true
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected 3 members, got %v", got)
+	}
+	if got, _ := ds.ZRandMember("z", 10); len(got) != 4 {
+		t.Errorf("Expected all 4 members, got %v", got)
+	}
+	if got, _ := ds.ZRandMember("z", -10); len(got) != 10 {
+		t.Errorf("Expected 10 members, got %v", got)
+	}
+}
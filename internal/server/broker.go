@@ -0,0 +1,225 @@
+package server
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+// Broker tracks the channel and pattern subscriptions of the connections, and delivers the
+// published messages to them.
+type Broker struct {
+	mu       sync.RWMutex
+	channels registry
+	patterns registry
+	// shards are the registries of the shard channels of each hash slot.
+	shards map[uint16]registry
+}
+
+// registry maps channels or patterns to their subscribers.
+type registry map[string]map[*subscriber]struct{}
+
+// NewBroker returns a broker without subscriptions.
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(registry),
+		patterns: make(registry),
+		shards:   make(map[uint16]registry),
+	}
+}
+
+// Publish sends the message to the subscribers of the channel and of the patterns matching
+// it. Returns the number of subscriptions the message was delivered to.
+func (b *Broker) Publish(channel, message string) int64 {
+	type delivery struct {
+		s     *subscriber
+		reply protocol.Resp
+	}
+	var deliveries []delivery
+	b.mu.RLock()
+	for s := range b.channels[channel] {
+		deliveries = append(deliveries, delivery{s, bulkStringArray("message", channel, message)})
+	}
+	for pattern, subscribers := range b.patterns {
+		if !datastore.GlobMatch(pattern, channel) {
+			continue
+		}
+		for s := range subscribers {
+			deliveries = append(deliveries, delivery{s, bulkStringArray("pmessage", pattern, channel, message)})
+		}
+	}
+	b.mu.RUnlock()
+	for _, d := range deliveries {
+		d.s.publish(d.reply)
+	}
+	return int64(len(deliveries))
+}
+
+// SPublish sends the message to the subscribers of the shard channel. Returns the number of
+// subscribers the message was delivered to.
+func (b *Broker) SPublish(channel, message string) int64 {
+	b.mu.RLock()
+	var subscribers []*subscriber
+	for s := range b.shards[keySlot(channel)][channel] {
+		subscribers = append(subscribers, s)
+	}
+	b.mu.RUnlock()
+	for _, s := range subscribers {
+		s.publish(bulkStringArray("smessage", channel, message))
+	}
+	return int64(len(subscribers))
+}
+
+// Channels returns the channels with subscribers matching the pattern, all of them if the
+// pattern is empty, sorted.
+func (b *Broker) Channels(pattern string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.channels.matching(pattern)
+}
+
+// ShardChannels returns the shard channels with subscribers matching the pattern, all of them
+// if the pattern is empty, sorted.
+func (b *Broker) ShardChannels(pattern string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ret := make([]string, 0)
+	for _, shard := range b.shards {
+		ret = append(ret, shard.matching(pattern)...)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// NumSub returns the number of subscribers of each channel.
+func (b *Broker) NumSub(channels ...string) []int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ret := make([]int64, len(channels))
+	for i, channel := range channels {
+		ret[i] = int64(len(b.channels[channel]))
+	}
+	return ret
+}
+
+// ShardNumSub returns the number of subscribers of each shard channel.
+func (b *Broker) ShardNumSub(channels ...string) []int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ret := make([]int64, len(channels))
+	for i, channel := range channels {
+		ret[i] = int64(len(b.shards[keySlot(channel)][channel]))
+	}
+	return ret
+}
+
+// NumPat returns the number of patterns subscribed to.
+func (b *Broker) NumPat() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int64(len(b.patterns))
+}
+
+// subscribe adds the subscriber to the channel, returning false if it was already subscribed.
+func (b *Broker) subscribe(s *subscriber, channel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.channels.add(channel, s)
+}
+
+// unsubscribe removes the subscriber from the channel, returning false if it was not subscribed.
+func (b *Broker) unsubscribe(s *subscriber, channel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.channels.remove(channel, s)
+}
+
+// psubscribe adds the subscriber to the pattern, returning false if it was already subscribed.
+func (b *Broker) psubscribe(s *subscriber, pattern string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.patterns.add(pattern, s)
+}
+
+// punsubscribe removes the subscriber from the pattern, returning false if it was not
+// subscribed.
+func (b *Broker) punsubscribe(s *subscriber, pattern string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.patterns.remove(pattern, s)
+}
+
+// ssubscribe adds the subscriber to the shard channel, returning false if it was already
+// subscribed.
+func (b *Broker) ssubscribe(s *subscriber, channel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slot := keySlot(channel)
+	if b.shards[slot] == nil {
+		b.shards[slot] = make(registry)
+	}
+	return b.shards[slot].add(channel, s)
+}
+
+// sunsubscribe removes the subscriber from the shard channel, returning false if it was not
+// subscribed.
+func (b *Broker) sunsubscribe(s *subscriber, channel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slot := keySlot(channel)
+	removed := b.shards[slot].remove(channel, s)
+	if len(b.shards[slot]) == 0 {
+		delete(b.shards, slot)
+	}
+	return removed
+}
+
+// add adds the subscriber to the channel or pattern, returning false if it was already
+// subscribed.
+func (r registry) add(name string, s *subscriber) bool {
+	if _, ok := r[name][s]; ok {
+		return false
+	}
+	if r[name] == nil {
+		r[name] = make(map[*subscriber]struct{})
+	}
+	r[name][s] = struct{}{}
+	return true
+}
+
+// remove removes the subscriber from the channel or pattern, returning false if it was not
+// subscribed.
+func (r registry) remove(name string, s *subscriber) bool {
+	if _, ok := r[name][s]; !ok {
+		return false
+	}
+	delete(r[name], s)
+	if len(r[name]) == 0 {
+		delete(r, name)
+	}
+	return true
+}
+
+// matching returns the sorted channels or patterns with subscribers matching the pattern, all
+// of them if the pattern is empty.
+func (r registry) matching(pattern string) []string {
+	ret := make([]string, 0)
+	for name := range r {
+		if pattern == "" || datastore.GlobMatch(pattern, name) {
+			ret = append(ret, name)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// bulkStringArray returns an array of bulk strings.
+func bulkStringArray(items ...string) protocol.Array {
+	ret := make([]protocol.Resp, len(items))
+	for i, item := range items {
+		ret[i] = protocol.BulkString{Data: protocol.Ptr(item)}
+	}
+	return protocol.Array{Items: ret}
+}
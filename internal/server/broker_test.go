@@ -0,0 +1,238 @@
+package server
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestPublish(t *testing.T) {
+	b := NewBroker()
+	s, other := newSubscriber(b), newSubscriber(b)
+	s.handleSubscribe(args("news", "sport"))
+	s.handlePSubscribe(args("n*", "*s"))
+	other.handleSubscribe(args("news"))
+	if n := b.Publish("news", "hello"); n != 4 {
+		t.Errorf("Expected the message to be delivered 4 times, got %d", n)
+	}
+	expected := []protocol.Resp{
+		bulkStringArray("message", "news", "hello"),
+		bulkStringArray("pmessage", "n*", "news", "hello"),
+		bulkStringArray("pmessage", "*s", "news", "hello"),
+	}
+	got := received(s)
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	// The pattern messages may be delivered in any order, after the channel message.
+	if !reflect.DeepEqual(got[0], expected[0]) || !containsAll(got[1:], expected[1:]) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if got := received(other); !reflect.DeepEqual(got, expected[:1]) {
+		t.Errorf("Expected %v, got %v", expected[:1], got)
+	}
+	if n := b.Publish("weather", "sunny"); n != 0 {
+		t.Errorf("Expected no subscriber, got %d", n)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	b := NewBroker()
+	s := newSubscriber(b)
+	tests := []struct {
+		got      protocol.Resp
+		expected protocol.Resp
+	}{
+		{s.handleSubscribe(args("a", "b", "a")), replies{
+			subscription("subscribe", "a", 1),
+			subscription("subscribe", "b", 2),
+			subscription("subscribe", "a", 2),
+		}},
+		{s.handlePSubscribe(args("a*")), replies{subscription("psubscribe", "a*", 3)}},
+		{s.handleUnsubscribe(args("c", "a")), replies{
+			subscription("unsubscribe", "c", 3),
+			subscription("unsubscribe", "a", 2),
+		}},
+		{s.handleUnsubscribe(args()), replies{subscription("unsubscribe", "b", 1)}},
+		{s.handleUnsubscribe(args()), protocol.Array{Items: []protocol.Resp{
+			protocol.BulkString{Data: protocol.Ptr("unsubscribe")},
+			protocol.BulkString{Data: nil},
+			protocol.Integer{Value: 1},
+		}}},
+		{s.handlePUnsubscribe(args()), replies{subscription("punsubscribe", "a*", 0)}},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.got, test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, test.got)
+		}
+	}
+	if len(b.channels) != 0 || len(b.patterns) != 0 {
+		t.Errorf("Expected no subscription left, got %v %v", b.channels, b.patterns)
+	}
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	b := NewBroker()
+	s, other := newSubscriber(b), newSubscriber(b)
+	s.handleSubscribe(args("news.tech", "news.sport", "weather"))
+	s.handlePSubscribe(args("news.*", "*"))
+	other.handleSubscribe(args("news.tech"))
+	other.handlePSubscribe(args("*"))
+	tests := []struct {
+		in       []protocol.Resp
+		expected protocol.Resp
+	}{
+		{args("CHANNELS"), bulkStringArray("news.sport", "news.tech", "weather")},
+		{args("channels", "news.*"), bulkStringArray("news.sport", "news.tech")},
+		{args("CHANNELS", "x*"), bulkStringArray()},
+		{args("CHANNELS", "a", "b"), protocol.Error{Data: "ERR wrong number of arguments for 'pubsub|channels' command"}},
+		{args("NUMSUB", "news.tech", "weather", "x"), protocol.Array{Items: []protocol.Resp{
+			protocol.BulkString{Data: protocol.Ptr("news.tech")}, protocol.Integer{Value: 2},
+			protocol.BulkString{Data: protocol.Ptr("weather")}, protocol.Integer{Value: 1},
+			protocol.BulkString{Data: protocol.Ptr("x")}, protocol.Integer{Value: 0},
+		}}},
+		{args("NUMSUB"), protocol.Array{Items: []protocol.Resp{}}},
+		{args("NUMPAT"), protocol.Integer{Value: 2}},
+		{args("FOO"), protocol.Error{Data: "ERR unknown subcommand 'FOO'. Try PUBSUB HELP."}},
+	}
+	for _, test := range tests {
+		if got := s.handlePubSub(test.in); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("PUBSUB %v: expected %v, got %v", test.in, test.expected, got)
+		}
+	}
+	conn, peer := net.Pipe()
+	go io.Copy(io.Discard, peer)
+	go other.write(conn)
+	other.close()
+	if got := s.handlePubSub(args("NUMSUB", "news.tech")); !reflect.DeepEqual(got, protocol.Array{Items: []protocol.Resp{
+		protocol.BulkString{Data: protocol.Ptr("news.tech")}, protocol.Integer{Value: 1},
+	}}) {
+		t.Errorf("Expected a closed subscriber to be unsubscribed, got %v", got)
+	}
+}
+
+func TestPublishToSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	s := newSubscriber(b)
+	s.handleSubscribe(args("news"))
+	received(s)
+	for range outgoingReplies {
+		b.Publish("news", "hello")
+	}
+	select {
+	case <-s.overflowed:
+		t.Fatalf("Expected the subscriber to keep up with %d messages", outgoingReplies)
+	default:
+	}
+	// Publishing to a subscriber which is not reading does not block.
+	if n := b.Publish("news", "hello"); n != 1 {
+		t.Errorf("Expected the message to be published to 1 subscriber, got %d", n)
+	}
+	select {
+	case <-s.overflowed:
+	default:
+		t.Errorf("Expected the subscriber to be disconnected")
+	}
+	received(s)
+	conn, peer := net.Pipe()
+	go s.write(conn)
+	if _, err := peer.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func args(values ...string) []protocol.Resp {
+	return bulkStringArray(values...).Items
+}
+
+func subscription(kind, name string, count int64) protocol.Array {
+	return protocol.Array{Items: []protocol.Resp{
+		protocol.BulkString{Data: protocol.Ptr(kind)},
+		protocol.BulkString{Data: protocol.Ptr(name)},
+		protocol.Integer{Value: count},
+	}}
+}
+
+// received returns the replies queued for the subscriber.
+func received(s *subscriber) []protocol.Resp {
+	var ret []protocol.Resp
+	for {
+		select {
+		case reply := <-s.out:
+			ret = append(ret, reply)
+		default:
+			return ret
+		}
+	}
+}
+
+func containsAll(got, expected []protocol.Resp) bool {
+	for _, e := range expected {
+		found := false
+		for _, g := range got {
+			if reflect.DeepEqual(g, e) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestShardedPubSub(t *testing.T) {
+	b := NewBroker()
+	s, other := newSubscriber(b), newSubscriber(b)
+	tests := []struct {
+		got      protocol.Resp
+		expected protocol.Resp
+	}{
+		{s.handleSubscribe(args("news")), replies{subscription("subscribe", "news", 1)}},
+		{s.handleSSubscribe(args("{user}.a", "{user}.b")), replies{
+			subscription("ssubscribe", "{user}.a", 1),
+			subscription("ssubscribe", "{user}.b", 2),
+		}},
+		{other.handleSSubscribe(args("{user}.a", "orders")), replies{
+			subscription("ssubscribe", "{user}.a", 1),
+			subscription("ssubscribe", "orders", 2),
+		}},
+		{s.handlePubSub(args("SHARDCHANNELS")), bulkStringArray("orders", "{user}.a", "{user}.b")},
+		{s.handlePubSub(args("SHARDCHANNELS", "{user}*")), bulkStringArray("{user}.a", "{user}.b")},
+		{s.handlePubSub(args("CHANNELS")), bulkStringArray("news")},
+		{s.handlePubSub(args("SHARDNUMSUB", "{user}.a", "news")), protocol.Array{Items: []protocol.Resp{
+			protocol.BulkString{Data: protocol.Ptr("{user}.a")}, protocol.Integer{Value: 2},
+			protocol.BulkString{Data: protocol.Ptr("news")}, protocol.Integer{Value: 0},
+		}}},
+		{s.handleSPublish(args("{user}.a", "hi")), protocol.Integer{Value: 2}},
+		{s.handleSPublish(args("news", "hi")), protocol.Integer{Value: 0}},
+		{s.handlePublish(args("{user}.a", "hi")), protocol.Integer{Value: 0}},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.got, test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, test.got)
+		}
+	}
+	message := bulkStringArray("smessage", "{user}.a", "hi")
+	for _, sub := range []*subscriber{s, other} {
+		if got := received(sub); !reflect.DeepEqual(got, []protocol.Resp{message}) {
+			t.Errorf("Expected %v, got %v", message, got)
+		}
+	}
+	if got := s.handleSUnsubscribe(args()); !reflect.DeepEqual(got, replies{
+		subscription("sunsubscribe", "{user}.a", 1),
+		subscription("sunsubscribe", "{user}.b", 0),
+	}) {
+		t.Errorf("Expected the shard channels to be unsubscribed, got %v", got)
+	}
+	if s.subscriptions() != 1 {
+		t.Errorf("Expected the channel subscription to be kept, got %d subscriptions", s.subscriptions())
+	}
+	other.handleSUnsubscribe(args("{user}.a", "orders"))
+	if len(b.shards) != 0 {
+		t.Errorf("Expected the empty slots to be released, got %v", b.shards)
+	}
+}
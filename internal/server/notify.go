@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+)
+
+// eventClasses maps the flags of notify-keyspace-events to the classes of events they select.
+var eventClasses = map[rune]datastore.EventClass{
+	'g': datastore.EventGeneric,
+	'$': datastore.EventString,
+	'l': datastore.EventList,
+	's': datastore.EventSet,
+	'h': datastore.EventHash,
+	'z': datastore.EventZSet,
+	't': datastore.EventStream,
+	'x': datastore.EventExpired,
+	'e': datastore.EventEvicted,
+}
+
+// KeyspaceEvents selects the keyspace events published by the server, like the
+// notify-keyspace-events setting of Redis.
+type KeyspaceEvents struct {
+	classes datastore.EventClass
+	// keyspace and keyevent select the __keyspace@<db>__ and __keyevent@<db>__ channels.
+	keyspace, keyevent bool
+}
+
+// ParseKeyspaceEvents parses the flags of notify-keyspace-events: K and E select the channels
+// the events are published to, the other flags their classes, A standing for all of them. The
+// new key (n), key miss (m) and module (d) classes are accepted, but never raised.
+func ParseKeyspaceEvents(flags string) (KeyspaceEvents, error) {
+	var e KeyspaceEvents
+	for _, flag := range flags {
+		switch flag {
+		case 'K':
+			e.keyspace = true
+		case 'E':
+			e.keyevent = true
+		case 'n', 'm', 'd':
+		case 'A':
+			for _, class := range eventClasses {
+				e.classes |= class
+			}
+		default:
+			class, ok := eventClasses[flag]
+			if !ok {
+				return KeyspaceEvents{}, fmt.Errorf("invalid keyspace event flag %q", flag)
+			}
+			e.classes |= class
+		}
+	}
+	return e, nil
+}
+
+// Notifier returns the notifier publishing the selected events of the database db to the
+// broker, or nil if no event would ever be published.
+func (e KeyspaceEvents) Notifier(b *Broker, db int) datastore.Notifier {
+	if e.classes == 0 || (!e.keyspace && !e.keyevent) {
+		return nil
+	}
+	keyspace := fmt.Sprintf("__keyspace@%d__:", db)
+	keyevent := fmt.Sprintf("__keyevent@%d__:", db)
+	return func(class datastore.EventClass, event, key string) {
+		if e.classes&class == 0 {
+			return
+		}
+		if e.keyspace {
+			b.Publish(keyspace+key, event)
+		}
+		if e.keyevent {
+			b.Publish(keyevent+event, key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dimitrovvlado/redis-server/internal/datastore"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+func TestParseKeyspaceEvents(t *testing.T) {
+	tests := []struct {
+		flags    string
+		expected KeyspaceEvents
+	}{
+		{"", KeyspaceEvents{}},
+		{"Kl", KeyspaceEvents{classes: datastore.EventList, keyspace: true}},
+		{"Ex$", KeyspaceEvents{classes: datastore.EventExpired | datastore.EventString, keyevent: true}},
+		{"KEnmd", KeyspaceEvents{keyspace: true, keyevent: true}},
+		{"KEA", KeyspaceEvents{classes: datastore.EventGeneric | datastore.EventString | datastore.EventList |
+			datastore.EventSet | datastore.EventHash | datastore.EventZSet | datastore.EventStream |
+			datastore.EventExpired | datastore.EventEvicted, keyspace: true, keyevent: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeyspaceEvents(tt.flags)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.flags, err)
+		} else if got != tt.expected {
+			t.Errorf("Expected %+v for %q, got %+v", tt.expected, tt.flags, got)
+		}
+	}
+	if _, err := ParseKeyspaceEvents("Kq"); err == nil {
+		t.Errorf("Expected an unknown flag to fail")
+	}
+}
+
+func TestKeyspaceNotifier(t *testing.T) {
+	for _, flags := range []string{"", "K", "A"} {
+		e, _ := ParseKeyspaceEvents(flags)
+		if e.Notifier(NewBroker(), 0) != nil {
+			t.Errorf("Expected no notifier for %q", flags)
+		}
+	}
+	b := NewBroker()
+	s := newSubscriber(b)
+	s.handlePSubscribe(args("__key*__:*"))
+	received(s)
+	e, _ := ParseKeyspaceEvents("KEl")
+	ds := datastore.NewDatastore(datastore.WithNotifier(e.Notifier(b, 3)))
+	ds.Set("k", "v")
+	ds.LPush("list", "a")
+	expected := []protocol.Resp{
+		bulkStringArray("pmessage", "__key*__:*", "__keyspace@3__:list", "lpush"),
+		bulkStringArray("pmessage", "__key*__:*", "__keyevent@3__:lpush", "list"),
+	}
+	if got := received(s); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
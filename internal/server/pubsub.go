@@ -0,0 +1,277 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dimitrovvlado/redis-server/internal/commands"
+	"github.com/dimitrovvlado/redis-server/internal/protocol"
+)
+
+// outgoingReplies is the number of replies and messages buffered for a connection. A subscriber
+// falling that far behind the messages published to it is disconnected, like with the pubsub
+// client-output-buffer-limit of Redis.
+const outgoingReplies = 1024
+
+// subscriber is the pub/sub state of a connection. All its replies, including the messages
+// published to it, are written in order by a single goroutine so that they do not interleave.
+type subscriber struct {
+	broker *Broker
+	out    chan protocol.Resp
+	done   chan struct{}
+	// written is closed once the replies have been written.
+	written chan struct{}
+	// overflowed is closed once a published message did not fit in out.
+	overflowed chan struct{}
+	overflow   sync.Once
+	// The subscriptions are only accessed by the goroutine of the connection.
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+}
+
+func newSubscriber(broker *Broker) *subscriber {
+	return &subscriber{
+		broker:        broker,
+		out:           make(chan protocol.Resp, outgoingReplies),
+		done:          make(chan struct{}),
+		written:       make(chan struct{}),
+		overflowed:    make(chan struct{}),
+		channels:      make(map[string]struct{}),
+		patterns:      make(map[string]struct{}),
+		shardChannels: make(map[string]struct{}),
+	}
+}
+
+// commands returns the pub/sub commands of the connection.
+func (s *subscriber) commands() map[string]commands.Command {
+	return map[string]commands.Command{
+		"subscribe":    {Handler: s.handleSubscribe, Arity: -2},
+		"unsubscribe":  {Handler: s.handleUnsubscribe, Arity: -1},
+		"psubscribe":   {Handler: s.handlePSubscribe, Arity: -2},
+		"punsubscribe": {Handler: s.handlePUnsubscribe, Arity: -1},
+		"ssubscribe":   {Handler: s.handleSSubscribe, Arity: -2},
+		"sunsubscribe": {Handler: s.handleSUnsubscribe, Arity: -1},
+		"publish":      {Handler: s.handlePublish, Arity: 3},
+		"spublish":     {Handler: s.handleSPublish, Arity: 3},
+		"pubsub":       {Handler: s.handlePubSub, Arity: -2},
+	}
+}
+
+// subscriptions returns the number of channels, patterns and shard channels subscribed to.
+func (s *subscriber) subscriptions() int {
+	return len(s.channels) + len(s.patterns) + len(s.shardChannels)
+}
+
+// send queues the reply to be written to the connection, unless it is closed.
+func (s *subscriber) send(reply protocol.Resp) {
+	select {
+	case s.out <- reply:
+	case <-s.done:
+	}
+}
+
+// publish queues the published message without waiting, as it may be published while the lock
+// of a datastore is held. The connection is closed if the message does not fit.
+func (s *subscriber) publish(message protocol.Resp) {
+	select {
+	case s.out <- message:
+	case <-s.done:
+	default:
+		s.overflow.Do(func() { close(s.overflowed) })
+	}
+}
+
+// write writes the queued replies to the connection until the subscriber is closed.
+func (s *subscriber) write(conn net.Conn) {
+	defer close(s.written)
+	for {
+		select {
+		case reply := <-s.out:
+			writeReply(conn, reply)
+		case <-s.overflowed:
+			log.Printf("Closing a subscriber that fell %d replies behind", outgoingReplies)
+			conn.Close()
+			return
+		case <-s.done:
+			// Write the replies queued before closing.
+			for {
+				select {
+				case reply := <-s.out:
+					writeReply(conn, reply)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func writeReply(conn net.Conn, reply protocol.Resp) {
+	if _, err := conn.Write(protocol.Encode(reply)); err != nil {
+		log.Println("Error writing to connection: ", err)
+	}
+}
+
+// close removes the subscriptions, then waits for the queued replies to be written.
+func (s *subscriber) close() {
+	for channel := range s.channels {
+		s.broker.unsubscribe(s, channel)
+	}
+	for pattern := range s.patterns {
+		s.broker.punsubscribe(s, pattern)
+	}
+	for channel := range s.shardChannels {
+		s.broker.sunsubscribe(s, channel)
+	}
+	close(s.done)
+	<-s.written
+}
+
+func (s *subscriber) handleSubscribe(args []protocol.Resp) protocol.Resp {
+	return s.subscribe("subscribe", s.channels, s.broker.subscribe, args)
+}
+
+func (s *subscriber) handleUnsubscribe(args []protocol.Resp) protocol.Resp {
+	return s.unsubscribe("unsubscribe", s.channels, s.broker.unsubscribe, args)
+}
+
+func (s *subscriber) handlePSubscribe(args []protocol.Resp) protocol.Resp {
+	return s.subscribe("psubscribe", s.patterns, s.broker.psubscribe, args)
+}
+
+func (s *subscriber) handlePUnsubscribe(args []protocol.Resp) protocol.Resp {
+	return s.unsubscribe("punsubscribe", s.patterns, s.broker.punsubscribe, args)
+}
+
+func (s *subscriber) handleSSubscribe(args []protocol.Resp) protocol.Resp {
+	return s.subscribe("ssubscribe", s.shardChannels, s.broker.ssubscribe, args)
+}
+
+func (s *subscriber) handleSUnsubscribe(args []protocol.Resp) protocol.Resp {
+	return s.unsubscribe("sunsubscribe", s.shardChannels, s.broker.sunsubscribe, args)
+}
+
+// subscribe adds the subscriptions to the channels or patterns named by args.
+func (s *subscriber) subscribe(kind string, subscribed map[string]struct{}, add func(*subscriber, string) bool, args []protocol.Resp) protocol.Resp {
+	ret := make(replies, len(args))
+	for i, arg := range args {
+		name := arg.String()
+		if add(s, name) {
+			subscribed[name] = struct{}{}
+		}
+		ret[i] = s.subscriptionReply(kind, &name)
+	}
+	return ret
+}
+
+// unsubscribe removes the subscriptions to the channels or patterns named by args, or to all
+// of them if there is no argument.
+func (s *subscriber) unsubscribe(kind string, subscribed map[string]struct{}, remove func(*subscriber, string) bool, args []protocol.Resp) protocol.Resp {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = arg.String()
+	}
+	if len(args) == 0 {
+		for name := range subscribed {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		if len(names) == 0 {
+			return s.subscriptionReply(kind, nil)
+		}
+	}
+	ret := make(replies, len(names))
+	for i, name := range names {
+		remove(s, name)
+		delete(subscribed, name)
+		ret[i] = s.subscriptionReply(kind, &name)
+	}
+	return ret
+}
+
+// subscriptionReply returns the reply confirming a change of subscription, with the number of
+// subscriptions left. The shard subscriptions are counted apart from the others.
+func (s *subscriber) subscriptionReply(kind string, name *string) protocol.Array {
+	count := len(s.channels) + len(s.patterns)
+	if kind == "ssubscribe" || kind == "sunsubscribe" {
+		count = len(s.shardChannels)
+	}
+	return protocol.Array{Items: []protocol.Resp{
+		protocol.BulkString{Data: protocol.Ptr(kind)},
+		protocol.BulkString{Data: name},
+		protocol.Integer{Value: int64(count)},
+	}}
+}
+
+func (s *subscriber) handlePublish(args []protocol.Resp) protocol.Resp {
+	return protocol.Integer{Value: s.broker.Publish(args[0].String(), args[1].String())}
+}
+
+func (s *subscriber) handleSPublish(args []protocol.Resp) protocol.Resp {
+	return protocol.Integer{Value: s.broker.SPublish(args[0].String(), args[1].String())}
+}
+
+func (s *subscriber) handlePubSub(args []protocol.Resp) protocol.Resp {
+	sub := strings.ToLower(args[0].String())
+	switch {
+	case sub == "channels" && len(args) <= 2:
+		return bulkStringArray(s.broker.Channels(optionalPattern(args))...)
+	case sub == "shardchannels" && len(args) <= 2:
+		return bulkStringArray(s.broker.ShardChannels(optionalPattern(args))...)
+	case sub == "numsub":
+		return numSubReply(args[1:], s.broker.NumSub)
+	case sub == "shardnumsub":
+		return numSubReply(args[1:], s.broker.ShardNumSub)
+	case sub == "numpat" && len(args) == 1:
+		return protocol.Integer{Value: s.broker.NumPat()}
+	case sub == "channels" || sub == "shardchannels" || sub == "numpat":
+		return protocol.Error{Data: fmt.Sprintf("ERR wrong number of arguments for 'pubsub|%s' command", sub)}
+	}
+	return protocol.Error{Data: fmt.Sprintf("ERR unknown subcommand '%s'. Try PUBSUB HELP.", args[0].String())}
+}
+
+// optionalPattern returns the pattern following the PUBSUB subcommand, or an empty pattern.
+func optionalPattern(args []protocol.Resp) string {
+	if len(args) == 2 {
+		return args[1].String()
+	}
+	return ""
+}
+
+// numSubReply returns the channels of args paired with their number of subscribers.
+func numSubReply(args []protocol.Resp, numSub func(...string) []int64) protocol.Array {
+	channels := make([]string, len(args))
+	for i, arg := range args {
+		channels[i] = arg.String()
+	}
+	counts := numSub(channels...)
+	ret := make([]protocol.Resp, 0, 2*len(channels))
+	for i, channel := range channels {
+		ret = append(ret, protocol.BulkString{Data: protocol.Ptr(channel)}, protocol.Integer{Value: counts[i]})
+	}
+	return protocol.Array{Items: ret}
+}
+
+// replies are several replies to a single command, like SUBSCRIBE confirming each channel.
+type replies []protocol.Resp
+
+func (r replies) Encode() []byte {
+	var ret []byte
+	for _, reply := range r {
+		ret = append(ret, reply.Encode()...)
+	}
+	return ret
+}
+
+func (r replies) String() string {
+	items := make([]string, len(r))
+	for i, reply := range r {
+		items[i] = reply.String()
+	}
+	return strings.Join(items, "\n")
+}
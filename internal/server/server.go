@@ -6,13 +6,17 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
 
 	"github.com/dimitrovvlado/redis-server/internal/commands"
 	"github.com/dimitrovvlado/redis-server/internal/datastore"
 	"github.com/dimitrovvlado/redis-server/internal/protocol"
 )
 
-func Serve(host string, port int, ds *datastore.Datastore) error {
+// Serve accepts connections on host:port, serving commands against the numbered databases and
+// pub/sub through the broker.
+func Serve(host string, port int, dbs []*datastore.Datastore, broker *Broker) error {
+	databases := commands.NewDatabases(dbs...)
 	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
 	if err != nil {
 		return err
@@ -24,16 +28,97 @@ func Serve(host string, port int, ds *datastore.Datastore) error {
 			log.Fatalf("Failed to establish a connection with the client: %v", err.Error())
 		}
 		defer conn.Close()
-		go handleConnection(conn, ds)
+		go handleConnection(conn, databases, broker)
 	}
 }
 
-func handleConnection(conn net.Conn, ds *datastore.Datastore) {
+// maxQueryBuffer is the number of bytes a client may send ahead of the commands being executed,
+// like the client-query-buffer-limit of Redis.
+const maxQueryBuffer = 1 << 30
+
+var errQueryBufferLimit = errors.New("max query buffer length reached")
+
+// connReader reads a connection in the background, so that its closing is noticed while a
+// command of the client blocks.
+type connReader struct {
+	conn net.Conn
+	mu   sync.Mutex
+	buf  []byte
+	err  error
+	// ready receives a value when data or an error is available.
+	ready chan struct{}
+	// closed is closed once reading failed, the client having closed the connection.
+	closed chan struct{}
+}
+
+func newConnReader(conn net.Conn) *connReader {
+	return &connReader{conn: conn, ready: make(chan struct{}, 1), closed: make(chan struct{})}
+}
+
+// read reads the connection until it fails.
+func (r *connReader) read() {
+	rbuf := make([]byte, 4096)
+	for {
+		n, err := r.conn.Read(rbuf)
+		r.mu.Lock()
+		r.buf = append(r.buf, rbuf[:n]...)
+		if err == nil && len(r.buf) > maxQueryBuffer {
+			err = errQueryBufferLimit
+		}
+		r.err = err
+		r.mu.Unlock()
+		select {
+		case r.ready <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			close(r.closed)
+			return
+		}
+	}
+}
+
+// next waits for data or an error, then appends the data read so far to buf.
+func (r *connReader) next(buf []byte) ([]byte, error) {
+	<-r.ready
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	buf = append(buf, r.buf...)
+	r.buf = r.buf[:0]
+	return buf, r.err
+}
+
+func handleConnection(conn net.Conn, databases *commands.Databases, broker *Broker) {
 	buf := make([]byte, 0, 4096)
-	rbuf := make([]byte, 1024)
 	defer conn.Close()
+	reader := newConnReader(conn)
+	go reader.read()
+	subscriber := newSubscriber(broker)
+	go subscriber.write(conn)
+	defer subscriber.close()
+	client := commands.NewClient(databases,
+		commands.WithCommands(subscriber.commands()),
+		commands.WithSubscriptions(subscriber.subscriptions),
+		commands.WithClosed(reader.closed))
+	defer client.Close()
 	for {
-		n, err := conn.Read(rbuf)
+		var err error
+		buf, err = reader.next(buf)
+		// The buffer may hold several pipelined commands.
+		for {
+			frame, size := protocol.ExtractFrameFromBuffer(buf)
+			if frame == nil {
+				break
+			}
+			result, err := commands.HandleCommand(frame, client)
+			if err != nil {
+				log.Println("Error handling command: ", err)
+			} else {
+				subscriber.send(result)
+			}
+			//trim to remove frame
+			buf = buf[size:]
+		}
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				addr := conn.RemoteAddr()
@@ -42,25 +127,10 @@ func handleConnection(conn net.Conn, ds *datastore.Datastore) {
 				} else {
 					log.Printf("Connection closed by client.")
 				}
-				return
-			}
-		}
-		if n > 0 {
-			buf = append(buf, rbuf[:n]...)
-			frame, size := protocol.ExtractFrameFromBuffer(buf)
-			if frame != nil {
-				result, err := commands.HandleCommand(frame, ds)
-				if err != nil {
-					log.Println("Error handling command: ", err)
-				} else {
-					_, err := conn.Write(protocol.Encode(result))
-					if err != nil {
-						log.Println("Error writing to connection: ", err)
-					}
-				}
-				//trim to remove frame
-				buf = buf[size:]
+			} else {
+				log.Printf("Closing the connection: %v", err)
 			}
+			return
 		}
 	}
 }
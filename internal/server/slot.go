@@ -0,0 +1,33 @@
+package server
+
+import "strings"
+
+// slots is the number of hash slots of a cluster.
+const slots = 16384
+
+// keySlot returns the hash slot of a key or shard channel, like Redis Cluster. When the key
+// contains a non-empty hash tag between braces, only the tag is hashed.
+func keySlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start != -1 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % slots
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
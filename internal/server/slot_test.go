@@ -0,0 +1,25 @@
+package server
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	if crc := crc16("123456789"); crc != 0x31c3 {
+		t.Errorf("Expected the CRC16 of the check string to be 0x31c3, got %#x", crc)
+	}
+	tests := map[string]uint16{
+		"foo":                  12182,
+		"hello":                866,
+		"{foo}.bar":            12182,
+		"x{foo}y{bar}":         12182,
+		"foo{}{bar}":           crc16("foo{}{bar}") % slots,
+		"{user1000}.following": keySlot("user1000"),
+	}
+	for key, expected := range tests {
+		if got := keySlot(key); got != expected {
+			t.Errorf("%s: expected slot %d, got %d", key, expected, got)
+		}
+	}
+	if keySlot("{}foo") != crc16("{}foo")%slots {
+		t.Errorf("Expected an empty hash tag to be ignored")
+	}
+}